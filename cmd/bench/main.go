@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+	"zetamachine/pkg/bench"
+)
+
+func main() {
+	pkgs := flag.String("pkg", "./pkg/...", "packages to benchmark (space separated)")
+	pattern := flag.String("bench", ".", "benchmarks to run, as for go test -bench")
+	benchtime := flag.String("benchtime", "1s", "time or iterations per benchmark, as for go test -benchtime")
+	count := flag.Int("count", 1, "times to run each benchmark, the fastest is recorded")
+	input := flag.String("input", "", "parse existing go test -bench output from this file (- for stdin) instead of running the benchmarks")
+	history := flag.String("history", "bench-history.json", "JSON file every run is appended to")
+	baseline := flag.String("baseline", "bench-baseline.json", "JSON file with the run to compare against")
+	saveBaseline := flag.Bool("save-baseline", false, "save this run as the new baseline")
+	threshold := flag.Float64("threshold", 0.1, "fractional slow down that counts as a regression")
+	flag.Parse()
+
+	out, err := benchmarks(*input, *pkgs, *pattern, *benchtime, *count)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	results, err := bench.Parse(out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(results) == 0 {
+		log.Fatal("[bench] no benchmark results found")
+	}
+
+	run := &bench.Run{
+		Time:      time.Now().UTC(),
+		Commit:    commit(),
+		GoVersion: runtime.Version(),
+		Results:   results,
+	}
+
+	if err := bench.AppendHistory(*history, run); err != nil {
+		log.Fatal(err)
+	}
+	log.Println("[bench] recorded", len(results), "results in", *history)
+
+	if *saveBaseline {
+		if err := bench.SaveRun(*baseline, run); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("[bench] saved baseline", *baseline)
+		return
+	}
+
+	base, err := bench.LoadRun(*baseline)
+	if os.IsNotExist(err) {
+		log.Println("[bench] no baseline to compare with, create one with -save-baseline")
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	comps := bench.Compare(base, run, *threshold)
+	bench.WriteComparison(os.Stdout, comps)
+
+	regressions := 0
+	for _, c := range comps {
+		if c.Regressed {
+			regressions++
+		}
+	}
+	if regressions > 0 {
+		log.Fatal("[bench] ", regressions, " benchmarks regressed by more than ", 100**threshold, "%")
+	}
+}
+
+// benchmarks returns the output of go test -bench, either by running it or
+// reading it from the input file
+func benchmarks(input, pkgs, pattern, benchtime string, count int) (io.Reader, error) {
+	switch input {
+	case "-":
+		return os.Stdin, nil
+	case "":
+	default:
+		return os.Open(input)
+	}
+
+	args := []string{"test", "-run", "^$", "-bench", pattern, "-benchtime", benchtime,
+		"-count", strconv.Itoa(count), "-benchmem"}
+	args = append(args, strings.Fields(pkgs)...)
+	log.Println("[bench] go", strings.Join(args, " "))
+
+	// show the output as it runs and keep a copy to parse
+	buf := &bytes.Buffer{}
+	cmd := exec.Command("go", args...)
+	cmd.Stdout = io.MultiWriter(os.Stdout, buf)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// commit returns the current git commit if there is one
+func commit() string {
+	out, err := exec.Command("git", "rev-parse", "--short", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"zetamachine/pkg/seed"
+	"zetamachine/pkg/zeta"
+
+	"github.com/joho/godotenv"
+)
+
+// finding is a damaged data file
+type finding struct {
+	File    string       `json:"file"`
+	Problem zeta.Problem `json:"problem"`
+	Error   string       `json:"error"`
+	Dest    string       `json:"dest,omitempty"` // where a misplaced file belongs
+
+	tile *zeta.Tile
+}
+
+// report is written with -report
+type report struct {
+	Checked  int                  `json:"checked"`
+	Problems map[zeta.Problem]int `json:"problems"`
+	Findings []finding            `json:"findings"`
+}
+
+// fsck checks every tile data file in the store can be loaded and holds a
+// whole tile. Damaged tiles can be deleted and requested again, and misplaced
+// ones moved to where their names say they belong.
+func main() {
+	jobs := flag.Int("jobs", runtime.NumCPU(), "files checked at once")
+	width := flag.Int("width", 0, "width of the stored tiles in pixels (default the width in each set's metadata)")
+	minZoom := flag.Int("min-zoom", math.MinInt32, "lowest zoom level to check")
+	maxZoom := flag.Int("max-zoom", math.MaxInt32, "highest zoom level to check")
+	set := flag.String("set", "*", "only check tiles of this set, empty for iterated zeta or * for every set")
+	classes := flag.String("problems", "", "problems -delete and -requeue act on, comma separated (default all): "+problemNames())
+	del := flag.Bool("delete", false, "delete damaged data files and move misplaced ones to where their names say")
+	requeue := flag.Bool("requeue", false, "request damaged tiles again on patch-request, needs ZETA_NSQD")
+	reportFile := flag.String("report", "", "write the findings to this JSON file")
+	flag.Parse()
+
+	if err := checkEnv(*requeue); err != nil {
+		log.Fatal(err)
+	}
+	if *jobs < 1 {
+		log.Fatal("-jobs must be at least 1")
+	}
+
+	act, err := parseProblems(*classes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	root := os.Getenv("ZETA_TILE_PATH")
+	widths := zeta.NewSetWidths(root)
+	start := time.Now()
+
+	files := make(chan string)
+	go func() {
+		defer close(files)
+		err := filepath.Walk(root, func(fname string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(info.Name(), zeta.DataExt) {
+				files <- fname
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	mu := &sync.Mutex{}
+	r := &report{Problems: make(map[zeta.Problem]int), Findings: []finding{}}
+	wg := &sync.WaitGroup{}
+	for i := 0; i < *jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fname := range files {
+				f, ok := check(root, fname, widths, *width, *minZoom, *maxZoom, *set)
+				if !ok {
+					continue
+				}
+
+				mu.Lock()
+				r.Checked++
+				if f != nil {
+					r.Problems[f.Problem]++
+					r.Findings = append(r.Findings, *f)
+				}
+				if r.Checked%10000 == 0 {
+					log.Println("[fsck] checked", r.Checked, "tiles,", len(r.Findings), "damaged")
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(r.Findings, func(i, j int) bool {
+		return r.Findings[i].File < r.Findings[j].File
+	})
+	for _, f := range r.Findings {
+		fmt.Printf("%-10s %s: %s\n", f.Problem, f.File, f.Error)
+	}
+
+	log.Printf("[fsck] checked %d tiles in %s, %d damaged", r.Checked, time.Since(start).Round(time.Second), len(r.Findings))
+	for _, p := range zeta.Problems {
+		if r.Problems[p] > 0 {
+			log.Printf("[fsck] %6d %s", r.Problems[p], p)
+		}
+	}
+
+	if *reportFile != "" {
+		if err := writeReport(*reportFile, r); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	repair(r.Findings, act, *del, *requeue)
+}
+
+// check verifies a data file, returning false if it is filtered out. Tiles are
+// expected to be width pixels wide, or as wide as their set's tiles if width
+// is zero.
+func check(root, fname string, widths *zeta.SetWidths, width, minZoom, maxZoom int, set string) (*finding, bool) {
+	t, err := zeta.TileFromPath(root, fname)
+	if err != nil {
+		f := &finding{File: fname, Problem: zeta.ProblemPath, Error: err.Error()}
+		f.Dest, f.tile = place(root, fname, widths)
+		return f, true
+	}
+	if t.Zoom < minZoom || t.Zoom > maxZoom {
+		return nil, false
+	}
+	if set != "*" && t.Set() != set {
+		return nil, false
+	}
+
+	if width == 0 {
+		// without the set's width none of its tiles can be checked
+		if width, err = widths.Width(t.Set()); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	t.Width = width
+	p, err := t.Verify(fname, width)
+	if p == zeta.ProblemNone {
+		return nil, true
+	}
+	t.Data, t.Values = nil, nil
+	return &finding{File: fname, Problem: p, Error: err.Error(), tile: t}, true
+}
+
+// place works out where a misplaced data file belongs from its name and the
+// set directory it is in. It returns an empty path if it can't tell, and a nil
+// tile if the set's width isn't known either.
+func place(root, fname string, widths *zeta.SetWidths) (string, *zeta.Tile) {
+	rel, err := filepath.Rel(root, fname)
+	if err != nil {
+		return "", nil
+	}
+	tok := strings.Split(filepath.ToSlash(rel), "/")
+	if len(tok) != 3 && len(tok) != 4 {
+		return "", nil
+	}
+
+	name := tok[len(tok)-1]
+	t, err := zeta.ParseFilename(name)
+	if err != nil {
+		return "", nil
+	}
+	dest := filepath.Join(root, filepath.Join(tok[:len(tok)-3]...), strconv.Itoa(t.Zoom), strconv.Itoa(t.Y), name)
+	if t, err = zeta.TileFromPath(root, dest); err != nil {
+		return "", nil
+	}
+
+	if t.Width, err = widths.Width(t.Set()); err != nil {
+		return dest, nil
+	}
+	return dest, t
+}
+
+// repair deletes and requests again the damaged tiles with the problems in
+// act. Misplaced files are moved where they belong rather than deleted, and
+// requested again if they can't be.
+func repair(findings []finding, act map[zeta.Problem]bool, del, requeue bool) {
+	if !del && !requeue {
+		return
+	}
+
+	deleted, moved := 0, 0
+	tiles := []*zeta.Tile{}
+	for _, f := range findings {
+		if !act[f.Problem] {
+			continue
+		}
+
+		if f.Problem == zeta.ProblemPath {
+			if f.Dest == "" {
+				log.Println("[fsck] can't tell where", f.File, "belongs, leaving it")
+				continue
+			}
+			if _, err := os.Stat(f.Dest); err == nil {
+				log.Println("[fsck] leaving", f.File, "as", f.Dest, "is already stored")
+				continue
+			}
+			if del {
+				err := move(f.File, f.Dest)
+				if err == nil {
+					moved++
+					continue
+				}
+				log.Println("[fsck] failed to move", f.File, err)
+			}
+			if f.tile != nil {
+				tiles = append(tiles, f.tile)
+			}
+			continue
+		}
+
+		if del {
+			if err := os.Remove(f.File); err != nil {
+				log.Println("[fsck] failed to delete", f.File, err)
+				continue
+			}
+			deleted++
+		}
+
+		tiles = append(tiles, f.tile)
+	}
+	if del {
+		log.Println("[fsck] deleted", deleted, "data files and moved", moved)
+	}
+
+	if requeue && len(tiles) > 0 {
+		if err := seed.Republish(tiles); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("[fsck] requested", len(tiles), "tiles again")
+	}
+}
+
+// move renames the data file to dest, making its directories
+func move(fname, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), os.ModeDir|os.ModePerm); err != nil {
+		return err
+	}
+	return os.Rename(fname, dest)
+}
+
+func parseProblems(s string) (map[zeta.Problem]bool, error) {
+	act := make(map[zeta.Problem]bool)
+	if s == "" {
+		for _, p := range zeta.Problems {
+			act[p] = true
+		}
+		return act, nil
+	}
+
+	for _, name := range strings.Split(s, ",") {
+		p := zeta.Problem(strings.TrimSpace(name))
+		known := false
+		for _, k := range zeta.Problems {
+			known = known || p == k
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown problem %q, expected %s", name, problemNames())
+		}
+		act[p] = true
+	}
+	return act, nil
+}
+
+func problemNames() string {
+	names := []string{}
+	for _, p := range zeta.Problems {
+		names = append(names, string(p))
+	}
+	return strings.Join(names, ", ")
+}
+
+func writeReport(fname string, r *report) error {
+	f, err := os.Create(fname)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func checkEnv(requeue bool) error {
+	godotenv.Load()
+
+	if os.Getenv("ZETA_TILE_PATH") == "" {
+		return errors.New("ZETA_TILE_PATH is not exported")
+	}
+	if requeue && os.Getenv("ZETA_NSQD") == "" {
+		return errors.New("ZETA_NSQD is not exported")
+	}
+	return nil
+}
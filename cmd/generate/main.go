@@ -2,12 +2,16 @@ package main
 
 import (
 	"errors"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 	"zetamachine/pkg/seed"
+	_ "zetamachine/pkg/worker" // the remote backend
+	"zetamachine/pkg/zeta"
 
 	"github.com/go-chi/valve"
 	"github.com/joho/godotenv"
@@ -23,8 +27,18 @@ func main() {
 	if err := checkEnv(); err != nil {
 		log.Fatal(err)
 	}
+	tiers := flag.Int("tiers", 1, "number of priority tier topics to consume (see request -tiers)")
+	backendName := flag.String("backend", zeta.AutoBackend, "backend tiles are computed with: "+strings.Join(zeta.Backends(), ", ")+" or auto for the fastest available")
+	flag.Parse()
+
+	backend, err := zeta.NewBackend(*backendName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("[seed] computing tiles with the", backend.Name(), "backend")
+
 	v := valve.New()
-	server, err := seed.NewCudaServer(v)
+	server, err := seed.NewCudaServer(v, *tiers, backend)
 	if err != nil {
 		log.Fatal(err)
 	}
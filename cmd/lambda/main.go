@@ -1,11 +1,49 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"os"
 	z "zetamachine/pkg/lambda"
 
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 )
 
 func main() {
-	lambda.Start(z.Compute)
+	local := flag.String("local", "", "invoke the handler once with the API Gateway event in this file (- for stdin) and print the response, instead of running in Lambda")
+	flag.Parse()
+
+	if *local == "" {
+		lambda.Start(z.Compute)
+		return
+	}
+
+	var b []byte
+	var err error
+	if *local == "-" {
+		b, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		b, err = ioutil.ReadFile(*local)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	req := events.APIGatewayProxyRequest{}
+	if err := json.Unmarshal(b, &req); err != nil {
+		log.Fatal("invalid event: ", err)
+	}
+
+	resp, err := z.Compute(context.Background(), req)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(resp)
 }
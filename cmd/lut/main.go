@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	_ "zetamachine/pkg/worker" // the remote backend
+	"zetamachine/pkg/zeta"
+
+	"github.com/joho/godotenv"
+)
+
+const usage = `usage: lut <command> [flags]
+
+commands:
+  render  compute the lookup tables in the manifest
+  build   fill the lookup tables in the manifest from stored iteration tiles
+  report  compare tiles computed with the lookup tables to full computation
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	godotenv.Load()
+	defaultManifest := os.Getenv("ZETA_LUT_MANIFEST")
+	if defaultManifest == "" {
+		defaultManifest = "lut/manifest.json"
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "render":
+		err = render(os.Args[2:], defaultManifest)
+	case "build":
+		err = build(os.Args[2:], defaultManifest)
+	case "report":
+		err = report(os.Args[2:], defaultManifest)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// render computes the unknown counts of each table in patches, jobs at a
+// time, with the backend. Progress is saved as patches complete so an
+// interrupted render picks up where it left off.
+func render(args []string, defaultManifest string) error {
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	manifest := flags.String("manifest", defaultManifest, "lookup table manifest")
+	name := flags.String("name", "", "only render the table with this name")
+	backendName := flags.String("backend", zeta.AutoBackend, "backend patches are computed with: "+strings.Join(zeta.Backends(), ", ")+" or auto for the fastest available")
+	patchWidth := flags.Int("patch", 500, "width of the patches tables are computed in")
+	jobs := flags.Int("jobs", 1, "patches computed at once, raise this for a pool of remote workers")
+	saveEvery := flags.Duration("save-every", time.Minute, "how often progress is saved")
+	flags.Parse(args)
+
+	if *patchWidth < 1 || *jobs < 1 {
+		return errors.New("-patch and -jobs must be at least 1")
+	}
+
+	backend, err := zeta.NewBackend(*backendName)
+	if err != nil {
+		return err
+	}
+
+	set, err := zeta.ReadLUTManifest(*manifest)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		log.Println("[lut] stopping, saving progress ...")
+		cancel()
+	}()
+
+	rendered := 0
+	for _, l := range set.LUTs {
+		if *name != "" && l.Name != *name {
+			continue
+		}
+		rendered++
+
+		if err := renderLUT(ctx, set, l, backend, *patchWidth, *jobs, *saveEvery); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+
+	if rendered == 0 {
+		return fmt.Errorf("no table named %q in %s", *name, *manifest)
+	}
+	return nil
+}
+
+func renderLUT(ctx context.Context, set *zeta.LUTSet, l *zeta.LUT, backend zeta.Backend, patchWidth, jobs int, saveEvery time.Duration) error {
+	fname := set.Path(l)
+	if _, err := os.Stat(fname); err == nil {
+		if err := l.Load(fname); err != nil {
+			return err
+		}
+	}
+
+	patches := l.Patches(patchWidth)
+	w, h := l.Size()
+	log.Println("[lut]", l.Name, w, "x", h, "at", l.PPU, "pixels per unit:", len(patches), "patches to compute with the", backend.Name(), "backend")
+	if len(patches) == 0 {
+		return nil
+	}
+
+	queue := make(chan *zeta.Tile, len(patches))
+	for _, p := range patches {
+		queue <- p
+	}
+	close(queue)
+
+	done := make(chan *zeta.Tile)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range queue {
+				if err := backend.Compute(ctx, p); err != nil {
+					if ctx.Err() == nil {
+						log.Println("[lut] failed to compute patch", p.X, p.Y, "of", l.Name, err)
+					}
+					continue
+				}
+				done <- p
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	start := time.Now()
+	saved := time.Now()
+	count := 0
+	for p := range done {
+		l.SetPatch(p)
+		count++
+
+		elapsed := time.Since(start)
+		remaining := time.Duration(float64(elapsed) / float64(count) * float64(len(patches)-count))
+		log.Printf("[lut] %s: %d of %d patches, %s remaining", l.Name, count, len(patches), remaining.Round(time.Second))
+
+		if time.Since(saved) > saveEvery {
+			if err := l.Save(fname); err != nil {
+				return err
+			}
+			saved = time.Now()
+		}
+	}
+
+	if err := l.Save(fname); err != nil {
+		return err
+	}
+	if count < len(patches) {
+		log.Println("[lut]", l.Name, "saved", count, "of", len(patches), "patches to", fname, "run again to finish it")
+		if ctx.Err() == nil {
+			return fmt.Errorf("%d patches of %s failed", len(patches)-count, l.Name)
+		}
+		return nil
+	}
+
+	log.Println("[lut]", l.Name, "rendered in", time.Since(start), "saved", fname)
+	return nil
+}
+
+// build fills each table from the tiles at the first zoom level at least as
+// fine as the table. Tables already built are only filled where unknown, so
+// running it again after more tiles are generated completes them.
+func build(args []string, defaultManifest string) error {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	manifest := flags.String("manifest", defaultManifest, "lookup table manifest")
+	name := flags.String("name", "", "only build the table with this name")
+	zoom := flags.Int("zoom", -1, "zoom level of the tiles to read, by default the first at least as fine as the table")
+	flags.Parse(args)
+
+	if os.Getenv("ZETA_TILE_PATH") == "" {
+		return errors.New("ZETA_TILE_PATH is not exported")
+	}
+
+	set, err := zeta.ReadLUTManifest(*manifest)
+	if err != nil {
+		return err
+	}
+
+	built := 0
+	for _, l := range set.LUTs {
+		if *name != "" && l.Name != *name {
+			continue
+		}
+		built++
+
+		fname := set.Path(l)
+		if _, err := os.Stat(fname); err == nil {
+			if err := l.Load(fname); err != nil {
+				return err
+			}
+		}
+
+		z := *zoom
+		if z < 0 {
+			z = int(math.Ceil(math.Log2(l.PPU)))
+		}
+
+		filled, err := l.Fill(z)
+		if err != nil {
+			return err
+		}
+
+		known := 0
+		for _, its := range l.Counts() {
+			if its != 0 {
+				known++
+			}
+		}
+
+		if err := l.Save(fname); err != nil {
+			return err
+		}
+		w, h := l.Size()
+		log.Printf("[lut] %s: filled %d counts from zoom %d, %.1f%% of %dx%d known, saved %s",
+			l.Name, filled, z, 100*float64(known)/float64(w*h), w, h, fname)
+	}
+
+	if built == 0 {
+		return fmt.Errorf("no table named %q in %s", *name, *manifest)
+	}
+	return nil
+}
+
+// report computes each tile with and without the lookup tables
+func report(args []string, defaultManifest string) error {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	manifest := flags.String("manifest", defaultManifest, "lookup table manifest")
+	tiles := flags.String("tiles", "4/0/0,4/-1/1,6/2/3,8/3/-20", "tiles to compare as zoom/x/y, comma separated")
+	width := flags.Int("width", zeta.TileWidth, "width of the tiles in pixels")
+	flags.Parse(args)
+
+	set, err := zeta.LoadLUTs(*manifest)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-16s %10s %9s %12s %12s %8s\n", "tile", "mismatched", "max diff", "full", "assisted", "speedup")
+
+	mismatched, pixels := 0, 0
+	for _, spec := range strings.Split(*tiles, ",") {
+		t, err := parseTile(spec, *width)
+		if err != nil {
+			return err
+		}
+
+		r := zeta.CompareLUT(context.Background(), t, set)
+		fmt.Printf("%-16s %9.2f%% %9d %12s %12s %7.1fx\n", spec, 100*r.MismatchRate(), r.MaxDiff,
+			r.Full, r.Assisted, r.Full.Seconds()/r.Assisted.Seconds())
+
+		mismatched += r.Mismatched
+		pixels += t.Width * t.Width
+	}
+
+	fmt.Printf("%.2f%% of %d pixels differ\n", 100*float64(mismatched)/float64(pixels), pixels)
+	return nil
+}
+
+func parseTile(spec string, width int) (*zeta.Tile, error) {
+	tok := strings.Split(strings.TrimSpace(spec), "/")
+	if len(tok) != 3 {
+		return nil, fmt.Errorf("expected zoom/x/y but got %q", spec)
+	}
+
+	v := make([]int, 3)
+	for i := range tok {
+		n, err := strconv.Atoi(tok[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid tile %q: %v", spec, err)
+		}
+		v[i] = n
+	}
+	return &zeta.Tile{Zoom: v[0], X: v[1], Y: v[2], Width: width}, nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"zetamachine/pkg/palette"
+	"zetamachine/pkg/zeta"
+
+	"github.com/joho/godotenv"
+)
+
+// recover rebuilds the iteration data of tiles whose .dat.gz files are lost
+// from the PNG images rendered from them
+func main() {
+	paletteName := flag.String("palette", "original", "palette the images were rendered with, by name or palette file")
+	force := flag.Bool("force", false, "overwrite tile data that already exists")
+	strict := flag.Bool("strict", false, "skip images with colours that are not in the palette")
+	dryRun := flag.Bool("dry-run", false, "decode the images and report without writing anything")
+	orientation := flag.String("orientation", "", "which way up the images were drawn: up, with the imaginary axis up as drawn now, or legacy, upside down as drawn before that was fixed")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: recover [flags] <png file or directory>...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := checkEnv(); err != nil {
+		log.Fatal(err)
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// guessing wrong would write the data upside down
+	if *orientation != "up" && *orientation != "legacy" {
+		log.Fatal("-orientation must be up or legacy; images drawn before the imaginary axis pointed up are legacy")
+	}
+	legacy := *orientation == "legacy"
+
+	colors, ok := palette.Palettes[*paletteName]
+	if !ok {
+		var err error
+		if colors, err = palette.Load(*paletteName); err != nil {
+			log.Fatal("unknown palette ", *paletteName, ": ", err)
+		}
+	}
+	rev := palette.NewReverse(colors)
+	reportAmbiguous(rev)
+
+	files, err := pngFiles(flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	root := os.Getenv("ZETA_TILE_PATH")
+	widths := make(map[string]int) // the width of each set's tiles once known
+
+	var written, skipped, failed, ambiguous, unknown int
+	for _, fname := range files {
+		t, err := zeta.ParseFilename(filepath.Base(fname))
+		if err != nil {
+			log.Println("[recover] skipping", fname, err)
+			failed++
+			continue
+		}
+
+		if _, err := t.Exists(); err == nil && !*force {
+			skipped++
+			continue
+		}
+
+		stats, err := decode(fname, t, rev, legacy)
+		if err != nil {
+			log.Println("[recover] failed to decode", fname, err)
+			failed++
+			continue
+		}
+
+		// an image drawn at another size can't be stored in the set
+		width, ok := widths[t.Set()]
+		if !ok {
+			m, saved, err := zeta.ResolveMetadata(root, t.Set(), t.Width)
+			if err != nil {
+				log.Println("[recover] skipping", fname, err)
+				failed++
+				continue
+			}
+			if !saved && !*dryRun {
+				if err := zeta.SaveMetadata(root, t.Set(), m); err != nil {
+					log.Fatal(err)
+				}
+			}
+			width = m.TileWidth
+			widths[t.Set()] = width
+		}
+		if t.Width != width {
+			log.Println("[recover] skipping", fname, "which is", t.Width, "pixels wide in a set of", width, "pixel tiles")
+			failed++
+			continue
+		}
+
+		ambiguous += stats.Ambiguous
+		unknown += stats.Unknown
+
+		if stats.Unknown > 0 {
+			log.Println("[recover]", fname, "has", stats.Unknown, "pixels with colours not in the palette")
+			if *strict {
+				failed++
+				continue
+			}
+		}
+
+		if *dryRun {
+			continue
+		}
+		if err := t.Save(); err != nil {
+			log.Fatal(err)
+		}
+		written++
+	}
+
+	log.Printf("[recover] %d images: %d tiles written, %d already had data, %d failed", len(files), written, skipped, failed)
+	log.Printf("[recover] %d ambiguous pixels were given the lowest count of their colour, %d unknown the nearest colour", ambiguous, unknown)
+}
+
+func decode(fname string, t *zeta.Tile, rev *palette.Reverse, legacy bool) (zeta.DecodeStats, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return zeta.DecodeStats{}, err
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return zeta.DecodeStats{}, err
+	}
+	return t.Decode(img, rev, legacy)
+}
+
+// pngFiles lists the PNG files given and those in the directories given
+func pngFiles(args []string) ([]string, error) {
+	files := []string{}
+	for _, arg := range args {
+		err := filepath.Walk(arg, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && strings.EqualFold(filepath.Ext(p), ".png") {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// reportAmbiguous lists the colours that can't be decoded exactly
+func reportAmbiguous(rev *palette.Reverse) {
+	amb := rev.Ambiguous()
+	lines := []string{}
+	for c, counts := range amb {
+		lines = append(lines, fmt.Sprintf("#%02x%02x%02x counts %d to %d (%d)", c.R, c.G, c.B, counts[0], counts[len(counts)-1], len(counts)))
+	}
+	sort.Strings(lines)
+
+	log.Println("[recover]", len(amb), "colours are ambiguous and decode to their lowest count:")
+	for _, l := range lines {
+		log.Println("\t", l)
+	}
+}
+
+func checkEnv() error {
+	godotenv.Load()
+
+	if os.Getenv("ZETA_TILE_PATH") == "" {
+		return errors.New("ZETA_TILE_PATH is not exported")
+	}
+	return nil
+}
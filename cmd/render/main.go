@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"zetamachine/pkg/palette"
+	"zetamachine/pkg/rendition"
+	"zetamachine/pkg/seed"
+	"zetamachine/pkg/zeta"
+
+	"github.com/joho/godotenv"
+)
+
+// job is a stored tile with the renditions it needs drawn
+type job struct {
+	tile       *zeta.Tile
+	renditions []*rendition.Rendition
+}
+
+// render rebuilds the images of stored tiles from their iteration data, such
+// as after a palette changes or a rendition is added. Images newer than their
+// data are left alone so an interrupted run picks up where it left off.
+func main() {
+	renditionsFile := flag.String("renditions", "", "renditions file, by default ZETA_RENDITIONS or the store's default rendition")
+	names := flag.String("rendition", "", "only draw the renditions with these names, comma separated")
+	paletteName := flag.String("palette", "", "draw iteration tiles with this palette, by name or palette file, in place of each rendition's")
+	jobs := flag.Int("jobs", runtime.NumCPU(), "tiles drawn at once")
+	minZoom := flag.Int("min-zoom", math.MinInt32, "lowest zoom level to draw")
+	maxZoom := flag.Int("max-zoom", math.MaxInt32, "highest zoom level to draw")
+	region := flag.String("region", "", "only draw tiles intersecting minReal,minImag,maxReal,maxImag")
+	set := flag.String("set", "*", "only draw tiles of this set, empty for iterated zeta or * for every set")
+	force := flag.Bool("force", false, "draw images that are newer than their data")
+	flag.Parse()
+
+	if err := checkEnv(); err != nil {
+		log.Fatal(err)
+	}
+	if *jobs < 1 {
+		log.Fatal("-jobs must be at least 1")
+	}
+
+	renditions, err := loadRenditions(*renditionsFile, *names, *paletteName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var rect *seed.Rect
+	if *region != "" {
+		r, err := seed.ParseRect(*region)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rect = &r
+	}
+
+	root := os.Getenv("ZETA_TILE_PATH")
+	queue := []job{}
+	upToDate := 0
+	err = zeta.WalkStore(root, func(t *zeta.Tile, fname string, info os.FileInfo) error {
+		if t.Zoom < *minZoom || t.Zoom > *maxZoom {
+			return nil
+		}
+		if *set != "*" && t.Set() != *set {
+			return nil
+		}
+		if rect != nil && !rect.Intersects(t.Min(), t.Max()) {
+			return nil
+		}
+
+		stale := renditions
+		if !*force {
+			stale = staleRenditions(root, t, info, renditions)
+		}
+		if len(stale) == 0 {
+			upToDate++
+			return nil
+		}
+
+		queue = append(queue, job{tile: t, renditions: stale})
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("[render]", len(queue), "tiles to draw,", upToDate, "already up to date")
+
+	stop := make(chan struct{})
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		log.Println("[render] stopping, run again to finish")
+		close(stop)
+	}()
+
+	var drawn, failed int64
+	work := make(chan job)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < *jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range work {
+				if err := draw(root, j); err != nil {
+					log.Println("[render] failed to draw", j.tile, err)
+					atomic.AddInt64(&failed, 1)
+					continue
+				}
+				atomic.AddInt64(&drawn, 1)
+			}
+		}()
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+loop:
+	for i := 0; i < len(queue); {
+		select {
+		case work <- queue[i]:
+			i++
+		case <-stop:
+			break loop
+		case <-ticker.C:
+			progress(atomic.LoadInt64(&drawn)+atomic.LoadInt64(&failed), len(queue), start)
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	log.Printf("[render] drew %d tiles in %s, %d failed", drawn, time.Since(start).Round(time.Second), failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// loadRenditions reads the renditions to draw, keeping those named and
+// swapping in the palette if one is given
+func loadRenditions(fname, names, paletteName string) ([]*rendition.Rendition, error) {
+	var renditions []*rendition.Rendition
+	var err error
+	if fname != "" {
+		renditions, err = rendition.Load(fname)
+	} else {
+		renditions, err = rendition.FromEnv()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if names != "" {
+		keep := []*rendition.Rendition{}
+		for _, name := range strings.Split(names, ",") {
+			found := false
+			for _, r := range renditions {
+				if r.Name == strings.TrimSpace(name) {
+					keep = append(keep, r)
+					found = true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("there is no rendition named %q", name)
+			}
+		}
+		renditions = keep
+	}
+
+	if paletteName != "" {
+		_, named := palette.Palettes[paletteName]
+		for _, r := range renditions {
+			if named {
+				r.SetPalette(paletteName)
+				continue
+			}
+			if err := r.LoadPalette(paletteName); err != nil {
+				return nil, fmt.Errorf("%q is not a palette name or file: %v", paletteName, err)
+			}
+		}
+	}
+
+	for _, r := range renditions {
+		log.Println("[render] drawing rendition", r.Name, "with the", r.PaletteName(), "palette to", r.Path)
+	}
+	return renditions, nil
+}
+
+// staleRenditions returns the renditions whose image of the tile is missing
+// or older than its data
+func staleRenditions(root string, t *zeta.Tile, data os.FileInfo, renditions []*rendition.Rendition) []*rendition.Rendition {
+	stale := []*rendition.Rendition{}
+	for _, r := range renditions {
+		info, err := os.Stat(r.Filename(root, t))
+		if err != nil || !info.ModTime().After(data.ModTime()) {
+			stale = append(stale, r)
+		}
+	}
+	return stale
+}
+
+// draw loads the tile's data and writes its images
+func draw(root string, j job) error {
+	t := j.tile
+	if err := t.Load(); err != nil {
+		return err
+	}
+
+	// the width of the stored data, which need not be TileWidth
+	n := len(t.Data)
+	if t.Mode == zeta.ModeDomain {
+		n = len(t.Values) / 2
+	}
+	t.Width = int(math.Sqrt(float64(n)))
+	if n == 0 || t.Width*t.Width != n {
+		return fmt.Errorf("%d pixels of data is not a square tile", n)
+	}
+
+	for _, r := range j.renditions {
+		if err := r.Write(root, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func progress(done int64, total int, start time.Time) {
+	if done == 0 {
+		return
+	}
+	elapsed := time.Since(start)
+	rate := float64(done) / elapsed.Seconds()
+	remaining := time.Duration(float64(total-int(done)) / rate * float64(time.Second))
+	log.Printf("[render] %d of %d tiles, %.1f tiles/s, %s remaining", done, total, rate, remaining.Round(time.Second))
+}
+
+func checkEnv() error {
+	godotenv.Load()
+
+	if os.Getenv("ZETA_TILE_PATH") == "" {
+		return errors.New("ZETA_TILE_PATH is not exported")
+	}
+	return nil
+}
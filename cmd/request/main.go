@@ -6,9 +6,12 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 	"zetamachine/pkg/seed"
+	"zetamachine/pkg/zeta"
 
 	"github.com/briandowns/spinner"
 	"github.com/go-chi/valve"
@@ -21,16 +24,44 @@ var (
 	host, port string
 )
 
-func main() {
-	if err := checkEnv(); err != nil {
-		log.Fatal(err)
-	}
+// rectFlags collects repeated -region flags
+type rectFlags []string
+
+func (r *rectFlags) String() string {
+	return strings.Join(*r, " ")
+}
 
+func (r *rectFlags) Set(s string) error {
+	*r = append(*r, s)
+	return nil
+}
+
+func main() {
 	minZoom := flag.Int("min-zoom", 1, "minimum zoom to start checking for missing tiles")
 	maxZoom := flag.Int("max-zoom", 1, "maximum zoom level to generate tiles")
 	bulbOnly := flag.Bool("bulb-only", true, "only generate the bulb")
+	regionFile := flag.String("regions", "", "GeoJSON-like file of regions (in complex coordinates) to request instead of the bulb and arms")
+	var rects rectFlags
+	flag.Var(&rects, "region", "rectangle minReal,minImag,maxReal,maxImag to request instead of the bulb and arms (may be repeated)")
+	orderName := flag.String("order", "raster", "order tiles are requested in within a zoom: raster, spiral, hilbert or popular")
+	focus := flag.String("focus", "0,0", "real,imag point the spiral and popular orders start from")
+	accessLog := flag.String("access-log", "", "web server access log used to rank tiles for the popular order")
+	function := flag.String("function", "", "function to render: "+strings.Join(zeta.Functions(), ", ")+" (default zeta)")
+	params := flag.String("params", "", "function parameters as name=value,... e.g. a=0.5 for hurwitz")
+	mode := flag.String("mode", "", "render mode: empty for the iteration count, newton for root basins or domain for domain colouring")
+	sampling := flag.String("sampling", "", "where pixels are sampled: empty for the corner, centre, ss<n><mean|majority> for n×n supersampling or adapt<n><mean|majority> to supersample edges only")
+	lut := flag.Bool("lut", false, "request the zeta iteration set computed with the generators' lookup tables (ZETA_LUT_MANIFEST), stored apart as zeta_lut")
+	width := flag.Int("width", 0, "width of the tiles of a new set in pixels (default the set's width, 512 for a new set)")
+	tiers := flag.Int("tiers", 1, "number of priority tier topics to split each zoom level across; the first tiles of every zoom level go ahead of the last tiles of any")
+	plan := flag.Bool("plan", false, "print the tiles and estimated compute cost for each zoom without publishing anything")
+	planFormat := flag.String("plan-format", "table", "plan output format: table or json")
+	termCost := flag.Duration("term-cost", 0, "time to sum a single zeta term for -plan (measured if not set)")
 	flag.Parse()
 
+	if err := checkEnv(*plan); err != nil {
+		log.Fatal(err)
+	}
+
 	log.Println("Arguments  min-zoom:", *minZoom, "max-zoom:", *maxZoom, "bulb only: ", *bulbOnly)
 
 	if *minZoom <= 0 {
@@ -41,12 +72,99 @@ func main() {
 		log.Fatal("max-zoom must be greater than zero")
 	}
 
-	var err error
+	regions := []seed.RegionSpec{}
+	for _, r := range rects {
+		rect, err := seed.ParseRect(r)
+		if err != nil {
+			log.Fatal(err)
+		}
+		regions = append(regions, seed.RegionSpec{Name: r, MinZoom: *minZoom, MaxZoom: *maxZoom, Region: rect})
+	}
+
+	if *regionFile != "" {
+		specs, err := seed.LoadRegions(*regionFile, *minZoom, *maxZoom)
+		if err != nil {
+			log.Fatal(err)
+		}
+		regions = append(regions, specs...)
+	}
+
+	for _, spec := range regions {
+		if spec.MinZoom <= 0 || spec.MaxZoom < spec.MinZoom {
+			log.Fatal("invalid zoom range for region ", spec.Name, ": ", spec.MinZoom, "-", spec.MaxZoom)
+		}
+	}
+
+	focusPoint, err := parseFocus(*focus)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	order, err := seed.ParseOrder(*orderName, focusPoint, *accessLog)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	funcParams, err := zeta.ParseParams(*params)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	f, err := zeta.NewFunction(*function, funcParams)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := zeta.CheckMode(*mode, f); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := zeta.CheckSampling(*mode, *sampling); err != nil {
+		log.Fatal(err)
+	}
+
+	if *width < 0 {
+		log.Fatal("width must not be negative")
+	}
+
+	// a tile of the set checks the lookup tables can be used for it
+	setTile := &zeta.Tile{Function: *function, Params: funcParams, Mode: *mode, Sampling: *sampling, LUT: *lut}
+	if _, err := setTile.Func(); err != nil {
+		log.Fatal(err)
+	}
+
+	set := setTile.Set()
+	meta, err := setMetadata(set, *width, *plan)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("[request] set:", set, "tile width:", meta.TileWidth)
+
+	coverage := &seed.Coverage{
+		MinZoom:  *minZoom,
+		MaxZoom:  *maxZoom,
+		BulbOnly: *bulbOnly,
+		Regions:  regions,
+		Function: *function,
+		Params:   funcParams,
+		Mode:     *mode,
+		Sampling: *sampling,
+		LUT:      *lut,
+		Width:    meta.TileWidth,
+	}
+
+	if *plan {
+		if err := writePlan(coverage, *planFormat, *termCost); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	v := valve.New()
 	spin := spinner.New(spinner.CharSets[43], 100*time.Millisecond)
 	spin.Start()
 
-	server, err := seed.NewRequester(v, *minZoom, *maxZoom, *bulbOnly)
+	server, err := seed.NewRequester(v, coverage, order, *tiers)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -66,9 +184,62 @@ func main() {
 	v.Shutdown(10 * time.Second)
 }
 
-func checkEnv() error {
+// setMetadata returns the metadata of the set, which decides the width of its
+// tiles. A new set is given its metadata before its first tile is requested,
+// unless only planning.
+func setMetadata(set string, width int, plan bool) (*zeta.Metadata, error) {
+	root := os.Getenv("ZETA_TILE_PATH")
+	if !plan {
+		return zeta.InitMetadata(root, set, width)
+	}
+	m, _, err := zeta.ResolveMetadata(root, set, width)
+	return m, err
+}
+
+// parseFocus parses a point given as "real,imag"
+func parseFocus(s string) (complex128, error) {
+	tok := strings.Split(s, ",")
+	if len(tok) != 2 {
+		return 0, errors.New("focus must be given as real,imag")
+	}
+
+	re, err := strconv.ParseFloat(strings.TrimSpace(tok[0]), 64)
+	if err != nil {
+		return 0, err
+	}
+	im, err := strconv.ParseFloat(strings.TrimSpace(tok[1]), 64)
+	if err != nil {
+		return 0, err
+	}
+	return complex(re, im), nil
+}
+
+// writePlan prints the plan for the coverage to stdout
+func writePlan(coverage *seed.Coverage, format string, termCost time.Duration) error {
+	if format != "table" && format != "json" {
+		return errors.New("plan-format must be table or json")
+	}
+
+	plan := seed.NewPlan(coverage, zeta.NewCostModel(termCost))
+	if format == "json" {
+		return plan.WriteJSON(os.Stdout)
+	}
+	return plan.WriteTable(os.Stdout)
+}
+
+// checkEnv makes sure the environment is configured. Planning only looks at
+// the tile store so it does not need NSQ.
+func checkEnv(plan bool) error {
 	godotenv.Load()
 
+	if os.Getenv("ZETA_TILE_PATH") == "" {
+		return errors.New("ZETA_TILE_PATH is not exported")
+	}
+
+	if plan {
+		return nil
+	}
+
 	if os.Getenv("ZETA_NSQLOOKUP") == "" {
 		return errors.New("ZETA_NSQLOOKUP is not exported")
 	}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"zetamachine/pkg/zeta"
+
+	"github.com/joho/godotenv"
+)
+
+// job is a stored tile and its data file
+type job struct {
+	tile  *zeta.Tile
+	fname string
+}
+
+// retile copies the tiles in the store into a new store at -out, split into
+// narrower tiles such as 256 pixel tiles from 512 pixel ones. The new tiles
+// are at the same zoom levels with exactly the same pixels, and each set in
+// the new store gets metadata with the new width. Images aren't copied; draw
+// them from the new store with cmd/render.
+func main() {
+	width := flag.Int("width", 256, "width of the new tiles in pixels, which must divide the width of the stored tiles")
+	out := flag.String("out", "", "directory of the new store")
+	set := flag.String("set", "*", "only retile tiles of this set, empty for iterated zeta or * for every set")
+	jobs := flag.Int("jobs", runtime.NumCPU(), "tiles split at once")
+	force := flag.Bool("force", false, "overwrite tiles already in the new store")
+	flag.Parse()
+
+	if err := checkEnv(); err != nil {
+		log.Fatal(err)
+	}
+	if *jobs < 1 {
+		log.Fatal("-jobs must be at least 1")
+	}
+	if *width < 1 {
+		log.Fatal("-width must be at least 1")
+	}
+
+	root := os.Getenv("ZETA_TILE_PATH")
+	if *out == "" {
+		log.Fatal("-out is required")
+	}
+	if in, err := within(*out, root); err != nil || in {
+		log.Fatal("-out must be a new directory outside ZETA_TILE_PATH")
+	}
+
+	queue := []job{}
+	sets := make(map[string]int)
+	err := zeta.WalkStore(root, func(t *zeta.Tile, fname string, info os.FileInfo) error {
+		if *set != "*" && t.Set() != *set {
+			return nil
+		}
+		if t.Width%*width != 0 {
+			return errors.New("set " + t.Set() + " has tiles that can't be split evenly")
+		}
+		sets[t.Set()] = t.Width
+		queue = append(queue, job{tile: t, fname: fname})
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// the metadata is written first so the new store is never without it
+	for s, w := range sets {
+		if _, err := zeta.InitMetadata(*out, s, *width); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("[retile] set %q: %d pixel tiles into %d pixel tiles", s, w, *width)
+	}
+	log.Println("[retile]", len(queue), "tiles to split")
+
+	stop := make(chan struct{})
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		log.Println("[retile] stopping, run again to finish")
+		close(stop)
+	}()
+
+	var written, skipped, failed int64
+	work := make(chan job)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < *jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range work {
+				w, s, err := split(*out, j, *width, *force)
+				if err != nil {
+					log.Println("[retile] failed to split", j.fname, err)
+					atomic.AddInt64(&failed, 1)
+					continue
+				}
+				atomic.AddInt64(&written, int64(w))
+				atomic.AddInt64(&skipped, int64(s))
+			}
+		}()
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+loop:
+	for i := 0; i < len(queue); {
+		select {
+		case work <- queue[i]:
+			i++
+		case <-stop:
+			break loop
+		case <-ticker.C:
+			log.Printf("[retile] %d of %d tiles split", i, len(queue))
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	log.Printf("[retile] wrote %d tiles in %s, %d already there, %d stored tiles failed", written, time.Since(start).Round(time.Second), skipped, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// split loads a stored tile and writes the tiles it splits into to the store
+// at out. It returns how many were written and how many were already there.
+func split(out string, j job, width int, force bool) (int, int, error) {
+	t := j.tile
+	if p, err := t.Verify(j.fname, t.Width); p != zeta.ProblemNone {
+		return 0, 0, err
+	}
+
+	tiles, err := t.Split(width)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	written, skipped := 0, 0
+	for _, s := range tiles {
+		if _, err := os.Stat(path.Join(s.Dir(out), s.Filename())); err == nil && !force {
+			skipped++
+			continue
+		}
+		if err := s.SaveTo(out); err != nil {
+			return written, skipped, err
+		}
+		written++
+	}
+	return written, skipped, nil
+}
+
+// within reports whether dir is root or inside it
+func within(dir, root string) (bool, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false, err
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return false, err
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return false, err
+	}
+	return rel != ".." && !strings.HasPrefix(rel, "../"), nil
+}
+
+func checkEnv() error {
+	godotenv.Load()
+
+	if os.Getenv("ZETA_TILE_PATH") == "" {
+		return errors.New("ZETA_TILE_PATH is not exported")
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"zetamachine/pkg/palette"
+	"zetamachine/pkg/zeta"
+
+	"github.com/joho/godotenv"
+)
+
+// pair is two stored neighbouring tiles
+type pair struct {
+	a, b *zeta.Tile
+	edge string
+}
+
+// zoomStats totals the seams checked at a zoom level
+type zoomStats struct {
+	pairs, pixels, mismatched, seams int
+	maxGap                           float64
+}
+
+var (
+	mismatchColor = color.NRGBA{255, 0, 0, 255}
+	matchColor    = color.NRGBA{0, 255, 0, 255}
+)
+
+// seams samples pairs of neighbouring stored tiles, computes pixels along
+// the edge between them again and reports how many differ from the stored
+// data at each zoom level. Tiles computed at slightly different points from
+// their neighbours show up as mismatches along one side of the seam.
+func main() {
+	samples := flag.Int("samples", 8, "pixels computed along each side of an edge")
+	pairs := flag.Int("pairs", 20, "most pairs of neighbouring tiles checked at each zoom level")
+	tolerance := flag.Int("tolerance", 1, "iteration counts this close to the computed count match")
+	minZoom := flag.Int("min-zoom", math.MinInt32, "lowest zoom level to check")
+	maxZoom := flag.Int("max-zoom", math.MaxInt32, "highest zoom level to check")
+	set := flag.String("set", "", "set of tiles to check, empty for iterated zeta")
+	width := flag.Int("width", 0, "width of the stored tiles in pixels (default the width in the set's metadata)")
+	images := flag.String("images", "", "write an image of each seam with mismatches to this directory")
+	seed := flag.Int64("seed", 1, "seed for choosing the pairs checked")
+	jobs := flag.Int("jobs", runtime.NumCPU(), "pairs checked at once")
+	flag.Parse()
+
+	if err := checkEnv(); err != nil {
+		log.Fatal(err)
+	}
+	if *jobs < 1 {
+		log.Fatal("-jobs must be at least 1")
+	}
+
+	byZoom, err := findPairs(os.Getenv("ZETA_TILE_PATH"), *set, *minZoom, *maxZoom)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	zooms := []int{}
+	for zoom := range byZoom {
+		zooms = append(zooms, zoom)
+	}
+	sort.Ints(zooms)
+
+	rnd := rand.New(rand.NewSource(*seed))
+	queue := make(chan pair)
+	go func() {
+		defer close(queue)
+		for _, zoom := range zooms {
+			candidates := byZoom[zoom]
+			rnd.Shuffle(len(candidates), func(i, j int) {
+				candidates[i], candidates[j] = candidates[j], candidates[i]
+			})
+			if len(candidates) > *pairs {
+				candidates = candidates[:*pairs]
+			}
+			for _, p := range candidates {
+				queue <- p
+			}
+		}
+	}()
+
+	mu := &sync.Mutex{}
+	stats := make(map[int]*zoomStats)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < *jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range queue {
+				seam, err := check(p, *width, *samples, *tolerance)
+				if err != nil {
+					log.Println("[seams] failed to check", p.a, p.edge, err)
+					continue
+				}
+
+				if seam.Mismatched > 0 && *images != "" {
+					if err := writeImage(*images, seam); err != nil {
+						log.Println("[seams] failed to write image:", err)
+					}
+				}
+
+				mu.Lock()
+				s, ok := stats[p.a.Zoom]
+				if !ok {
+					s = &zoomStats{}
+					stats[p.a.Zoom] = s
+				}
+				s.pairs++
+				s.pixels += len(seam.Pixels)
+				s.mismatched += seam.Mismatched
+				if seam.Mismatched > 0 {
+					s.seams++
+				}
+				s.maxGap = math.Max(s.maxGap, math.Abs(seam.Gap))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	fmt.Printf("%5s %7s %8s %11s %9s %11s %12s\n", "zoom", "pairs", "pixels", "mismatched", "rate", "bad seams", "max gap px")
+	for _, zoom := range zooms {
+		s, ok := stats[zoom]
+		if !ok {
+			continue
+		}
+		fmt.Printf("%5d %7d %8d %11d %8.2f%% %11d %12.3g\n", zoom, s.pairs, s.pixels, s.mismatched,
+			100*float64(s.mismatched)/float64(s.pixels), s.seams, s.maxGap)
+	}
+}
+
+// findPairs lists every pair of neighbouring tiles of the set in the store by
+// zoom level
+func findPairs(root, set string, minZoom, maxZoom int) (map[int][]pair, error) {
+	stored := make(map[[3]int]*zeta.Tile)
+	err := zeta.WalkStore(root, func(t *zeta.Tile, fname string, info os.FileInfo) error {
+		if t.Set() == set && t.Zoom >= minZoom && t.Zoom <= maxZoom {
+			stored[[3]int{t.Zoom, t.X, t.Y}] = t
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byZoom := make(map[int][]pair)
+	for k, t := range stored {
+		if b, ok := stored[[3]int{k[0], k[1] + 1, k[2]}]; ok {
+			byZoom[k[0]] = append(byZoom[k[0]], pair{a: t, b: b, edge: zeta.EdgeRight})
+		}
+		if b, ok := stored[[3]int{k[0], k[1], k[2] + 1}]; ok {
+			byZoom[k[0]] = append(byZoom[k[0]], pair{a: t, b: b, edge: zeta.EdgeTop})
+		}
+	}
+
+	// map order is random, sort so the seed alone picks the pairs
+	for _, pairs := range byZoom {
+		sort.Slice(pairs, func(i, j int) bool {
+			if pairs[i].a.X != pairs[j].a.X {
+				return pairs[i].a.X < pairs[j].a.X
+			}
+			if pairs[i].a.Y != pairs[j].a.Y {
+				return pairs[i].a.Y < pairs[j].a.Y
+			}
+			return pairs[i].edge < pairs[j].edge
+		})
+	}
+	log.Println("[seams]", len(stored), "tiles stored with", len(byZoom), "zoom levels of neighbours")
+	return byZoom, nil
+}
+
+// check loads a copy of both tiles and checks the seam between them. The
+// tiles are as wide as their set's tiles unless width is given.
+func check(p pair, width, samples, tolerance int) (*zeta.Seam, error) {
+	a, b := *p.a, *p.b
+	for _, t := range []*zeta.Tile{&a, &b} {
+		if width != 0 {
+			t.Width = width
+		}
+		if err := t.Load(); err != nil {
+			return nil, err
+		}
+	}
+	return zeta.CheckSeam(&a, &b, p.edge, samples, tolerance)
+}
+
+// writeImage draws both tiles as they meet on the map, b right of or above
+// a, with the sampled pixels outlined in red if they mismatch and green if
+// not
+func writeImage(dir string, seam *zeta.Seam) error {
+	a, b := seam.A, seam.B
+	w := a.Width
+
+	size := image.Rect(0, 0, 2*w, w)
+	offsets := map[*zeta.Tile]image.Point{a: image.Pt(0, 0), b: image.Pt(w, 0)}
+	if seam.Edge == zeta.EdgeTop {
+		size = image.Rect(0, 0, w, 2*w)
+		offsets = map[*zeta.Tile]image.Point{a: image.Pt(0, w), b: image.Pt(0, 0)}
+	}
+	dst := image.NewNRGBA(size)
+
+	for _, t := range []*zeta.Tile{a, b} {
+		img, err := t.Render(palette.Original)
+		if err != nil {
+			return err
+		}
+		draw.Draw(dst, img.Bounds().Add(offsets[t]), img, image.Pt(0, 0), draw.Src)
+	}
+
+	for _, p := range seam.Pixels {
+		c := matchColor
+		if p.Mismatched {
+			c = mismatchColor
+		}
+		at := offsets[p.Tile]
+		outline(dst, at.X+p.X, at.Y+zeta.ImageRow(w, p.Y), c)
+	}
+
+	if err := os.MkdirAll(dir, os.ModeDir|os.ModePerm); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d.%d.%d-%s.png", a.Zoom, a.Y, a.X, seam.Edge)
+	if s := a.Set(); s != "" {
+		name = s + "-" + name
+	}
+	f, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, dst)
+}
+
+// outline draws a box around pixel x, y
+func outline(img *image.NRGBA, x, y int, c color.NRGBA) {
+	for d := -2; d <= 2; d++ {
+		img.SetNRGBA(x+d, y-2, c)
+		img.SetNRGBA(x+d, y+2, c)
+		img.SetNRGBA(x-2, y+d, c)
+		img.SetNRGBA(x+2, y+d, c)
+	}
+}
+
+func checkEnv() error {
+	godotenv.Load()
+
+	if os.Getenv("ZETA_TILE_PATH") == "" {
+		return errors.New("ZETA_TILE_PATH is not exported")
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	"zetamachine/pkg/rendition"
 	"zetamachine/pkg/seed"
 
 	"github.com/go-chi/valve"
@@ -24,8 +25,16 @@ func main() {
 		log.Fatal(err)
 	}
 
+	renditions, err := rendition.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, r := range renditions {
+		log.Println("[store] writing rendition", r.Name, "to", r.Path)
+	}
+
 	v := valve.New()
-	server, err := seed.NewStore(v)
+	server, err := seed.NewStore(v, renditions)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	"zetamachine/pkg/worker"
+	"zetamachine/pkg/zeta"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	godotenv.Load()
+
+	addr := flag.String("addr", ":8090", "address to listen on")
+	backendName := flag.String("backend", zeta.AutoBackend, "backend tiles are computed with: "+strings.Join(zeta.Backends(), ", ")+" or auto for the fastest available")
+	concurrency := flag.Int("concurrency", 1, "number of tiles computed at once")
+	flag.Parse()
+
+	backend, err := zeta.NewBackend(*backendName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	server := &http.Server{
+		Addr:    *addr,
+		Handler: worker.NewServer(backend, *concurrency).Routes(),
+	}
+
+	go func() {
+		log.Println("[worker] computing tiles with the", backend.Name(), "backend on", *addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
+
+	log.Println("[worker] Shutting down ...")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+	log.Println("[worker] done")
+}
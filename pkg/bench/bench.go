@@ -0,0 +1,190 @@
+package bench
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Result is the measurement of a single benchmark
+type Result struct {
+	Name        string  `json:"name"`
+	Iterations  int     `json:"iterations"`
+	NsPerOp     float64 `json:"nsPerOp"`
+	BytesPerOp  float64 `json:"bytesPerOp,omitempty"`
+	AllocsPerOp float64 `json:"allocsPerOp,omitempty"`
+}
+
+// Run is every result from one run of the benchmarks
+type Run struct {
+	Time      time.Time `json:"time"`
+	Commit    string    `json:"commit,omitempty"`
+	GoVersion string    `json:"goVersion,omitempty"`
+	Results   []Result  `json:"results"`
+}
+
+// benchLine matches a result line of go test -bench output, for example
+//
+//	BenchmarkZeta/arm-8   	   45876	     25605 ns/op	     0 B/op	       0 allocs/op
+var benchLine = regexp.MustCompile(`^(Benchmark\S+?)(?:-\d+)?\s+(\d+)\s+(.*)$`)
+
+// Parse reads the results from go test -bench output. Every other line is
+// ignored. If a benchmark was run more than once (-count) the fastest result
+// is kept.
+func Parse(r io.Reader) ([]Result, error) {
+	best := make(map[string]Result)
+	order := []string{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		m := benchLine.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+
+		n, _ := strconv.Atoi(m[2])
+		res := Result{Name: m[1], Iterations: n}
+
+		// the measurements are value unit pairs
+		fields := strings.Fields(m[3])
+		for i := 0; i+1 < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				continue
+			}
+			switch fields[i+1] {
+			case "ns/op":
+				res.NsPerOp = v
+			case "B/op":
+				res.BytesPerOp = v
+			case "allocs/op":
+				res.AllocsPerOp = v
+			}
+		}
+
+		prev, ok := best[res.Name]
+		if !ok {
+			order = append(order, res.Name)
+		}
+		if !ok || res.NsPerOp < prev.NsPerOp {
+			best[res.Name] = res
+		}
+	}
+
+	results := make([]Result, len(order))
+	for i, name := range order {
+		results[i] = best[name]
+	}
+	return results, scanner.Err()
+}
+
+// LoadRun reads a single run, such as a baseline, from a JSON file
+func LoadRun(fname string) (*Run, error) {
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &Run{}
+	return run, json.Unmarshal(b, run)
+}
+
+// SaveRun writes a single run to a JSON file
+func SaveRun(fname string, run *Run) error {
+	b, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fname, b, 0644)
+}
+
+// LoadHistory reads every run recorded in a history file. A missing file is
+// an empty history.
+func LoadHistory(fname string) ([]*Run, error) {
+	b, err := ioutil.ReadFile(fname)
+	if os.IsNotExist(err) {
+		return []*Run{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	history := []*Run{}
+	return history, json.Unmarshal(b, &history)
+}
+
+// AppendHistory adds the run to the end of the history file
+func AppendHistory(fname string, run *Run) error {
+	history, err := LoadHistory(fname)
+	if err != nil {
+		return err
+	}
+	history = append(history, run)
+
+	b, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fname, b, 0644)
+}
+
+// Comparison is the change in a benchmark from the baseline
+type Comparison struct {
+	Name      string
+	Baseline  float64 // ns/op, 0 if the benchmark is new
+	Current   float64 // ns/op
+	Change    float64 // fractional change in ns/op
+	Regressed bool
+}
+
+// Compare compares every benchmark of the current run with the baseline. A
+// benchmark has regressed if it is more than threshold (0.1 is 10%) slower.
+func Compare(baseline, current *Run, threshold float64) []Comparison {
+	base := make(map[string]float64)
+	for _, r := range baseline.Results {
+		base[r.Name] = r.NsPerOp
+	}
+
+	comps := []Comparison{}
+	for _, r := range current.Results {
+		c := Comparison{Name: r.Name, Baseline: base[r.Name], Current: r.NsPerOp}
+		if c.Baseline > 0 {
+			c.Change = c.Current/c.Baseline - 1
+			c.Regressed = c.Change > threshold
+		}
+		comps = append(comps, c)
+	}
+
+	sort.SliceStable(comps, func(i, j int) bool {
+		return comps[i].Name < comps[j].Name
+	})
+	return comps
+}
+
+// WriteComparison writes the comparisons as a table
+func WriteComparison(w io.Writer, comps []Comparison) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "benchmark\tbaseline ns/op\tcurrent ns/op\tchange\t\t")
+
+	for _, c := range comps {
+		base, change, flag := "-", "new", ""
+		if c.Baseline > 0 {
+			base = strconv.FormatFloat(c.Baseline, 'f', 0, 64)
+			change = fmt.Sprintf("%+.1f%%", 100*c.Change)
+		}
+		if c.Regressed {
+			flag = "REGRESSION"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.0f\t%s\t%s\t\n", c.Name, base, c.Current, change, flag)
+	}
+	return tw.Flush()
+}
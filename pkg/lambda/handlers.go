@@ -1,39 +1,226 @@
 package lambda
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"log"
+	"math"
+	"net/http"
+	"os"
+	"strings"
 	"time"
 	"zetamachine/pkg/zeta"
 
 	"github.com/aws/aws-lambda-go/events"
 )
 
-func Compute(req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+const (
+	// MaxZoom is the deepest zoom level a tile can be requested at
+	MaxZoom = 20
+
+	// MaxWidth is the widest tile that can be requested
+	MaxWidth = zeta.TileWidth
+
+	// maxResponseSize is the largest response body Lambda returns
+	maxResponseSize = 6 * 1024 * 1024
+
+	computeTimeout = 10 * time.Minute
+)
+
+// Error is the body of every error response. Field names the invalid part of
+// the request, if any.
+type Error struct {
+	Error string `json:"error"`
+	Field string `json:"field,omitempty"`
+}
+
+// Compute computes the tile in the JSON body of the request and responds with
+// it. Invalid requests get a 4xx response with an Error body. The response is
+// gzipped (and so base64 encoded) when the client accepts gzip.
+func Compute(ctx context.Context, req events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if req.HTTPMethod == http.MethodOptions {
+		return respond(req, http.StatusNoContent, nil), nil
+	}
+
+	body := []byte(req.Body)
+	if req.IsBase64Encoded {
+		b, err := base64.StdEncoding.DecodeString(req.Body)
+		if err != nil {
+			return errorResponse(req, http.StatusBadRequest, Error{Error: "invalid base64 body: " + err.Error()}), nil
+		}
+		body = b
+	}
+
 	var tile zeta.Tile
+	if err := json.Unmarshal(body, &tile); err != nil {
+		return errorResponse(req, http.StatusBadRequest, Error{Error: "invalid tile: " + err.Error()}), nil
+	}
 
-	if err := json.Unmarshal([]byte(req.Body), &tile); err != nil {
-		log.Println("Error unmarshalling tile: ", err)
-		return nil, err
+	if e := validate(&tile); e != nil {
+		return errorResponse(req, http.StatusBadRequest, *e), nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	ctx, cancel := context.WithTimeout(ctx, computeTimeout)
 	defer cancel()
 
-	tile.ComputeRequest(ctx)
+	backend := &zeta.CPUBackend{}
+	if err := backend.Compute(ctx, &tile); err != nil {
+		log.Println("[lambda] Error computing tile:", tile.String(), err)
+		return errorResponse(req, http.StatusInternalServerError, Error{Error: "failed to compute tile: " + err.Error()}), nil
+	}
 
 	jsonb, err := json.Marshal(tile)
 	if err != nil {
-		log.Println("Error marshalling tile: ", err)
-		return nil, err
+		log.Println("[lambda] Error marshalling tile:", err)
+		return errorResponse(req, http.StatusInternalServerError, Error{Error: "failed to encode tile"}), nil
+	}
+
+	return respond(req, http.StatusOK, jsonb), nil
+}
+
+// validate checks the tile can be computed, returning the problem if not. A
+// missing width defaults to MaxWidth.
+func validate(t *zeta.Tile) *Error {
+	if t.Zoom < 0 || t.Zoom > MaxZoom {
+		return &Error{Error: fmt.Sprintf("zoom must be between 0 and %d", MaxZoom), Field: "zoom"}
+	}
+
+	if t.Width == 0 {
+		t.Width = MaxWidth
+	}
+	if t.Width < 1 || t.Width > MaxWidth {
+		return &Error{Error: fmt.Sprintf("width must be between 1 and %d", MaxWidth), Field: "width"}
+	}
+
+	if r := t.Region; r != nil {
+		if !(r.Units > 0) || math.IsInf(r.Units, 0) || math.IsNaN(r.Min[0]+r.Min[1]) || math.IsInf(r.Min[0]+r.Min[1], 0) {
+			return &Error{Error: "region must have a finite corner and positive size", Field: "region"}
+		}
+	}
+
+	for k, v := range t.Params {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return &Error{Error: fmt.Sprintf("parameter %s must be finite", k), Field: "params"}
+		}
+	}
+
+	name := t.Function
+	if name == "" {
+		name = zeta.DefaultFunction
+	}
+	if !isFunction(name) {
+		return &Error{Error: fmt.Sprintf("unknown function %q", name), Field: "function"}
+	}
+
+	f, err := zeta.NewFunction(t.Function, t.Params)
+	if err != nil {
+		return &Error{Error: err.Error(), Field: "params"}
+	}
+	if err := zeta.CheckMode(t.Mode, f); err != nil {
+		return &Error{Error: err.Error(), Field: "mode"}
+	}
+	if err := zeta.CheckSampling(t.Mode, t.Sampling); err != nil {
+		return &Error{Error: err.Error(), Field: "sampling"}
+	}
+	if t.LUT {
+		return &Error{Error: "no lookup tables are loaded", Field: "lut"}
+	}
+
+	// computed tiles must not be sent back in
+	t.Data = nil
+	t.Values = nil
+	return nil
+}
+
+func isFunction(name string) bool {
+	for _, f := range zeta.Functions() {
+		if f == name {
+			return true
+		}
 	}
+	return false
+}
 
+func errorResponse(req events.APIGatewayProxyRequest, status int, e Error) *events.APIGatewayProxyResponse {
+	b, _ := json.Marshal(e)
+	return respond(req, status, b)
+}
+
+// respond builds a JSON response with CORS headers, gzipping the body if the
+// client accepts it
+func respond(req events.APIGatewayProxyRequest, status int, body []byte) *events.APIGatewayProxyResponse {
 	resp := &events.APIGatewayProxyResponse{
-		Headers:    map[string]string{"Content-Type": "application/json"},
-		StatusCode: 200,
-		Body:       string(jsonb),
+		StatusCode: status,
+		Headers:    corsHeaders(),
+	}
+	if body == nil {
+		return resp
+	}
+	resp.Headers["Content-Type"] = "application/json"
+
+	if !acceptsGzip(req) {
+		if len(body) > maxResponseSize {
+			return errorResponse(req, http.StatusRequestEntityTooLarge, Error{Error: "tile is too large to return, accept gzip encoding"})
+		}
+		resp.Body = string(body)
+		return resp
+	}
+
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	w.Write(body)
+	w.Close()
+
+	encoded := base64.StdEncoding.EncodeToString(buf.Bytes())
+	if len(encoded) > maxResponseSize {
+		return errorResponse(events.APIGatewayProxyRequest{}, http.StatusRequestEntityTooLarge, Error{Error: "tile is too large to return"})
+	}
+
+	resp.Headers["Content-Encoding"] = "gzip"
+	resp.Body = encoded
+	resp.IsBase64Encoded = true
+	return resp
+}
+
+// corsHeaders allow the tile API to be called from web pages on other
+// domains, ZETA_CORS_ORIGIN or any by default
+func corsHeaders() map[string]string {
+	origin := os.Getenv("ZETA_CORS_ORIGIN")
+	if origin == "" {
+		origin = "*"
+	}
+	return map[string]string{
+		"Access-Control-Allow-Origin":  origin,
+		"Access-Control-Allow-Methods": "POST, OPTIONS",
+		"Access-Control-Allow-Headers": "Content-Type, Accept-Encoding",
 	}
+}
 
-	return resp, nil
+// acceptsGzip checks the Accept-Encoding header, whose name may have any case
+func acceptsGzip(req events.APIGatewayProxyRequest) bool {
+	values := []string{}
+	for k, v := range req.Headers {
+		if strings.EqualFold(k, "Accept-Encoding") {
+			values = append(values, v)
+		}
+	}
+	for k, v := range req.MultiValueHeaders {
+		if strings.EqualFold(k, "Accept-Encoding") {
+			values = append(values, v...)
+		}
+	}
+
+	for _, v := range values {
+		for _, enc := range strings.Split(v, ",") {
+			enc = strings.TrimSpace(strings.SplitN(enc, ";", 2)[0])
+			if enc == "gzip" || enc == "*" {
+				return true
+			}
+		}
+	}
+	return false
 }
@@ -1,14 +1,199 @@
 package lambda
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
 	"testing"
+	"zetamachine/pkg/zeta"
+
+	"github.com/aws/aws-lambda-go/events"
 )
 
-/*
-Cross-origin resource sharing (CORS)
-CORS is required to call your API from a webpage that isn’t hosted on the same domain.
-To enable CORS for a REST API, set the Access-Control-Allow-Origin header in the
-response object that you return from your function code.
-*/
-func TestHandler(t *testing.T) {
+func request(t *testing.T, tile interface{}) events.APIGatewayProxyRequest {
+	b, err := json.Marshal(tile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Body: string(b)}
+}
+
+func invoke(t *testing.T, req events.APIGatewayProxyRequest) *events.APIGatewayProxyResponse {
+	resp, err := Compute(context.Background(), req)
+	if err != nil {
+		t.Fatal("handler returned an error, which API Gateway turns into a 502:", err)
+	}
+
+	// Cross-origin resource sharing (CORS) is required to call the API from a
+	// webpage that isn't hosted on the same domain
+	if resp.Headers["Access-Control-Allow-Origin"] == "" {
+		t.Fatal("response has no CORS headers")
+	}
+	return resp
+}
+
+// decode decodes a tile response, gunzipping it if needed
+func decode(t *testing.T, resp *events.APIGatewayProxyResponse, v interface{}) {
+	body := []byte(resp.Body)
+	if resp.IsBase64Encoded {
+		b, err := base64.StdEncoding.DecodeString(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = b
+	}
+
+	if resp.Headers["Content-Encoding"] == "gzip" {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, err = ioutil.ReadAll(zr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompute(t *testing.T) {
+	resp := invoke(t, request(t, zeta.Tile{Zoom: 4, X: 1, Y: 1, Width: 8}))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("status", resp.StatusCode, resp.Body)
+	}
+	if resp.IsBase64Encoded {
+		t.Fatal("response is encoded without Accept-Encoding")
+	}
+
+	tile := zeta.Tile{}
+	decode(t, resp, &tile)
+	if len(tile.Data) != 64 {
+		t.Fatalf("got %d pixels, want 64", len(tile.Data))
+	}
+}
+
+func TestValidateDefaultWidth(t *testing.T) {
+	tile := &zeta.Tile{Zoom: 4, X: 1, Y: 1}
+	if e := validate(tile); e != nil {
+		t.Fatal(e.Error)
+	}
+	if tile.Width != MaxWidth {
+		t.Fatalf("got width %d, want %d", tile.Width, MaxWidth)
+	}
+}
+
+func TestComputeGzip(t *testing.T) {
+	plain := invoke(t, request(t, zeta.Tile{Zoom: 4, X: 1, Y: 1, Width: 8}))
+
+	req := request(t, zeta.Tile{Zoom: 4, X: 1, Y: 1, Width: 8})
+	req.Headers = map[string]string{"accept-encoding": "deflate, gzip;q=1.0"}
+	resp := invoke(t, req)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("status", resp.StatusCode, resp.Body)
+	}
+	if !resp.IsBase64Encoded || resp.Headers["Content-Encoding"] != "gzip" {
+		t.Fatal("response is not gzipped")
+	}
+
+	var a, b zeta.Tile
+	decode(t, plain, &a)
+	decode(t, resp, &b)
+	for i := range a.Data {
+		if a.Data[i] != b.Data[i] {
+			t.Fatalf("gzipped tile differs at pixel %d", i)
+		}
+	}
+}
+
+func TestComputeBase64Request(t *testing.T) {
+	req := request(t, zeta.Tile{Zoom: 4, X: 1, Y: 1, Width: 4})
+	req.Body = base64.StdEncoding.EncodeToString([]byte(req.Body))
+	req.IsBase64Encoded = true
+
+	if resp := invoke(t, req); resp.StatusCode != http.StatusOK {
+		t.Fatal("status", resp.StatusCode, resp.Body)
+	}
+}
+
+func TestComputeInvalid(t *testing.T) {
+	cases := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"malformed", `{"zoom": `, ""},
+		{"wrong type", `{"zoom": "four"}`, ""},
+		{"negative zoom", `{"zoom": -1, "width": 8}`, "zoom"},
+		{"deep zoom", `{"zoom": 21, "width": 8}`, "zoom"},
+		{"negative width", `{"zoom": 4, "width": -8}`, "width"},
+		{"wide", `{"zoom": 4, "width": 4096}`, "width"},
+		{"unknown function", `{"zoom": 4, "width": 8, "function": "gamma"}`, "function"},
+		{"missing param", `{"zoom": 4, "width": 8, "function": "hurwitz"}`, "params"},
+		{"bad param", `{"zoom": 4, "width": 8, "function": "hurwitz", "params": {"a": 2}}`, "params"},
+		{"unexpected param", `{"zoom": 4, "width": 8, "params": {"a": 0.5}}`, "params"},
+		{"empty region", `{"zoom": 4, "width": 8, "region": {"min": [0, 0], "units": 0}}`, "region"},
+		{"unknown mode", `{"zoom": 4, "width": 8, "mode": "sepia"}`, "mode"},
+		{"no derivative", `{"zoom": 4, "width": 8, "function": "xi", "mode": "newton"}`, "mode"},
+		{"unknown sampling", `{"zoom": 4, "width": 8, "sampling": "ss3median"}`, "sampling"},
+		{"majority domain", `{"zoom": 4, "width": 8, "mode": "domain", "sampling": "ss2majority"}`, "sampling"},
+		{"lookup tables", `{"zoom": 4, "width": 8, "lut": true}`, "lut"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resp := invoke(t, events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Body: c.body})
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatal("status", resp.StatusCode, resp.Body)
+			}
+			if resp.Headers["Content-Type"] != "application/json" {
+				t.Fatal("error is not JSON")
+			}
+
+			e := Error{}
+			decode(t, resp, &e)
+			if e.Error == "" {
+				t.Fatal("error response has no message")
+			}
+			if e.Field != c.field {
+				t.Fatalf("error names field %q, want %q: %s", e.Field, c.field, e.Error)
+			}
+		})
+	}
+}
+
+func TestValidateInfiniteParam(t *testing.T) {
+	tile := &zeta.Tile{Zoom: 4, Width: 8, Function: "hurwitz", Params: map[string]float64{"a": math.Inf(1)}}
+	if e := validate(tile); e == nil || e.Field != "params" {
+		t.Fatal("infinite parameter was accepted:", e)
+	}
+}
+
+func TestComputePreflight(t *testing.T) {
+	resp := invoke(t, events.APIGatewayProxyRequest{HTTPMethod: http.MethodOptions})
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatal("status", resp.StatusCode)
+	}
+	if resp.Headers["Access-Control-Allow-Methods"] == "" {
+		t.Fatal("preflight response allows no methods")
+	}
+}
+
+func TestComputeCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	resp, err := Compute(ctx, request(t, zeta.Tile{Zoom: 4, X: 1, Y: 1, Width: 8}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatal("status", resp.StatusCode, resp.Body)
+	}
 }
@@ -0,0 +1,42 @@
+package palette
+
+import (
+	"image/color"
+	"math"
+	"math/cmplx"
+)
+
+// Domain colours a complex value for domain colouring. The hue is the
+// argument of z and the brightness rises with log|z| from black at zeros to
+// white at poles. Modulus contours are drawn where |z| passes a power of two
+// and phase contours every 30 degrees.
+func Domain(z complex128) color.Color {
+	if cmplx.IsNaN(z) {
+		return color.RGBA{0x0, 0x0, 0x0, 0xff}
+	}
+	if cmplx.IsInf(z) {
+		return color.RGBA{0xff, 0xff, 0xff, 0xff}
+	}
+
+	arg := cmplx.Phase(z) * 180 / math.Pi
+	logAbs := math.Log2(cmplx.Abs(z))
+
+	// 0 at zeros, 1/2 on the unit circle and 1 at poles
+	l := 0.5 + math.Atan(logAbs/4)/math.Pi
+
+	// darken the top of each band of modulus and phase
+	shade := 0.75 + 0.25*(1-fraction(logAbs))
+	shade *= 0.85 + 0.15*(1-fraction(arg/30))
+
+	// mix toward white above the unit circle so poles stand out
+	v, s := math.Min(1, 2*l), 1.0
+	if l > 0.5 {
+		s = 2 * (1 - l)
+	}
+	return HSV(arg, s, v*shade)
+}
+
+// fraction returns the fractional part of x in [0, 1)
+func fraction(x float64) float64 {
+	return x - math.Floor(x)
+}
@@ -0,0 +1,67 @@
+package palette
+
+import (
+	"image/color"
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+func TestDomain(t *testing.T) {
+	// on the unit circle at a multiple of 30 degrees the colour is the
+	// unshaded hue of the argument
+	hues := []struct {
+		z    complex128
+		want color.RGBA
+	}{
+		{1, color.RGBA{0xff, 0x00, 0x00, 0xff}},
+		{cmplx.Rect(1, math.Pi/3), color.RGBA{0xff, 0xff, 0x00, 0xff}},
+		{cmplx.Rect(1, 2*math.Pi/3), color.RGBA{0x00, 0xff, 0x00, 0xff}},
+		{-1, color.RGBA{0x00, 0xff, 0xff, 0xff}},
+	}
+	for _, h := range hues {
+		if got := Domain(h.z); got != h.want {
+			t.Errorf("Domain(%v) = %v, want %v", h.z, got, h.want)
+		}
+	}
+
+	black, white := color.RGBA{0x0, 0x0, 0x0, 0xff}, color.RGBA{0xff, 0xff, 0xff, 0xff}
+	if got := Domain(cmplx.NaN()); got != black {
+		t.Errorf("NaN is %v, want black", got)
+	}
+	if got := Domain(cmplx.Inf()); got != white {
+		t.Errorf("infinity is %v, want white", got)
+	}
+
+	// zeros fade to black and poles to shaded white whatever the argument
+	for _, arg := range []float64{0, 1, 2, -3} {
+		c := Domain(cmplx.Rect(1e-30, arg)).(color.RGBA)
+		if c.R > 0x20 || c.G > 0x20 || c.B > 0x20 {
+			t.Errorf("near zero at argument %v is %v, want nearly black", arg, c)
+		}
+		c = Domain(cmplx.Rect(1e30, arg)).(color.RGBA)
+		if c.R < 0xa0 || c.G < 0xa0 || c.B < 0xa0 || spread(c) > 0x10 {
+			t.Errorf("near a pole at argument %v is %v, want nearly white", arg, c)
+		}
+	}
+
+	// a modulus contour darkens the top of each band
+	below, above := Domain(1.999).(color.RGBA), Domain(2.001).(color.RGBA)
+	if below.R >= above.R {
+		t.Errorf("just below |z| = 2 is %v, not darker than just above %v", below, above)
+	}
+}
+
+// spread is the difference between the largest and smallest channel of c
+func spread(c color.RGBA) uint8 {
+	lo, hi := c.R, c.R
+	for _, v := range []uint8{c.G, c.B} {
+		if v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+	}
+	return hi - lo
+}
@@ -0,0 +1,55 @@
+package palette
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxColors is the most colours a palette file may list, one for each count
+// up to the largest a tile stores
+const maxColors = 256
+
+// Load reads a palette from a text file of one colour per line as hex
+// #rrggbb, the colour of count 0 first. Blank lines are skipped. Palettes
+// shorter than 256 colours draw every higher count in their last colour.
+func Load(fname string) ([]color.Color, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	colors := []color.Color{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		s := strings.TrimSpace(scanner.Text())
+		if s == "" {
+			continue
+		}
+
+		hex := strings.TrimPrefix(s, "#")
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || len(hex) != 6 {
+			return nil, fmt.Errorf("palette %s line %d: %q is not a #rrggbb colour", fname, line, s)
+		}
+		if len(colors) == maxColors {
+			return nil, fmt.Errorf("palette %s has more than %d colours", fname, maxColors)
+		}
+		colors = append(colors, color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(colors) == 0 {
+		return nil, fmt.Errorf("palette %s has no colours", fname)
+	}
+
+	for len(colors) < maxColors {
+		colors = append(colors, colors[len(colors)-1])
+	}
+	return colors, nil
+}
@@ -0,0 +1,58 @@
+package palette
+
+import (
+	"image/color"
+	"math"
+)
+
+// goldenAngle spreads consecutive root indexes around the colour wheel so
+// neighbouring basins never share a hue
+const goldenAngle = 137.50776405003785
+
+// Newton colours a pixel of a Newton's method tile. The hue identifies the
+// root the pixel converged to and the brightness falls off with the number
+// of iterations it took. Pixels that did not converge (root 0) are black.
+func Newton(root, its uint8) color.Color {
+	if root == 0 {
+		return color.RGBA{0x0, 0x0, 0x0, 0xff}
+	}
+
+	h := math.Mod(float64(root)*goldenAngle, 360)
+	v := 1 - 0.8*math.Min(float64(its)/40, 1)
+	return HSV(h, 0.8, v)
+}
+
+// HSV converts hue (degrees), saturation and value in [0, 1] to a colour
+func HSV(h, s, v float64) color.RGBA {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	return color.RGBA{
+		R: uint8(math.Round((r + m) * 255)),
+		G: uint8(math.Round((g + m) * 255)),
+		B: uint8(math.Round((b + m) * 255)),
+		A: 0xff,
+	}
+}
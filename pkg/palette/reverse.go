@@ -0,0 +1,80 @@
+package palette
+
+import (
+	"image/color"
+)
+
+// Palettes are the palettes tiles have been rendered with, by name
+var Palettes = map[string][]color.Color{
+	"original": Original,
+}
+
+// Reverse maps the colours of a palette back to the iteration counts they
+// were rendered from. Palettes repeat colours, Original renders 0 and 1 both
+// black and everything from 100 up magenta, so a colour may be ambiguous.
+type Reverse struct {
+	colors []color.RGBA
+	counts map[color.RGBA][]uint16
+}
+
+// NewReverse builds the reverse mapping of the palette
+func NewReverse(colors []color.Color) *Reverse {
+	r := &Reverse{counts: make(map[color.RGBA][]uint16)}
+	for i, c := range colors {
+		rgba := toRGBA(c)
+		r.colors = append(r.colors, rgba)
+		r.counts[rgba] = append(r.counts[rgba], uint16(i))
+	}
+	return r
+}
+
+// Lookup returns the lowest count rendered as the colour, whether other
+// counts are rendered as it too and whether it is in the palette at all.
+// Rendering the lowest count gives back the same colour.
+func (r *Reverse) Lookup(c color.Color) (its uint16, ambiguous, ok bool) {
+	counts := r.counts[toRGBA(c)]
+	if len(counts) == 0 {
+		return 0, false, false
+	}
+	return counts[0], len(counts) > 1, true
+}
+
+// Counts returns every count rendered as the colour
+func (r *Reverse) Counts(c color.Color) []uint16 {
+	return r.counts[toRGBA(c)]
+}
+
+// Nearest returns the lowest count rendered as the palette colour closest to
+// c, for colours that were changed by resampling or lossy compression
+func (r *Reverse) Nearest(c color.Color) uint16 {
+	rgba := toRGBA(c)
+
+	best, bestDist := 0, -1
+	for i, p := range r.colors {
+		dr := int(p.R) - int(rgba.R)
+		dg := int(p.G) - int(rgba.G)
+		db := int(p.B) - int(rgba.B)
+		if d := dr*dr + dg*dg + db*db; bestDist < 0 || d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return r.counts[r.colors[best]][0]
+}
+
+// Ambiguous returns the colours that more than one count is rendered as
+func (r *Reverse) Ambiguous() map[color.RGBA][]uint16 {
+	amb := make(map[color.RGBA][]uint16)
+	for c, counts := range r.counts {
+		if len(counts) > 1 {
+			amb[c] = counts
+		}
+	}
+	return amb
+}
+
+// toRGBA converts to opaque 8 bit colour, which is how tiles are rendered
+func toRGBA(c color.Color) color.RGBA {
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	rgba.A = 0xff
+	return rgba
+}
@@ -0,0 +1,84 @@
+package rendition
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/cmplx"
+	"zetamachine/pkg/zeta"
+)
+
+// heights treats the tile's data as terrain: the iteration count, the
+// iterations Newton's method took or log |f(s)| for domain colouring. They
+// are in the order of the tile's image.
+func heights(t *zeta.Tile) []float64 {
+	h := make([]float64, t.Width*t.Width)
+
+	for i := range h {
+		j := zeta.ImageRow(t.Width, i/t.Width)*t.Width + i%t.Width
+
+		switch t.Mode {
+		case zeta.ModeDomain:
+			z := t.DomainValue(i)
+			if v := math.Log(cmplx.Abs(z)); !math.IsNaN(v) && !math.IsInf(v, 0) {
+				h[j] = v
+			}
+		case zeta.ModeNewton:
+			_, its := zeta.NewtonData(t.Data[i])
+			h[j] = float64(its)
+		default:
+			h[j] = float64(t.Data[i])
+		}
+	}
+	return h
+}
+
+// hillshade darkens slopes facing away from a light in the top left and
+// lightens those facing it. Flat ground keeps its colour.
+func hillshade(src image.Image, h []float64, width int, strength float64) image.Image {
+	// light from the top left, 45 degrees above the horizon
+	lx, ly, lz := -0.5, -0.5, math.Sqrt2/2
+
+	at := func(x, y int) float64 {
+		if x < 0 {
+			x = 0
+		}
+		if x >= width {
+			x = width - 1
+		}
+		if y < 0 {
+			y = 0
+		}
+		if y >= width {
+			y = width - 1
+		}
+		return h[y*width+x]
+	}
+
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, width))
+	for y := 0; y < width; y++ {
+		for x := 0; x < width; x++ {
+			dx := (at(x+1, y) - at(x-1, y)) / 2
+			dy := (at(x, y+1) - at(x, y-1)) / 2
+
+			// the light falling on the surface relative to flat ground
+			n := math.Sqrt(dx*dx + dy*dy + 1)
+			light := (-dx*lx - dy*ly + lz) / n / lz
+			shade := math.Max(0, 1+strength*(light-1))
+
+			c := color.NRGBAModel.Convert(src.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: scale(c.R, shade),
+				G: scale(c.G, shade),
+				B: scale(c.B, shade),
+				A: c.A,
+			})
+		}
+	}
+	return dst
+}
+
+func scale(v uint8, f float64) uint8 {
+	return uint8(math.Min(255, math.Round(float64(v)*f)))
+}
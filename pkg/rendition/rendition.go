@@ -0,0 +1,359 @@
+package rendition
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"zetamachine/pkg/palette"
+	"zetamachine/pkg/zeta"
+)
+
+const (
+	// DefaultPath is where tile images are written unless a rendition says
+	// otherwise, relative to ZETA_TILE_PATH. The web page loads tiles from
+	// here.
+	DefaultPath = "{set}/{zoom}/{y}/{zoom}.{y}.{x}.{ext}"
+
+	defaultPalette = "original"
+	defaultQuality = 90
+	maxSize        = 4096
+)
+
+// Rendition is one way of drawing tiles to image files. Every tile the store
+// saves is drawn in each configured rendition so a static site can offer
+// several styles without rendering them again.
+type Rendition struct {
+	Name string `json:"name"`
+
+	// Palette names the palette iteration tiles are drawn with (see
+	// palette.Palettes), original by default. Newton and domain colouring
+	// tiles have their own colours.
+	Palette string `json:"palette,omitempty"`
+
+	// PaletteFile is a palette file (see palette.Load) to draw iteration
+	// tiles with in place of a named palette, relative to the renditions
+	// file
+	PaletteFile string `json:"paletteFile,omitempty"`
+
+	// Format is png, the default, jpeg or webp, which is always lossless.
+	// Quality is the jpeg quality, 90 by default.
+	Format  string `json:"format,omitempty"`
+	Quality int    `json:"quality,omitempty"`
+
+	// Size is the width of the image in pixels, the tile width by default.
+	// Use 256 for standard maps and 512 for retina screens.
+	Size int `json:"size,omitempty"`
+
+	// Hillshade shades the image as if the data were terrain lit from the
+	// top left, from 0 for none to 1 for the strongest
+	Hillshade float64 `json:"hillshade,omitempty"`
+
+	// Path is where images are written relative to ZETA_TILE_PATH. {set},
+	// {zoom}, {x}, {y}, {name} and {ext} are replaced with the tile's set
+	// and position, the rendition's name and the file extension of its
+	// format. DefaultPath by default.
+	Path string `json:"path,omitempty"`
+
+	// colors are the colours of PaletteFile
+	colors []color.Color
+}
+
+// config is the JSON file renditions are read from
+type config struct {
+	Renditions []*Rendition `json:"renditions"`
+}
+
+// Default is the single rendition the store has always written: the original
+// palette as a PNG the width of the tile next to the data
+func Default() []*Rendition {
+	r := &Rendition{Name: "default"}
+	r.setDefaults()
+	return []*Rendition{r}
+}
+
+// FromEnv loads the renditions listed in the file ZETA_RENDITIONS, or the
+// Default ones if it isn't set
+func FromEnv() ([]*Rendition, error) {
+	fname := os.Getenv("ZETA_RENDITIONS")
+	if fname == "" {
+		return Default(), nil
+	}
+	return Load(fname)
+}
+
+// Load reads and validates the renditions in a JSON file
+func Load(fname string) ([]*Rendition, error) {
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := config{}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid renditions %s: %v", fname, err)
+	}
+	if len(cfg.Renditions) == 0 {
+		return nil, fmt.Errorf("no renditions in %s", fname)
+	}
+
+	names := make(map[string]bool)
+	paths := make(map[string]string)
+	for _, r := range cfg.Renditions {
+		if r.PaletteFile != "" {
+			if r.Palette != "" {
+				return nil, fmt.Errorf("%s: rendition %s has both a palette and a palette file", fname, r.Name)
+			}
+			pf := r.PaletteFile
+			if !path.IsAbs(pf) {
+				pf = path.Join(path.Dir(fname), pf)
+			}
+			if err := r.LoadPalette(pf); err != nil {
+				return nil, fmt.Errorf("%s: rendition %s: %v", fname, r.Name, err)
+			}
+		}
+		r.setDefaults()
+		if err := r.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %v", fname, err)
+		}
+
+		if names[r.Name] {
+			return nil, fmt.Errorf("%s: more than one rendition is named %s", fname, r.Name)
+		}
+		names[r.Name] = true
+
+		// renditions with the same path would overwrite each other
+		p := strings.Replace(r.Path, "{ext}", r.Ext(), -1)
+		p = strings.Replace(p, "{name}", r.Name, -1)
+		if other, ok := paths[p]; ok {
+			return nil, fmt.Errorf("%s: renditions %s and %s are written to the same path", fname, other, r.Name)
+		}
+		paths[p] = r.Name
+	}
+
+	return cfg.Renditions, nil
+}
+
+// LoadPalette draws the rendition with the colours of a palette file in place
+// of its palette
+func (r *Rendition) LoadPalette(fname string) error {
+	colors, err := palette.Load(fname)
+	if err != nil {
+		return err
+	}
+	r.Palette = ""
+	r.PaletteFile = fname
+	r.colors = colors
+	return nil
+}
+
+// SetPalette draws the rendition with the named palette
+func (r *Rendition) SetPalette(name string) error {
+	if _, ok := palette.Palettes[name]; !ok {
+		return fmt.Errorf("unknown palette %q", name)
+	}
+	r.Palette = name
+	r.PaletteFile = ""
+	r.colors = nil
+	return nil
+}
+
+// PaletteName is the name of the rendition's palette or its palette file
+func (r *Rendition) PaletteName() string {
+	if r.PaletteFile != "" {
+		return r.PaletteFile
+	}
+	return r.Palette
+}
+
+func (r *Rendition) setDefaults() {
+	if r.Palette == "" && r.PaletteFile == "" {
+		r.Palette = defaultPalette
+	}
+	if r.Format == "" {
+		r.Format = "png"
+	}
+	r.Format = strings.ToLower(r.Format)
+	if r.Format == "jpg" {
+		r.Format = "jpeg"
+	}
+	if r.Quality == 0 {
+		r.Quality = defaultQuality
+	}
+	if r.Path == "" {
+		r.Path = DefaultPath
+	}
+}
+
+// Validate checks the rendition can be drawn
+func (r *Rendition) Validate() error {
+	if r.Name == "" {
+		return errors.New("rendition has no name")
+	}
+
+	switch r.Format {
+	case "png", "jpeg", "webp":
+	default:
+		return fmt.Errorf("rendition %s: unknown format %q, expected png, jpeg or webp", r.Name, r.Format)
+	}
+
+	if r.PaletteFile != "" {
+		if r.Palette != "" {
+			return fmt.Errorf("rendition %s has both a palette and a palette file", r.Name)
+		}
+		if r.colors == nil {
+			return fmt.Errorf("rendition %s: palette file %s is not loaded", r.Name, r.PaletteFile)
+		}
+	} else if _, ok := palette.Palettes[r.Palette]; !ok {
+		return fmt.Errorf("rendition %s: unknown palette %q", r.Name, r.Palette)
+	}
+	if r.Quality < 1 || r.Quality > 100 {
+		return fmt.Errorf("rendition %s: quality must be between 1 and 100", r.Name)
+	}
+	if r.Size < 0 || r.Size > maxSize {
+		return fmt.Errorf("rendition %s: size must be between 1 and %d, or 0 for the tile width", r.Name, maxSize)
+	}
+	if r.Hillshade < 0 || r.Hillshade > 1 {
+		return fmt.Errorf("rendition %s: hillshade must be between 0 and 1", r.Name)
+	}
+
+	for _, v := range []string{"{zoom}", "{x}", "{y}"} {
+		if !strings.Contains(r.Path, v) {
+			return fmt.Errorf("rendition %s: path %q needs %s so tiles don't overwrite each other", r.Name, r.Path, v)
+		}
+	}
+	return nil
+}
+
+// Ext is the file extension of the rendition's format
+func (r *Rendition) Ext() string {
+	if r.Format == "jpeg" {
+		return "jpg"
+	}
+	return r.Format
+}
+
+// Filename returns the path the tile's image is written to under root
+func (r *Rendition) Filename(root string, t *zeta.Tile) string {
+	p := strings.NewReplacer(
+		"{set}", t.Set(),
+		"{zoom}", strconv.Itoa(t.Zoom),
+		"{x}", strconv.Itoa(t.X),
+		"{y}", strconv.Itoa(t.Y),
+		"{name}", r.Name,
+		"{ext}", r.Ext(),
+	).Replace(r.Path)
+	return path.Join(root, p)
+}
+
+// Image draws the tile
+func (r *Rendition) Image(t *zeta.Tile) (image.Image, error) {
+	colors := r.colors
+	if colors == nil {
+		colors = palette.Palettes[r.Palette]
+	}
+	img, err := t.Render(colors)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Hillshade > 0 {
+		img = hillshade(img, heights(t), t.Width, r.Hillshade)
+	}
+
+	if r.Size != 0 && r.Size != t.Width {
+		img = resize(img, r.Size)
+	}
+	return img, nil
+}
+
+// Encode draws the tile in the rendition's format
+func (r *Rendition) Encode(w io.Writer, t *zeta.Tile) error {
+	img, err := r.Image(t)
+	if err != nil {
+		return err
+	}
+
+	switch r.Format {
+	case "jpeg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: r.Quality})
+	case "webp":
+		return encodeWebP(w, img)
+	}
+	return png.Encode(w, img)
+}
+
+// Write draws the tile to its file under root. The image is written to a
+// temporary file first so a half written image is never served.
+func (r *Rendition) Write(root string, t *zeta.Tile) error {
+	fname := r.Filename(root, t)
+	if err := os.MkdirAll(path.Dir(fname), os.ModeDir|os.ModePerm); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(path.Dir(fname), ".rendition-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := r.Encode(f, t); err != nil {
+		f.Close()
+		return fmt.Errorf("rendition %s: %v", r.Name, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), fname)
+}
+
+// resize scales the square image to size pixels wide, averaging the source
+// pixels under each pixel when shrinking
+func resize(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	width := b.Dx()
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+
+	for y := 0; y < size; y++ {
+		y0, y1 := span(y, size, width)
+		for x := 0; x < size; x++ {
+			x0, x1 := span(x, size, width)
+
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBAModel.Convert(src.At(b.Min.X+sx, b.Min.Y+sy)).(color.NRGBA)
+					r += uint32(c.R)
+					g += uint32(c.G)
+					bl += uint32(c.B)
+					a += uint32(c.A)
+					n++
+				}
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{uint8(r / n), uint8(g / n), uint8(bl / n), uint8(a / n)})
+		}
+	}
+	return dst
+}
+
+// span returns the source pixels under destination pixel i, at least one
+func span(i, dst, src int) (int, int) {
+	lo := i * src / dst
+	hi := (i + 1) * src / dst
+	if hi <= lo {
+		hi = lo + 1
+	}
+	return lo, hi
+}
@@ -0,0 +1,243 @@
+package rendition
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"zetamachine/pkg/palette"
+	"zetamachine/pkg/zeta"
+)
+
+func testTile() *zeta.Tile {
+	t := &zeta.Tile{Zoom: 4, X: -2, Y: 3, Width: 32}
+	t.Data = make([]uint16, 32*32)
+	for i := range t.Data {
+		t.Data[i] = uint16(i % 32)
+	}
+	return t
+}
+
+func writeConfig(t *testing.T, cfg string) string {
+	fname := filepath.Join(t.TempDir(), "renditions.json")
+	if err := ioutil.WriteFile(fname, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fname
+}
+
+func TestDefault(t *testing.T) {
+	tile := testTile()
+	r := Default()[0]
+
+	// where the store has always written PNGs
+	want := path.Join("/tiles", tile.Set(), "4/3", strings.TrimSuffix(tile.Filename(), ".dat.gz")+".png")
+	if got := r.Filename("/tiles", tile); got != want {
+		t.Fatalf("default rendition is written to %s, want %s", got, want)
+	}
+
+	tile.Function = "hurwitz"
+	tile.Params = map[string]float64{"a": 0.5}
+	if got := r.Filename("/tiles", tile); !strings.HasPrefix(got, "/tiles/hurwitz_a0.5/4/3/") {
+		t.Fatalf("tile set is not in the path %s", got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	renditions, err := Load(writeConfig(t, `{"renditions": [
+		{"name": "default"},
+		{"name": "retina", "size": 512, "hillshade": 0.5, "path": "{name}/{set}/{zoom}/{x}/{y}.{ext}"},
+		{"name": "small", "format": "JPG", "size": 256, "path": "{name}/{zoom}/{x}/{y}.{ext}"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(renditions) != 3 || renditions[2].Format != "jpeg" || renditions[2].Quality != defaultQuality {
+		t.Fatalf("defaults were not applied: %+v", renditions[2])
+	}
+	if got := renditions[2].Filename("/t", testTile()); got != "/t/small/4/-2/3.jpg" {
+		t.Fatal("small rendition is written to", got)
+	}
+
+	invalid := map[string]string{
+		"format":    `{"renditions": [{"name": "a", "format": "tiff"}]}`,
+		"palette":   `{"renditions": [{"name": "a", "palette": "sepia"}]}`,
+		"no file":   `{"renditions": [{"name": "a", "paletteFile": "sepia.txt"}]}`,
+		"both":      `{"renditions": [{"name": "a", "palette": "original", "paletteFile": "sepia.txt"}]}`,
+		"size":      `{"renditions": [{"name": "a", "size": -256}]}`,
+		"hillshade": `{"renditions": [{"name": "a", "hillshade": 2}]}`,
+		"path":      `{"renditions": [{"name": "a", "path": "{zoom}/{y}.png"}]}`,
+		"no name":   `{"renditions": [{"size": 256}]}`,
+		"same name": `{"renditions": [{"name": "a"}, {"name": "a", "path": "{name}/{zoom}/{x}/{y}.png"}]}`,
+		"same path": `{"renditions": [{"name": "a"}, {"name": "b", "size": 256}]}`,
+		"empty":     `{"renditions": []}`,
+		"not json":  `renditions`,
+	}
+	for name, cfg := range invalid {
+		if _, err := Load(writeConfig(t, cfg)); err == nil {
+			t.Errorf("%s: invalid renditions were loaded", name)
+		}
+	}
+}
+
+func TestPaletteFile(t *testing.T) {
+	fname := writeConfig(t, `{"renditions": [
+		{"name": "default"},
+		{"name": "grey", "paletteFile": "grey.txt", "path": "{name}/{zoom}/{x}/{y}.{ext}"}
+	]}`)
+
+	// a grey ramp over the first 32 counts, then white
+	grey := ""
+	for i := 0; i < 32; i++ {
+		grey += fmt.Sprintf("#%02x%02x%02x\n", i*8, i*8, i*8)
+	}
+	grey += "\n#ffffff\n"
+	if err := ioutil.WriteFile(filepath.Join(filepath.Dir(fname), "grey.txt"), []byte(grey), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	renditions, err := Load(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renditions[1].Palette != "" || renditions[1].PaletteName() != filepath.Join(filepath.Dir(fname), "grey.txt") {
+		t.Fatalf("palette file rendition has palette %q", renditions[1].PaletteName())
+	}
+
+	tile := testTile()
+	tile.Data[1] = 200
+	original, _ := renditions[0].Image(tile)
+	greyed, _ := renditions[1].Image(tile)
+	if color.NRGBAModel.Convert(original.At(5, 5)) == color.NRGBAModel.Convert(greyed.At(5, 5)) {
+		t.Fatal("the palette file draws the same colours as the original palette")
+	}
+	for i, c := range tile.Data {
+		want := color.NRGBA{0xff, 0xff, 0xff, 0xff}
+		if c < 32 {
+			want = color.NRGBA{uint8(c * 8), uint8(c * 8), uint8(c * 8), 0xff}
+		}
+		if got := greyed.At(i%32, zeta.ImageRow(32, i/32)); color.NRGBAModel.Convert(got) != want {
+			t.Fatalf("count %d is drawn %v, want %v", c, got, want)
+		}
+	}
+
+	// the named palette replaces the file
+	if err := renditions[1].SetPalette("original"); err != nil {
+		t.Fatal(err)
+	}
+	if img, _ := renditions[1].Image(tile); color.NRGBAModel.Convert(img.At(5, 5)) != color.NRGBAModel.Convert(original.At(5, 5)) {
+		t.Fatal("the named palette did not replace the palette file")
+	}
+
+	invalid := map[string]string{
+		"empty":      "\n\n",
+		"not hex":    "#00ff00\nsepia\n",
+		"short":      "#0f0\n",
+		"too many":   strings.Repeat("#000000\n", 257),
+		"with alpha": "#00ff00ff\n",
+	}
+	for name, colors := range invalid {
+		pf := filepath.Join(t.TempDir(), "palette.txt")
+		if err := ioutil.WriteFile(pf, []byte(colors), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := palette.Load(pf); err == nil {
+			t.Errorf("%s: invalid palette was loaded", name)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	root := t.TempDir()
+	tile := testTile()
+
+	r := &Rendition{Name: "retina", Size: 64, Path: "{name}/{zoom}/{x}/{y}.{ext}"}
+	r.setDefaults()
+	if err := r.Write(root, tile); err != nil {
+		t.Fatal(err)
+	}
+	img := decode(t, r.Filename(root, tile), png.Decode)
+	if img.Bounds().Dx() != 64 {
+		t.Fatal("image is", img.Bounds().Dx(), "wide, want 64")
+	}
+
+	r = &Rendition{Name: "small", Format: "jpeg", Size: 16, Path: "{name}/{zoom}/{x}/{y}.{ext}"}
+	r.setDefaults()
+	if err := r.Write(root, tile); err != nil {
+		t.Fatal(err)
+	}
+	img = decode(t, r.Filename(root, tile), jpeg.Decode)
+	if img.Bounds().Dx() != 16 {
+		t.Fatal("image is", img.Bounds().Dx(), "wide, want 16")
+	}
+
+	// nothing is left behind but the images
+	files, _ := filepath.Glob(filepath.Join(root, "*/4/-2/.rendition-*"))
+	if len(files) != 0 {
+		t.Fatal("temporary files were left:", files)
+	}
+}
+
+func TestResize(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i := 0; i < 16; i++ {
+		src.Set(i%4, i/4, color.NRGBA{uint8(100 * (i % 2)), 0, 0, 0xff})
+	}
+
+	// each pixel of the half size image averages two columns
+	small := resize(src, 2)
+	if c := color.NRGBAModel.Convert(small.At(1, 1)).(color.NRGBA); c.R != 50 {
+		t.Fatal("resized pixel is", c)
+	}
+}
+
+func TestHillshade(t *testing.T) {
+	colors := palette.Original
+
+	flat := &zeta.Tile{Width: 8, Data: make([]uint16, 64)}
+	for i := range flat.Data {
+		flat.Data[i] = 10
+	}
+	src, _ := flat.Render(colors)
+	shaded := hillshade(src, heights(flat), 8, 1)
+	for i := 0; i < 64; i++ {
+		if color.NRGBAModel.Convert(shaded.At(i%8, i/8)) != color.NRGBAModel.Convert(src.At(i%8, i/8)) {
+			t.Fatal("flat ground changed colour")
+		}
+	}
+
+	// a slope falling to the right faces away from the light in the top left
+	slope := &zeta.Tile{Width: 8, Data: make([]uint16, 64)}
+	for i := range slope.Data {
+		slope.Data[i] = uint16(20 - i%8)
+	}
+	src, _ = slope.Render(colors)
+	shaded = hillshade(src, heights(slope), 8, 1)
+	before := color.NRGBAModel.Convert(src.At(4, 4)).(color.NRGBA)
+	after := color.NRGBAModel.Convert(shaded.At(4, 4)).(color.NRGBA)
+	if int(after.R)+int(after.G)+int(after.B) >= int(before.R)+int(before.G)+int(before.B) {
+		t.Fatal("slope facing away from the light is not darker:", before, after)
+	}
+}
+
+func decode(t *testing.T, fname string, dec func(f io.Reader) (image.Image, error)) image.Image {
+	f, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	img, err := dec(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
@@ -0,0 +1,400 @@
+package rendition
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"sort"
+)
+
+// Lossless WebP (VP8L) as described in "WebP Lossless Bitstream
+// Specification". The encoder uses no transforms and no colour cache. Runs
+// that repeat the pixel to the left or the row above, common in tiles, are
+// written as backward references, and every other pixel as a literal.
+
+const (
+	vp8lSignature  = 0x2f
+	vp8lMaxSize    = 1 << 14
+	vp8lMaxLength  = 4096 // longest backward reference
+	vp8lMinLength  = 3    // shortest backward reference worth writing
+	vp8lLengthSyms = 24   // prefix codes of lengths in the green alphabet
+	vp8lDistSyms   = 40
+
+	// distance codes of the pixel above and the pixel to the left
+	vp8lDistUp   = 1
+	vp8lDistLeft = 2
+
+	maxCodeLength       = 15
+	maxCodeLengthLength = 7
+)
+
+// codeLengthOrder is the order the lengths of the code length code are
+// written in
+var codeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// encodeWebP writes the image as a lossless WebP
+func encodeWebP(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width < 1 || height < 1 || width > vp8lMaxSize || height > vp8lMaxSize {
+		return errors.New("webp images must be from 1 to 16384 pixels wide and high")
+	}
+
+	argb := make([]uint32, 0, width*height)
+	alpha := false
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			argb = append(argb, uint32(c.A)<<24|uint32(c.R)<<16|uint32(c.G)<<8|uint32(c.B))
+			alpha = alpha || c.A != 0xff
+		}
+	}
+
+	bw := &bitWriter{}
+	bw.write(vp8lSignature, 8)
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	if alpha {
+		bw.write(1, 1)
+	} else {
+		bw.write(0, 1)
+	}
+	bw.write(0, 3) // version
+	bw.write(0, 1) // no transforms
+	bw.write(0, 1) // no colour cache
+	bw.write(0, 1) // a single set of prefix codes for the whole image
+
+	tokens := backwardRefs(argb, width)
+
+	// the green alphabet holds green values and then the length prefixes
+	var green [256 + vp8lLengthSyms]int
+	var red, blue, alphas [256]int
+	var dist [vp8lDistSyms]int
+	for _, t := range tokens {
+		if t.length == 0 {
+			green[t.argb>>8&0xff]++
+			red[t.argb>>16&0xff]++
+			blue[t.argb&0xff]++
+			alphas[t.argb>>24]++
+			continue
+		}
+		sym, _, _ := prefixEncode(t.length)
+		green[256+sym]++
+		sym, _, _ = prefixEncode(t.dist)
+		dist[sym]++
+	}
+
+	codes := []*prefixCode{
+		newPrefixCode(green[:], maxCodeLength),
+		newPrefixCode(red[:], maxCodeLength),
+		newPrefixCode(blue[:], maxCodeLength),
+		newPrefixCode(alphas[:], maxCodeLength),
+		newPrefixCode(dist[:], maxCodeLength),
+	}
+	for _, c := range codes {
+		bw.writeCode(c)
+	}
+
+	for _, t := range tokens {
+		if t.length == 0 {
+			codes[0].write(bw, int(t.argb>>8&0xff))
+			codes[1].write(bw, int(t.argb>>16&0xff))
+			codes[2].write(bw, int(t.argb&0xff))
+			codes[3].write(bw, int(t.argb>>24))
+			continue
+		}
+		sym, extra, n := prefixEncode(t.length)
+		codes[0].write(bw, 256+sym)
+		bw.write(extra, n)
+		sym, extra, n = prefixEncode(t.dist)
+		codes[4].write(bw, sym)
+		bw.write(extra, n)
+	}
+	data := bw.bytes()
+
+	// the RIFF container, padded to an even size
+	size := len(data) + len(data)%2
+	header := make([]byte, 20)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(12+size))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(len(data)))
+	if len(data)%2 == 1 {
+		data = append(data, 0)
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// token is a literal pixel, or a backward reference to length pixels at a
+// distance code
+type token struct {
+	argb   uint32
+	length int
+	dist   int
+}
+
+// backwardRefs splits the pixels into literals and references to runs
+// repeating the pixel to the left or the row above
+func backwardRefs(argb []uint32, width int) []token {
+	tokens := []token{}
+	match := func(i, d int) int {
+		n := 0
+		for i+n < len(argb) && n < vp8lMaxLength && argb[i+n] == argb[i+n-d] {
+			n++
+		}
+		return n
+	}
+
+	for i := 0; i < len(argb); {
+		length, dist := 0, 0
+		if i >= width {
+			length, dist = match(i, width), vp8lDistUp
+		}
+		if i >= 1 {
+			if n := match(i, 1); n > length {
+				length, dist = n, vp8lDistLeft
+			}
+		}
+
+		if length >= vp8lMinLength {
+			tokens = append(tokens, token{length: length, dist: dist})
+			i += length
+			continue
+		}
+		tokens = append(tokens, token{argb: argb[i]})
+		i++
+	}
+	return tokens
+}
+
+// prefixEncode splits a length or distance code into its prefix symbol and
+// the extra bits that follow it
+func prefixEncode(v int) (sym int, extra uint32, bits uint) {
+	v--
+	if v < 4 {
+		return v, 0, 0
+	}
+	h := uint(0)
+	for v>>(h+1) != 0 {
+		h++
+	}
+	second := (v >> (h - 1)) & 1
+	bits = h - 1
+	return int(2*h) + second, uint32(v) & (1<<bits - 1), bits
+}
+
+// prefixCode is a canonical Huffman code
+type prefixCode struct {
+	lengths []uint8
+	codes   []uint16
+	single  bool // a code of a single symbol takes no bits
+}
+
+// newPrefixCode builds a code for the symbol counts with no code longer
+// than limit. Counts that are all zero give a code of symbol 0.
+func newPrefixCode(counts []int, limit int) *prefixCode {
+	c := &prefixCode{lengths: make([]uint8, len(counts)), codes: make([]uint16, len(counts))}
+
+	used := []int{}
+	for s, n := range counts {
+		if n > 0 {
+			used = append(used, s)
+		}
+	}
+	if len(used) < 2 {
+		s := 0
+		if len(used) == 1 {
+			s = used[0]
+		}
+		c.lengths[s] = 1
+		c.single = true
+		return c
+	}
+
+	freq := make([]int, len(counts))
+	copy(freq, counts)
+	for {
+		if huffmanLengths(freq, used, c.lengths) <= limit {
+			break
+		}
+		// flatten the counts until the tree is shallow enough
+		for _, s := range used {
+			freq[s] = (freq[s] + 1) / 2
+		}
+	}
+
+	// canonical codes are assigned in order of length and then symbol
+	var count [maxCodeLength + 2]int
+	for _, l := range c.lengths {
+		count[l]++
+	}
+	count[0] = 0
+	var next [maxCodeLength + 2]int
+	code := 0
+	for l := 1; l < len(next); l++ {
+		code = (code + count[l-1]) << 1
+		next[l] = code
+	}
+	for s, l := range c.lengths {
+		if l > 0 {
+			c.codes[s] = uint16(next[l])
+			next[l]++
+		}
+	}
+	return c
+}
+
+// huffmanLengths sets the Huffman code lengths of the used symbols and
+// returns the longest
+func huffmanLengths(freq []int, used []int, lengths []uint8) int {
+	type node struct {
+		freq   int
+		parent int
+	}
+	nodes := make([]node, 0, 2*len(used))
+	leaves := make([]int, len(used))
+	copy(leaves, used)
+	sort.SliceStable(leaves, func(i, j int) bool { return freq[leaves[i]] < freq[leaves[j]] })
+	for _, s := range leaves {
+		nodes = append(nodes, node{freq: freq[s], parent: -1})
+	}
+
+	// two queues: the sorted leaves and the merged nodes, which are made in
+	// order of frequency
+	leaf, merged := 0, len(leaves)
+	smallest := func() int {
+		if leaf < len(leaves) && (merged >= len(nodes) || nodes[leaf].freq <= nodes[merged].freq) {
+			leaf++
+			return leaf - 1
+		}
+		merged++
+		return merged - 1
+	}
+	for len(nodes) < 2*len(leaves)-1 {
+		a, b := smallest(), smallest()
+		nodes = append(nodes, node{freq: nodes[a].freq + nodes[b].freq, parent: -1})
+		nodes[a].parent = len(nodes) - 1
+		nodes[b].parent = len(nodes) - 1
+	}
+
+	longest := 0
+	for i, s := range leaves {
+		depth := 0
+		for n := i; nodes[n].parent >= 0; n = nodes[n].parent {
+			depth++
+		}
+		if depth > longest {
+			longest = depth
+		}
+		if depth < 256 {
+			lengths[s] = uint8(depth)
+		}
+	}
+	return longest
+}
+
+// write writes the code of the symbol
+func (c *prefixCode) write(bw *bitWriter, sym int) {
+	if c.single {
+		return
+	}
+	// codes are read a bit at a time from the most significant
+	code, n := c.codes[sym], uint(c.lengths[sym])
+	for i := n; i > 0; i-- {
+		bw.write(uint32(code>>(i-1))&1, 1)
+	}
+}
+
+// bitWriter packs bits from the least significant
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (bw *bitWriter) write(v uint32, n uint) {
+	bw.acc |= uint64(v) << bw.nbits
+	bw.nbits += n
+	for bw.nbits >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.nbits -= 8
+	}
+}
+
+func (bw *bitWriter) bytes() []byte {
+	if bw.nbits > 0 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc, bw.nbits = 0, 0
+	}
+	return bw.buf
+}
+
+// writeCode writes the code lengths of a prefix code, themselves coded with
+// a code length code. Runs of zero lengths are written with the repeat codes
+// 17 and 18.
+func (bw *bitWriter) writeCode(c *prefixCode) {
+	type clToken struct {
+		sym   int
+		extra uint32
+		bits  uint
+	}
+	tokens := []clToken{}
+	for i := 0; i < len(c.lengths); {
+		if c.lengths[i] != 0 {
+			tokens = append(tokens, clToken{sym: int(c.lengths[i])})
+			i++
+			continue
+		}
+
+		run := 0
+		for i+run < len(c.lengths) && c.lengths[i+run] == 0 {
+			run++
+		}
+		i += run
+		for run >= 11 {
+			n := run
+			if n > 138 {
+				n = 138
+			}
+			tokens = append(tokens, clToken{sym: 18, extra: uint32(n - 11), bits: 7})
+			run -= n
+		}
+		if run >= 3 {
+			tokens = append(tokens, clToken{sym: 17, extra: uint32(run - 3), bits: 3})
+			run = 0
+		}
+		for ; run > 0; run-- {
+			tokens = append(tokens, clToken{sym: 0})
+		}
+	}
+
+	var counts [19]int
+	for _, t := range tokens {
+		counts[t.sym]++
+	}
+	cl := newPrefixCode(counts[:], maxCodeLengthLength)
+
+	n := len(codeLengthOrder)
+	for n > 4 && cl.lengths[codeLengthOrder[n-1]] == 0 {
+		n--
+	}
+
+	bw.write(0, 1) // not a simple code
+	bw.write(uint32(n-4), 4)
+	for _, s := range codeLengthOrder[:n] {
+		bw.write(uint32(cl.lengths[s]), 3)
+	}
+	bw.write(0, 1) // lengths of every symbol follow
+	for _, t := range tokens {
+		cl.write(bw, t.sym)
+		bw.write(t.extra, t.bits)
+	}
+}
@@ -0,0 +1,112 @@
+package rendition
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"path/filepath"
+	"testing"
+	"zetamachine/pkg/palette"
+	"zetamachine/pkg/zeta"
+
+	"golang.org/x/image/webp"
+)
+
+// roundTrip encodes the image as WebP and checks it decodes to exactly the
+// same pixels
+func roundTrip(t *testing.T, name string, img image.Image) {
+	buf := &bytes.Buffer{}
+	if err := encodeWebP(buf, img); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	got, err := webp.Decode(buf)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+
+	b := img.Bounds()
+	if got.Bounds().Dx() != b.Dx() || got.Bounds().Dy() != b.Dy() {
+		t.Fatalf("%s: decoded %v, want %v", name, got.Bounds(), b)
+	}
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			want := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y))
+			if c := color.NRGBAModel.Convert(got.At(x, y)); c != want {
+				t.Fatalf("%s: pixel %d,%d is %v, want %v", name, x, y, c, want)
+			}
+		}
+	}
+}
+
+func TestEncodeWebP(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	noise := image.NewNRGBA(image.Rect(0, 0, 67, 33))
+	for i := range noise.Pix {
+		noise.Pix[i] = uint8(rnd.Intn(256))
+	}
+	roundTrip(t, "noise", noise)
+
+	// few colours with long runs, like a tile
+	tile := &zeta.Tile{Width: 64, Data: make([]uint16, 64*64)}
+	for i := range tile.Data {
+		tile.Data[i] = uint16((i%64)/7 + (i/64)/5)
+	}
+	img, err := tile.Render(palette.Original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip(t, "tile", img)
+
+	flat := image.NewNRGBA(image.Rect(0, 0, 300, 20))
+	for i := 0; i < len(flat.Pix); i += 4 {
+		copy(flat.Pix[i:], []uint8{0x00, 0x3c, 0xff, 0xff})
+	}
+	roundTrip(t, "flat", flat)
+
+	alpha := image.NewNRGBA(image.Rect(3, 5, 19, 21))
+	for y := 5; y < 21; y++ {
+		for x := 3; x < 19; x++ {
+			alpha.Set(x, y, color.NRGBA{uint8(x * 10), 0x80, uint8(y * 7), uint8(x * y)})
+		}
+	}
+	roundTrip(t, "alpha", alpha)
+
+	roundTrip(t, "pixel", image.NewNRGBA(image.Rect(0, 0, 1, 1)))
+
+	if err := encodeWebP(&bytes.Buffer{}, image.NewNRGBA(image.Rect(0, 0, 1<<14+1, 1))); err == nil {
+		t.Fatal("an image too wide for WebP was encoded")
+	}
+}
+
+func TestWriteWebP(t *testing.T) {
+	root := t.TempDir()
+	tile := testTile()
+
+	r := &Rendition{Name: "lossless", Format: "webp", Size: 64, Path: "{name}/{zoom}/{x}/{y}.{ext}"}
+	r.setDefaults()
+	if err := r.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Write(root, tile); err != nil {
+		t.Fatal(err)
+	}
+	fname := r.Filename(root, tile)
+	if filepath.Ext(fname) != ".webp" {
+		t.Fatal("webp rendition is written to", fname)
+	}
+
+	want, err := r.Image(tile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := decode(t, fname, webp.Decode)
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			if color.NRGBAModel.Convert(got.At(x, y)) != color.NRGBAModel.Convert(want.At(x, y)) {
+				t.Fatalf("pixel %d,%d of the webp is not the rendered pixel", x, y)
+			}
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package seed
+
+import (
+	"log"
+	"math"
+	"zetamachine/pkg/zeta"
+)
+
+// Coverage describes which tiles should exist at each zoom level. By default
+// this is the bulb near the origin and, optionally, the arms extending along
+// the imaginary axis. If any regions are given, only the tiles intersecting
+// them are covered instead.
+type Coverage struct {
+	MinZoom  int
+	MaxZoom  int
+	BulbOnly bool
+	Regions  []RegionSpec
+
+	// Function and Params select the function the tiles are rendered for and
+	// Mode how it is rendered. They are empty for the zeta iteration.
+	Function string
+	Params   map[string]float64
+	Mode     string
+
+	// Sampling is how the points of each pixel are chosen (see
+	// zeta.ParseSampling), empty for the pixel's corner
+	Sampling string
+
+	// LUT requests the set computed with lookup tables (see zeta.Tile.LUT)
+	LUT bool
+
+	// Width is the width of the set's tiles (see zeta.Metadata), zero for
+	// zeta.TileWidth
+	Width int
+}
+
+// tile constructs the covered tile at zoom, x, y
+func (c *Coverage) tile(zoom, x, y int) *zeta.Tile {
+	return &zeta.Tile{
+		Zoom:     zoom,
+		X:        x,
+		Y:        y,
+		Width:    c.width(),
+		Function: c.Function,
+		Params:   c.Params,
+		Mode:     c.Mode,
+		Sampling: c.Sampling,
+		LUT:      c.LUT,
+	}
+}
+
+// width returns the width of the covered tiles
+func (c *Coverage) width() int {
+	if c.Width == 0 {
+		return zeta.TileWidth
+	}
+	return c.Width
+}
+
+// ZoomRange returns the zoom levels covered. When regions are given they carry
+// their own zoom ranges.
+func (c *Coverage) ZoomRange() (int, int) {
+	if len(c.Regions) == 0 {
+		return c.MinZoom, c.MaxZoom
+	}
+
+	minZoom, maxZoom := c.Regions[0].MinZoom, c.Regions[0].MaxZoom
+	for _, spec := range c.Regions[1:] {
+		if spec.MinZoom < minZoom {
+			minZoom = spec.MinZoom
+		}
+		if spec.MaxZoom > maxZoom {
+			maxZoom = spec.MaxZoom
+		}
+	}
+	return minZoom, maxZoom
+}
+
+// Each calls fn for every tile covered at the zoom level. If fn returns false
+// the enumeration stops and Each returns false.
+func (c *Coverage) Each(zoom int, fn func(t *zeta.Tile) bool) bool {
+	if len(c.Regions) > 0 {
+		return c.eachRegion(zoom, fn)
+	}
+
+	yCount, ok := c.eachBulb(zoom, fn)
+	if !ok {
+		return false
+	}
+
+	if !c.BulbOnly {
+		return c.eachArm(yCount, zoom, fn)
+	}
+	return true
+}
+
+// eachRegion visits every tile at this zoom level that intersects one of the
+// regions. Tiles covered by more than one region are only visited once.
+func (c *Coverage) eachRegion(zoom int, fn func(t *zeta.Tile) bool) bool {
+	seen := make(map[[2]int]bool)
+
+	ppu := math.Pow(2, float64(zoom))
+	units := float64(c.width()) / ppu // units per tile
+
+	for _, spec := range c.Regions {
+		if zoom < spec.MinZoom || zoom > spec.MaxZoom {
+			continue
+		}
+
+		min, max := spec.Region.Bounds()
+		xStart := int(math.Floor(real(min) / units))
+		xEnd := int(math.Floor(real(max)/units)) + 1
+		yStart := int(math.Floor(imag(min) / units))
+		yEnd := int(math.Floor(imag(max)/units)) + 1
+
+		log.Println("[coverage] zoom:", zoom, "region:", spec.Name, "x:", xStart, "to", xEnd-1, "y:", yStart, "to", yEnd-1)
+
+		for x := xStart; x < xEnd; x++ {
+			for y := yStart; y < yEnd; y++ {
+				t := c.tile(zoom, x, y)
+
+				if seen[[2]int{x, y}] || !spec.Region.Intersects(t.Min(), t.Max()) {
+					continue
+				}
+				seen[[2]int{x, y}] = true
+
+				if !fn(t) {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+// eachBulb visits the tiles around the bulb area in the middle of the display
+// near the origin. It is only valid for zoom levels of 1 or greater.
+func (c *Coverage) eachBulb(zoom int, fn func(t *zeta.Tile) bool) (int, bool) {
+	log.Println("[coverage] -- bulb --")
+	xRange := math.Max(float64(zeta.TileWidth/zoom/8), 30.0)
+	yRange := math.Max(float64(zeta.TileWidth/zoom/8), 20.0)
+
+	ppu := math.Pow(2, float64(zoom))
+	units := float64(c.width()) / ppu // units per tile
+
+	// how many patches in each direction
+	xCount := int(math.Max(1, xRange/units))
+	yCount := int(math.Max(1, yRange/units))
+
+	return yCount, c.eachRange(zoom, xCount, -yCount, yCount, fn)
+}
+
+// eachArm visits the tiles for the arms extending from the bulb in the
+// imaginary axis. It is hard coded to only go to +/- 4096 on the imaginary
+// axis.
+func (c *Coverage) eachArm(yStart, zoom int, fn func(t *zeta.Tile) bool) bool {
+	xRange := math.Max(float64(zeta.TileWidth/zoom/8), 6.0)
+	yRange := 4096.0 // same
+
+	ppu := math.Pow(2, float64(zoom))
+	units := float64(c.width()) / ppu // units per tile
+
+	// how many patches in each direction
+	xCount := int(math.Max(2, xRange/units))
+	yCount := int(math.Max(2, yRange/units))
+
+	log.Println("[coverage] -- positive arm -- ", yStart, yCount, " yrange, units", yRange, units)
+	if !c.eachRange(zoom, xCount, yStart, yCount, fn) {
+		return false
+	}
+
+	log.Println("[coverage] -- negative arm --", -yCount+1, -yStart)
+	return c.eachRange(zoom, xCount, -yCount+1, -yStart, fn) // count and start need reversed
+}
+
+// eachRange visits tiles numbered in the x and y directions. The number of
+// tiles in the x-direction (the real axis) are from -xCount to xCount-1
+func (c *Coverage) eachRange(zoom, xCount, yStart, yEnd int, fn func(t *zeta.Tile) bool) bool {
+	log.Println("[coverage] zoom:", zoom, " xrange ", -xCount, " to ", xCount-1)
+	log.Println("\tyrange ", yStart, " to ", yEnd-1)
+
+	for x := -xCount; x < xCount; x++ {
+		for y := yStart; y < yEnd; y++ {
+
+			// rl := -xr + units*float64(x+xCount)
+			// im := -yRange + units*float64(y+yCount)
+
+			t := c.tile(int(zoom), x, y)
+
+			if !fn(t) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
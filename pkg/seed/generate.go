@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"time"
 	"zetamachine/pkg/utils"
 	"zetamachine/pkg/zeta"
@@ -25,7 +26,10 @@ import (
 //
 const (
 	requestPatchTopic = "patch-request"
-	nsqMaxMsgSize     = 1048576
+
+	// nsqMaxMsgSize is the largest tile published unless ZETA_NSQ_MAX_MSG_SIZE
+	// is set. It matches the nsqd -max-msg-size default.
+	nsqMaxMsgSize = 1048576
 )
 
 // Starter is a basic interface that provides a Start() method
@@ -36,14 +40,37 @@ type Starter interface {
 // CudaServer waits for messages requesting the generation of a tile patch
 // then generates the data on the GPU, splits the patch into 16 tiles
 // and publishes each individual tile.
+//
+// Requests are consumed from every priority tier topic (see RequestTopic) but
+// only one tile is computed at a time and waiting higher priority requests
+// always go first.
 type CudaServer struct {
 	producer *nsq.Producer
 	valve    *valve.Valve
+	tiers    int
+	gate     *priorityGate
+	backend  zeta.Backend
+
+	maxMsgSize int
+}
+
+// tierHandler handles the messages of a single priority tier
+type tierHandler struct {
+	server *CudaServer
+	tier   int
+}
+
+func (h *tierHandler) HandleMessage(msg *nsq.Message) error {
+	return h.server.handle(msg, h.tier)
 }
 
 // NewCudaServer constructs a CudaServer by creating internal structures
-// such as an NSQ Producer for publishing generated tiles
-func NewCudaServer(v *valve.Valve) (*CudaServer, error) {
+// such as an NSQ Producer for publishing generated tiles. Tiles are computed
+// by the backend.
+func NewCudaServer(v *valve.Valve, tiers int, backend zeta.Backend) (*CudaServer, error) {
+	if tiers < 1 {
+		return nil, fmt.Errorf("need at least one priority tier, got %d", tiers)
+	}
 
 	config := nsq.NewConfig()
 	p, err := nsq.NewProducer(os.Getenv("ZETA_NSQD"), config)
@@ -51,9 +78,23 @@ func NewCudaServer(v *valve.Valve) (*CudaServer, error) {
 		log.Fatal("Could not connect to nsqd: ", err)
 	}
 
+	// domain colouring tiles hold a pair of floats per pixel and need nsqd
+	// to be started with a larger -max-msg-size
+	maxMsgSize := nsqMaxMsgSize
+	if size := os.Getenv("ZETA_NSQ_MAX_MSG_SIZE"); size != "" {
+		maxMsgSize, err = strconv.Atoi(size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ZETA_NSQ_MAX_MSG_SIZE: %v", err)
+		}
+	}
+
 	server := CudaServer{
-		producer: p,
-		valve:    v,
+		producer:   p,
+		valve:      v,
+		tiers:      tiers,
+		gate:       newPriorityGate(tiers),
+		backend:    backend,
+		maxMsgSize: maxMsgSize,
 	}
 
 	return &server, nil
@@ -61,15 +102,24 @@ func NewCudaServer(v *valve.Valve) (*CudaServer, error) {
 
 // Start starts the NSQ consumer to service request messages
 func (s *CudaServer) Start() {
-	go func() {
-		if err := utils.StartConsumer(s.valve.Context(), requestPatchTopic, "patch-generator", 1, s); err != nil {
-			log.Fatal(err)
-		}
-	}()
+	for tier := 0; tier < s.tiers; tier++ {
+		h := &tierHandler{server: s, tier: tier}
+		go func() {
+			if err := utils.StartConsumer(s.valve.Context(), RequestTopic(h.tier), "patch-generator", 1, h); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
 }
 
 // HandleMessage is called by the NSQ consumer when a request for a patch is received.
 func (s *CudaServer) HandleMessage(msg *nsq.Message) error {
+	return s.handle(msg, 0)
+}
+
+// handle computes the requested tile once no higher priority requests are
+// waiting, touching the message the whole time so it does not time out.
+func (s *CudaServer) handle(msg *nsq.Message, tier int) error {
 	if err := s.valve.Open(); err != nil {
 		log.Println("[server] failed to open valve: ", err)
 		return err
@@ -84,8 +134,12 @@ func (s *CudaServer) HandleMessage(msg *nsq.Message) error {
 
 	ticker := time.NewTicker(utils.TouchSec * time.Second)
 	done := make(chan bool)
+	var computeErr error
 	go func() {
-		t.ComputeRequest(s.valve.Context())
+		s.gate.acquire(tier)
+		defer s.gate.release()
+
+		computeErr = s.backend.Compute(s.valve.Context(), t)
 		close(done)
 	}()
 
@@ -100,8 +154,17 @@ loop:
 		}
 	}
 
+	if computeErr != nil {
+		log.Println("[cuda server] failed to compute tile:", t, computeErr)
+
+		// requeue tiles interrupted by shutting down
+		if s.valve.Context().Err() != nil {
+			return computeErr
+		}
+	}
+
 	// Publish the 16 tiles for storage.
-	if err := s.publishTile(t); err != nil {
+	if computeErr != nil || s.publishTile(t) != nil {
 		// Move this patch request message to the errors topic
 		if err := s.producer.Publish("patch-errors", msg.Body); err != nil {
 			log.Println("[cuda server] error publishing error message:", err)
@@ -120,7 +183,7 @@ func (s *CudaServer) publishTile(tile *zeta.Tile) error {
 
 	// If the compressed tile is still too large, publish the original
 	// request to the `patch-errors` topic and move on
-	if len(json) > nsqMaxMsgSize {
+	if len(json) > s.maxMsgSize {
 		log.Println("[cuda server] tile too large:", tile)
 		return fmt.Errorf("tile to large:%d bytes", len(json)) // swallow msg from this topic
 	}
@@ -0,0 +1,227 @@
+package seed
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"zetamachine/pkg/zeta"
+)
+
+// Order sorts the tiles of a single zoom level into the order they should be
+// requested, most important first
+type Order interface {
+	Sort(tiles []*zeta.Tile)
+}
+
+// RasterOrder leaves tiles in the order the coverage produces them
+type RasterOrder struct{}
+
+// Sort ...
+func (RasterOrder) Sort(tiles []*zeta.Tile) {}
+
+// SpiralOrder requests tiles in square rings spiralling out from the tile
+// containing the focus point
+type SpiralOrder struct {
+	Focus complex128
+}
+
+// Sort ...
+func (o SpiralOrder) Sort(tiles []*zeta.Tile) {
+	if len(tiles) == 0 {
+		return
+	}
+
+	units := tiles[0].Units()
+	fx := int(math.Floor(real(o.Focus) / units))
+	fy := int(math.Floor(imag(o.Focus) / units))
+
+	ring := func(t *zeta.Tile) int {
+		dx, dy := t.X-fx, t.Y-fy
+		if dx < 0 {
+			dx = -dx
+		}
+		if dy < 0 {
+			dy = -dy
+		}
+		if dx > dy {
+			return dx
+		}
+		return dy
+	}
+
+	angle := func(t *zeta.Tile) float64 {
+		return math.Atan2(float64(t.Y-fy), float64(t.X-fx))
+	}
+
+	sort.SliceStable(tiles, func(i, j int) bool {
+		ri, rj := ring(tiles[i]), ring(tiles[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return angle(tiles[i]) < angle(tiles[j])
+	})
+}
+
+// HilbertOrder requests tiles along a Hilbert curve over the bounding box of
+// the tiles so that consecutive requests are close together
+type HilbertOrder struct{}
+
+// Sort ...
+func (HilbertOrder) Sort(tiles []*zeta.Tile) {
+	if len(tiles) == 0 {
+		return
+	}
+
+	minX, minY := tiles[0].X, tiles[0].Y
+	maxX, maxY := minX, minY
+	for _, t := range tiles {
+		if t.X < minX {
+			minX = t.X
+		}
+		if t.Y < minY {
+			minY = t.Y
+		}
+		if t.X > maxX {
+			maxX = t.X
+		}
+		if t.Y > maxY {
+			maxY = t.Y
+		}
+	}
+
+	n := 1
+	for n <= maxX-minX || n <= maxY-minY {
+		n *= 2
+	}
+
+	d := make(map[*zeta.Tile]int, len(tiles))
+	for _, t := range tiles {
+		d[t] = hilbert(n, t.X-minX, t.Y-minY)
+	}
+
+	sort.SliceStable(tiles, func(i, j int) bool {
+		return d[tiles[i]] < d[tiles[j]]
+	})
+}
+
+// hilbert returns the distance along a Hilbert curve filling an n by n grid
+// (n is a power of two) to the cell x, y
+func hilbert(n, x, y int) int {
+	d := 0
+	for s := n / 2; s > 0; s /= 2 {
+		rx, ry := 0, 0
+		if x&s > 0 {
+			rx = 1
+		}
+		if y&s > 0 {
+			ry = 1
+		}
+		d += s * s * ((3 * rx) ^ ry)
+
+		// rotate the quadrant
+		if ry == 0 {
+			if rx == 1 {
+				x = s - 1 - x
+				y = s - 1 - y
+			}
+			x, y = y, x
+		}
+	}
+	return d
+}
+
+// TileKey identifies a tile in a pyramid
+type TileKey struct {
+	Zoom, X, Y int
+}
+
+// PopularityOrder requests the most viewed tiles first. Tiles with the same
+// number of views keep the order given by Then.
+type PopularityOrder struct {
+	Views map[TileKey]int
+	Then  Order
+}
+
+// Sort ...
+func (o PopularityOrder) Sort(tiles []*zeta.Tile) {
+	if o.Then != nil {
+		o.Then.Sort(tiles)
+	}
+
+	views := func(t *zeta.Tile) int {
+		return o.Views[TileKey{Zoom: t.Zoom, X: t.X, Y: t.Y}]
+	}
+
+	sort.SliceStable(tiles, func(i, j int) bool {
+		return views(tiles[i]) > views(tiles[j])
+	})
+}
+
+// tileURL matches the dynamic tile route of the web server and the static
+// tile paths used by the Leaflet map.
+//
+//	/tile/{zoom}/{y}/{x}/
+//	/public/tiles/{z}/{y}/{z}.{y}.{x}.png
+var tileURL = regexp.MustCompile(`/tile/(-?\d+)/(-?\d+)/(-?\d+)|/tiles/(-?\d+)/(-?\d+)/-?\d+\.-?\d+\.(-?\d+)\.png`)
+
+// LoadAccessLog counts the number of times each tile was requested in a web
+// server access log. Any log format works as long as the request path appears
+// on the line.
+func LoadAccessLog(fname string) (map[TileKey]int, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	views := make(map[TileKey]int)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m := tileURL.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+
+		// either the first or the second set of groups matched
+		g := m[1:4]
+		if m[1] == "" {
+			g = m[4:7]
+		}
+
+		zoom, _ := strconv.Atoi(g[0])
+		y, _ := strconv.Atoi(g[1])
+		x, _ := strconv.Atoi(g[2])
+		views[TileKey{Zoom: zoom, X: x, Y: y}]++
+	}
+
+	return views, scanner.Err()
+}
+
+// ParseOrder returns the named ordering strategy. The spiral and popularity
+// orders spiral out from the focus point, and the popularity order reads
+// views from the access log.
+func ParseOrder(name string, focus complex128, accessLog string) (Order, error) {
+	switch name {
+	case "", "raster":
+		return RasterOrder{}, nil
+	case "spiral":
+		return SpiralOrder{Focus: focus}, nil
+	case "hilbert":
+		return HilbertOrder{}, nil
+	case "popular":
+		if accessLog == "" {
+			return nil, fmt.Errorf("the popular order needs an access log")
+		}
+		views, err := LoadAccessLog(accessLog)
+		if err != nil {
+			return nil, err
+		}
+		return PopularityOrder{Views: views, Then: SpiralOrder{Focus: focus}}, nil
+	}
+
+	return nil, fmt.Errorf("unknown order %q", name)
+}
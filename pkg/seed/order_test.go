@@ -0,0 +1,138 @@
+package seed
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"zetamachine/pkg/zeta"
+)
+
+// grid returns the tiles of a w by h grid from x, y at zoom 4
+func grid(x, y, w, h int) []*zeta.Tile {
+	tiles := []*zeta.Tile{}
+	for j := y; j < y+h; j++ {
+		for i := x; i < x+w; i++ {
+			tiles = append(tiles, &zeta.Tile{Zoom: 4, X: i, Y: j, Width: 16})
+		}
+	}
+	return tiles
+}
+
+func TestHilbert(t *testing.T) {
+	cases := []struct {
+		n, x, y, d int
+	}{
+		{1, 0, 0, 0},
+		{2, 0, 0, 0}, {2, 0, 1, 1}, {2, 1, 1, 2}, {2, 1, 0, 3},
+		{4, 0, 0, 0}, {4, 1, 0, 1}, {4, 1, 1, 2}, {4, 0, 1, 3},
+		{4, 0, 2, 4}, {4, 2, 2, 8}, {4, 3, 0, 15},
+	}
+	for _, c := range cases {
+		if d := hilbert(c.n, c.x, c.y); d != c.d {
+			t.Errorf("hilbert(%d, %d, %d) = %d, want %d", c.n, c.x, c.y, d, c.d)
+		}
+	}
+
+	// a curve visits every cell of the grid once, each next to the last
+	tiles := grid(-3, 5, 8, 8)
+	HilbertOrder{}.Sort(tiles)
+	seen := map[[2]int]bool{}
+	for i, tile := range tiles {
+		seen[[2]int{tile.X, tile.Y}] = true
+		if i == 0 {
+			continue
+		}
+		dx, dy := tile.X-tiles[i-1].X, tile.Y-tiles[i-1].Y
+		if dx*dx+dy*dy != 1 {
+			t.Fatalf("tile %d at %d, %d isn't next to %d, %d", i, tile.X, tile.Y, tiles[i-1].X, tiles[i-1].Y)
+		}
+	}
+	if len(seen) != 64 {
+		t.Errorf("visited %d tiles, want 64", len(seen))
+	}
+}
+
+func TestSpiralOrder(t *testing.T) {
+	tiles := grid(-5, -5, 11, 9)
+
+	// 16 pixel tiles at zoom 4 are a unit wide, so the focus is in tile 2, -1
+	SpiralOrder{Focus: 2.5 - 0.5i}.Sort(tiles)
+	if tiles[0].X != 2 || tiles[0].Y != -1 {
+		t.Fatalf("spiral starts at %d, %d", tiles[0].X, tiles[0].Y)
+	}
+
+	ring := 0
+	for _, tile := range tiles {
+		r := max(abs(tile.X-2), abs(tile.Y+1))
+		if r < ring {
+			t.Fatalf("tile %d, %d in ring %d comes after ring %d", tile.X, tile.Y, r, ring)
+		}
+		ring = r
+	}
+	if ring != 7 {
+		t.Errorf("spiral ends in ring %d, want 7", ring)
+	}
+}
+
+func TestPopularityOrder(t *testing.T) {
+	tiles := grid(0, 0, 3, 1)
+	views := map[TileKey]int{{Zoom: 4, X: 2, Y: 0}: 5, {Zoom: 4, X: 0, Y: 0}: 1, {Zoom: 3, X: 1, Y: 0}: 9}
+
+	PopularityOrder{Views: views, Then: SpiralOrder{Focus: 1.5}}.Sort(tiles)
+	got := []int{}
+	for _, tile := range tiles {
+		got = append(got, tile.X)
+	}
+	if want := []int{2, 0, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tiles are in order %v, want %v", got, want)
+	}
+}
+
+func TestLoadAccessLog(t *testing.T) {
+	lines := `127.0.0.1 - - [10/Oct/2020:13:55:36 +0000] "GET /public/tiles/4/-2/4.-2.3.png HTTP/1.1" 200 2326
+127.0.0.1 - - [10/Oct/2020:13:55:37 +0000] "GET /public/tiles/newton_zeta/4/-2/4.-2.3.png HTTP/1.1" 200 2326
+10.0.0.2 - - [10/Oct/2020:13:55:38 +0000] "GET /tile/7/12/-40/ HTTP/1.1" 200 512
+10.0.0.2 - - [10/Oct/2020:13:55:39 +0000] "GET /index.html HTTP/1.1" 200 100
+{"time": "2020-10-10T13:55:40Z", "path": "/public/tiles/0/0/0.0.-1.png"}
+`
+	fname := filepath.Join(t.TempDir(), "access.log")
+	if err := ioutil.WriteFile(fname, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	views, err := LoadAccessLog(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// tiles of other sets aren't views of these
+	want := map[TileKey]int{
+		{Zoom: 4, X: 3, Y: -2}:   1,
+		{Zoom: 7, X: -40, Y: 12}: 1,
+		{Zoom: 0, X: -1, Y: 0}:   1,
+	}
+	if !reflect.DeepEqual(views, want) {
+		t.Errorf("counted %v, want %v", views, want)
+	}
+
+	if _, err := ParseOrder("popular", 0, ""); err == nil {
+		t.Error("popular order without an access log")
+	}
+	if _, err := ParseOrder("random", 0, ""); err == nil {
+		t.Error("parsed an unknown order")
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
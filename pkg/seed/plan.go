@@ -0,0 +1,88 @@
+package seed
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+	"zetamachine/pkg/zeta"
+)
+
+// ZoomPlan summarises the work needed to fill in one zoom level
+type ZoomPlan struct {
+	Zoom     int           `json:"zoom"`
+	Tiles    int           `json:"tiles"`
+	Existing int           `json:"existing"`
+	Missing  int           `json:"missing"`
+	Terms    float64       `json:"terms"`
+	CPUTime  time.Duration `json:"cpuTime"`
+}
+
+// Plan is a dry run of a Requester. It enumerates the tiles in a coverage,
+// subtracts the ones that already exist and estimates the compute cost of the
+// rest without publishing anything.
+type Plan struct {
+	Zooms    []ZoomPlan    `json:"zooms"`
+	Total    ZoomPlan      `json:"total"`
+	TermCost time.Duration `json:"termCost"`
+}
+
+// NewPlan builds a plan for the coverage using the cost model
+func NewPlan(coverage *Coverage, model *zeta.CostModel) *Plan {
+	p := &Plan{TermCost: model.TermCost}
+	p.Total.Zoom = -1
+
+	minZoom, maxZoom := coverage.ZoomRange()
+	for zoom := minZoom; zoom <= maxZoom; zoom++ {
+		zp := ZoomPlan{Zoom: zoom}
+
+		coverage.Each(zoom, func(t *zeta.Tile) bool {
+			zp.Tiles++
+			if info, _ := t.Exists(); info != nil {
+				zp.Existing++
+				return true
+			}
+
+			zp.Missing++
+			zp.Terms += model.TileTerms(t)
+			return true
+		})
+
+		zp.CPUTime = time.Duration(zp.Terms * float64(model.TermCost))
+
+		p.Zooms = append(p.Zooms, zp)
+		p.Total.Tiles += zp.Tiles
+		p.Total.Existing += zp.Existing
+		p.Total.Missing += zp.Missing
+		p.Total.Terms += zp.Terms
+		p.Total.CPUTime += zp.CPUTime
+	}
+
+	return p
+}
+
+// WriteJSON writes the plan as indented JSON
+func (p *Plan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// WriteTable writes the plan as a human readable table
+func (p *Plan) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "zoom\ttiles\texisting\tmissing\tterms\test. cpu time\t")
+
+	row := func(label string, zp ZoomPlan) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.3g\t%s\t\n", label, zp.Tiles, zp.Existing, zp.Missing, zp.Terms, zp.CPUTime.Round(time.Second))
+	}
+
+	for _, zp := range p.Zooms {
+		row(fmt.Sprint(zp.Zoom), zp)
+	}
+	row("total", p.Total)
+
+	fmt.Fprintf(tw, "\nterm cost: %s\n", p.TermCost)
+	return tw.Flush()
+}
@@ -0,0 +1,117 @@
+package seed
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"zetamachine/pkg/zeta"
+)
+
+func TestPlan(t *testing.T) {
+	defer os.Setenv("ZETA_TILE_PATH", os.Getenv("ZETA_TILE_PATH"))
+	os.Setenv("ZETA_TILE_PATH", t.TempDir())
+
+	coverage := &Coverage{
+		Width: 8,
+		Regions: []RegionSpec{
+			{Name: "a", MinZoom: 2, MaxZoom: 3, Region: Rect{Min: -3 - 1i, Max: 2 + 4i}},
+			{Name: "b", MinZoom: 3, MaxZoom: 3, Region: Path{Points: []complex128{10 + 10i, 12 + 10i}}},
+		},
+	}
+
+	// one tile is already stored
+	stored := &zeta.Tile{Zoom: 2, X: 0, Y: 0, Width: 8, Data: make([]uint16, 64)}
+	if err := stored.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	model := &zeta.CostModel{Samples: 2, FollowIterations: 1, TermCost: time.Microsecond}
+	plan := NewPlan(coverage, model)
+	if len(plan.Zooms) != 2 {
+		t.Fatalf("planned %d zoom levels, want 2", len(plan.Zooms))
+	}
+
+	total := 0
+	for _, zp := range plan.Zooms {
+		tiles, terms := 0, 0.0
+		coverage.Each(zp.Zoom, func(tile *zeta.Tile) bool {
+			tiles++
+			if tile.Zoom != stored.Zoom || tile.X != stored.X || tile.Y != stored.Y {
+				terms += model.TileTerms(tile)
+			}
+			return true
+		})
+
+		existing := 0
+		if zp.Zoom == stored.Zoom {
+			existing = 1
+		}
+		if zp.Tiles != tiles || zp.Existing != existing || zp.Missing != tiles-existing {
+			t.Errorf("zoom %d: planned %+v for %d tiles with %d stored", zp.Zoom, zp, tiles, existing)
+		}
+		if zp.Terms != terms || zp.CPUTime != time.Duration(terms*float64(time.Microsecond)) {
+			t.Errorf("zoom %d: planned %g terms in %s, want %g", zp.Zoom, zp.Terms, zp.CPUTime, terms)
+		}
+		total += tiles
+	}
+	if plan.Total.Tiles != total || plan.Total.Existing != 1 {
+		t.Errorf("total is %+v for %d tiles", plan.Total, total)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := plan.WriteJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded := &Plan{}
+	if err := json.Unmarshal(buf.Bytes(), decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Total != plan.Total || len(decoded.Zooms) != len(plan.Zooms) {
+		t.Errorf("JSON plan decoded to %+v", decoded)
+	}
+
+	buf.Reset()
+	if err := plan.WriteTable(buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if !strings.HasPrefix(strings.TrimSpace(lines[3]), "total") || !strings.Contains(buf.String(), "term cost: 1µs") {
+		t.Errorf("table is\n%s", buf.String())
+	}
+}
+
+func TestCoverageEach(t *testing.T) {
+	c := &Coverage{Width: 16, Regions: []RegionSpec{
+		{MinZoom: 4, MaxZoom: 4, Region: Rect{Min: 0, Max: 1.5 + 1.5i}},
+		{MinZoom: 4, MaxZoom: 4, Region: Rect{Min: 1, Max: 1.5 + 0.5i}},
+	}}
+
+	// 16 pixel tiles at zoom 4 are a unit wide, and the tiles covered by
+	// both regions are only visited once
+	seen := map[[2]int]int{}
+	c.Each(4, func(tile *zeta.Tile) bool {
+		if tile.Width != 16 || tile.Units() != 1 {
+			t.Fatalf("covered %v", tile)
+		}
+		seen[[2]int{tile.X, tile.Y}]++
+		return true
+	})
+	want := [][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}}
+	if len(seen) != len(want) {
+		t.Fatalf("covered %v, want %v", seen, want)
+	}
+	for _, k := range want {
+		if seen[k] != 1 {
+			t.Errorf("tile %v was visited %d times", k, seen[k])
+		}
+	}
+
+	// returning false stops the enumeration
+	n := 0
+	if c.Each(4, func(*zeta.Tile) bool { n++; return false }) || n != 1 {
+		t.Errorf("visited %d tiles after stopping", n)
+	}
+}
@@ -0,0 +1,49 @@
+package seed
+
+import "sync"
+
+// priorityGate lets one holder in at a time. When it is released the waiter
+// with the highest priority (lowest tier) goes next.
+type priorityGate struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	busy    bool
+	waiting []int
+}
+
+func newPriorityGate(tiers int) *priorityGate {
+	g := &priorityGate{waiting: make([]int, tiers)}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+// acquire blocks until the gate is free and nothing of a higher priority is
+// waiting for it
+func (g *priorityGate) acquire(tier int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.waiting[tier]++
+	for g.busy || g.higherWaiting(tier) {
+		g.cond.Wait()
+	}
+	g.waiting[tier]--
+	g.busy = true
+}
+
+// release frees the gate for the next waiter
+func (g *priorityGate) release() {
+	g.mu.Lock()
+	g.busy = false
+	g.mu.Unlock()
+	g.cond.Broadcast()
+}
+
+func (g *priorityGate) higherWaiting(tier int) bool {
+	for t := 0; t < tier; t++ {
+		if g.waiting[t] > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package seed
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPriorityGate(t *testing.T) {
+	g := newPriorityGate(3)
+	g.acquire(2)
+
+	// wait for the waiters to queue up behind the holder, lowest priority
+	// first
+	mu := &sync.Mutex{}
+	order := []int{}
+	wg := &sync.WaitGroup{}
+	for _, tier := range []int{2, 1, 0} {
+		wg.Add(1)
+		go func(tier int) {
+			defer wg.Done()
+			g.acquire(tier)
+			mu.Lock()
+			order = append(order, tier)
+			mu.Unlock()
+			g.release()
+		}(tier)
+
+		for waiting := 0; waiting == 0; {
+			time.Sleep(time.Millisecond)
+			g.mu.Lock()
+			waiting = g.waiting[tier]
+			g.mu.Unlock()
+		}
+	}
+
+	g.release()
+	wg.Wait()
+	if want := []int{0, 1, 2}; !reflect.DeepEqual(order, want) {
+		t.Errorf("tiers went through in order %v, want %v", order, want)
+	}
+}
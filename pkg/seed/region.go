@@ -0,0 +1,323 @@
+package seed
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Region is an area of the complex plane to request tiles for
+type Region interface {
+	// Bounds returns the lower-left and upper-right corners of the region
+	Bounds() (min, max complex128)
+
+	// Intersects reports whether any part of the region falls within the
+	// tile spanning min (inclusive) to max (exclusive)
+	Intersects(min, max complex128) bool
+}
+
+// RegionSpec is a region along with the zoom levels it should be rendered at
+type RegionSpec struct {
+	Name    string
+	MinZoom int
+	MaxZoom int
+	Region  Region
+}
+
+// Rect is a rectangle in the complex plane. A rectangle with no width or
+// height is treated as a line or a point.
+type Rect struct {
+	Min, Max complex128
+}
+
+// Bounds ...
+func (r Rect) Bounds() (complex128, complex128) {
+	return r.Min, r.Max
+}
+
+// Intersects ...
+func (r Rect) Intersects(min, max complex128) bool {
+	return overlaps(real(r.Min), real(r.Max), real(min), real(max)) &&
+		overlaps(imag(r.Min), imag(r.Max), imag(min), imag(max))
+}
+
+// Polygon is a closed polygon in the complex plane. The last point is
+// implicitly connected back to the first.
+type Polygon struct {
+	Points []complex128
+}
+
+// Bounds ...
+func (p Polygon) Bounds() (complex128, complex128) {
+	return bounds(p.Points)
+}
+
+// Intersects ...
+func (p Polygon) Intersects(min, max complex128) bool {
+	if len(p.Points) == 0 {
+		return false
+	}
+
+	bmin, bmax := p.Bounds()
+	if !(Rect{Min: bmin, Max: bmax}).Intersects(min, max) {
+		return false
+	}
+
+	// any edge crossing the tile, or a vertex inside it
+	for i := range p.Points {
+		a := p.Points[i]
+		b := p.Points[(i+1)%len(p.Points)]
+		if segmentIntersects(a, b, min, max) {
+			return true
+		}
+	}
+
+	// otherwise the tile is either entirely inside the polygon or outside it
+	return p.contains(min)
+}
+
+// contains uses ray casting to see if the point z is inside the polygon
+func (p Polygon) contains(z complex128) bool {
+	inside := false
+	n := len(p.Points)
+	for i, j := 0, n-1; i < n; j, i = i, i+1 {
+		a, b := p.Points[i], p.Points[j]
+		if (imag(a) > imag(z)) != (imag(b) > imag(z)) {
+			x := real(a) + (imag(z)-imag(a))*(real(b)-real(a))/(imag(b)-imag(a))
+			if real(z) < x {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// Path is an open polyline in the complex plane, such as a stretch of the
+// critical line
+type Path struct {
+	Points []complex128
+}
+
+// Bounds ...
+func (p Path) Bounds() (complex128, complex128) {
+	return bounds(p.Points)
+}
+
+// Intersects ...
+func (p Path) Intersects(min, max complex128) bool {
+	if len(p.Points) == 1 {
+		return Rect{Min: p.Points[0], Max: p.Points[0]}.Intersects(min, max)
+	}
+
+	for i := 1; i < len(p.Points); i++ {
+		if segmentIntersects(p.Points[i-1], p.Points[i], min, max) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRect parses a rectangle given as "minReal,minImag,maxReal,maxImag"
+func ParseRect(s string) (Rect, error) {
+	tok := strings.Split(s, ",")
+	if len(tok) != 4 {
+		return Rect{}, fmt.Errorf("expected minReal,minImag,maxReal,maxImag but got %q", s)
+	}
+
+	var v [4]float64
+	for i := range tok {
+		f, err := strconv.ParseFloat(strings.TrimSpace(tok[i]), 64)
+		if err != nil {
+			return Rect{}, err
+		}
+		v[i] = f
+	}
+
+	if v[2] < v[0] || v[3] < v[1] {
+		return Rect{}, fmt.Errorf("rectangle max is less than min: %q", s)
+	}
+
+	return Rect{Min: complex(v[0], v[1]), Max: complex(v[2], v[3])}, nil
+}
+
+// geoJSON is the subset of GeoJSON we understand. Coordinates are given as
+// [real, imag] pairs rather than longitude and latitude.
+type geoJSON struct {
+	Type       string          `json:"type"`
+	Features   []geoJSON       `json:"features"`
+	Geometry   *geoJSON        `json:"geometry"`
+	Properties geoProperties   `json:"properties"`
+	Coords     json.RawMessage `json:"coordinates"`
+}
+
+type geoProperties struct {
+	Name    string `json:"name"`
+	MinZoom *int   `json:"minZoom"`
+	MaxZoom *int   `json:"maxZoom"`
+}
+
+// LoadRegions reads a GeoJSON-like file of features whose coordinates are in
+// the complex plane. Polygon, MultiPolygon and LineString geometries are
+// supported (polygon holes are ignored). Features may set "minZoom" and
+// "maxZoom" properties, otherwise the given defaults are used.
+func LoadRegions(fname string, minZoom, maxZoom int) ([]RegionSpec, error) {
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc geoJSON
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	features := []geoJSON{doc}
+	if doc.Type == "FeatureCollection" {
+		features = doc.Features
+	}
+
+	specs := []RegionSpec{}
+	for i, f := range features {
+		if f.Type != "Feature" || f.Geometry == nil {
+			return nil, fmt.Errorf("region %d: expected a Feature with a geometry", i)
+		}
+
+		regions, err := parseGeometry(f.Geometry)
+		if err != nil {
+			return nil, fmt.Errorf("region %d: %v", i, err)
+		}
+
+		spec := RegionSpec{Name: f.Properties.Name, MinZoom: minZoom, MaxZoom: maxZoom}
+		if f.Properties.MinZoom != nil {
+			spec.MinZoom = *f.Properties.MinZoom
+		}
+		if f.Properties.MaxZoom != nil {
+			spec.MaxZoom = *f.Properties.MaxZoom
+		}
+
+		for _, r := range regions {
+			spec.Region = r
+			specs = append(specs, spec)
+		}
+	}
+
+	return specs, nil
+}
+
+func parseGeometry(g *geoJSON) ([]Region, error) {
+	switch g.Type {
+	case "LineString":
+		var pts [][2]float64
+		if err := json.Unmarshal(g.Coords, &pts); err != nil {
+			return nil, err
+		}
+		if len(pts) == 0 {
+			return nil, errors.New("empty LineString")
+		}
+		return []Region{Path{Points: toComplex(pts)}}, nil
+
+	case "Polygon":
+		var rings [][][2]float64
+		if err := json.Unmarshal(g.Coords, &rings); err != nil {
+			return nil, err
+		}
+		if len(rings) == 0 || len(rings[0]) < 3 {
+			return nil, errors.New("Polygon needs at least three points")
+		}
+		return []Region{Polygon{Points: toComplex(rings[0])}}, nil
+
+	case "MultiPolygon":
+		var polys [][][][2]float64
+		if err := json.Unmarshal(g.Coords, &polys); err != nil {
+			return nil, err
+		}
+		regions := []Region{}
+		for _, rings := range polys {
+			if len(rings) == 0 || len(rings[0]) < 3 {
+				return nil, errors.New("Polygon needs at least three points")
+			}
+			regions = append(regions, Polygon{Points: toComplex(rings[0])})
+		}
+		return regions, nil
+	}
+
+	return nil, fmt.Errorf("unsupported geometry type %q", g.Type)
+}
+
+func toComplex(pts [][2]float64) []complex128 {
+	c := make([]complex128, len(pts))
+	for i := range pts {
+		c[i] = complex(pts[i][0], pts[i][1])
+	}
+	return c
+}
+
+func bounds(pts []complex128) (complex128, complex128) {
+	if len(pts) == 0 {
+		return 0, 0
+	}
+
+	minR, minI := real(pts[0]), imag(pts[0])
+	maxR, maxI := minR, minI
+	for _, p := range pts[1:] {
+		minR = math.Min(minR, real(p))
+		minI = math.Min(minI, imag(p))
+		maxR = math.Max(maxR, real(p))
+		maxI = math.Max(maxI, imag(p))
+	}
+	return complex(minR, minI), complex(maxR, maxI)
+}
+
+// overlaps reports whether the closed interval [lo, hi] overlaps the half
+// open tile interval [min, max). Zero length intervals are treated as points.
+func overlaps(lo, hi, min, max float64) bool {
+	if lo == hi {
+		return lo >= min && lo < max
+	}
+	return lo < max && hi > min
+}
+
+// segmentIntersects clips the segment a-b against the tile using the
+// Liang-Barsky algorithm and reports whether any of it remains.
+func segmentIntersects(a, b, min, max complex128) bool {
+	dx := real(b) - real(a)
+	dy := imag(b) - imag(a)
+	t0, t1 := 0.0, 1.0
+
+	clip := func(p, q float64) bool {
+		if p == 0 {
+			return q >= 0
+		}
+		r := q / p
+		if p < 0 {
+			if r > t1 {
+				return false
+			}
+			t0 = math.Max(t0, r)
+		} else {
+			if r < t0 {
+				return false
+			}
+			t1 = math.Min(t1, r)
+		}
+		return true
+	}
+
+	if !clip(-dx, real(a)-real(min)) || !clip(dx, real(max)-real(a)) ||
+		!clip(-dy, imag(a)-imag(min)) || !clip(dy, imag(max)-imag(a)) {
+		return false
+	}
+
+	if t0 > t1 {
+		return false
+	}
+
+	// the clipped segment may only be touching the exclusive upper edges
+	t := (t0 + t1) / 2
+	mid := a + complex(dx*t, dy*t)
+	return real(mid) < real(max) && imag(mid) < imag(max)
+}
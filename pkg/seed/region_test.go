@@ -0,0 +1,128 @@
+package seed
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// the tile every region is checked against, from 0 inclusive to 1 + i
+// exclusive
+const tileMin, tileMax = complex(0, 0), complex(1, 1)
+
+func TestIntersects(t *testing.T) {
+	square := func(min, max complex128) Polygon {
+		return Polygon{Points: []complex128{min, complex(real(max), imag(min)), max, complex(real(min), imag(max))}}
+	}
+
+	cases := []struct {
+		name   string
+		region Region
+		want   bool
+	}{
+		{"rect inside", Rect{Min: 0.2 + 0.2i, Max: 0.4 + 0.4i}, true},
+		{"rect around", Rect{Min: -1 - 1i, Max: 2 + 2i}, true},
+		{"rect disjoint", Rect{Min: 2 + 2i, Max: 3 + 3i}, false},
+		{"rect on the lower edge", Rect{Min: -1 + 0i, Max: 2 + 0i}, true},
+		{"rect touching the upper edge", Rect{Min: 0 + 1i, Max: 1 + 2i}, false},
+		{"rect touching the right edge", Rect{Min: 1 + 0i, Max: 2 + 1i}, false},
+		{"point inside", Rect{Min: 0.5 + 0.5i, Max: 0.5 + 0.5i}, true},
+
+		{"polygon inside", square(0.2+0.2i, 0.4+0.4i), true},
+		{"polygon around", Polygon{Points: []complex128{-5 - 5i, 5 - 5i, 0 + 5i}}, true},
+		{"polygon crossing", square(0.5-1i, 2+0.5i), true},
+		{"polygon disjoint", square(2+2i, 3+3i), false},
+		{"polygon touching the upper edge", square(0+1i, 1+2i), false},
+		{"polygon with the tile in its bounds only", Polygon{Points: []complex128{-1 + 3i, 3 + 3i, 3 - 1i}}, false},
+
+		{"path through", Path{Points: []complex128{-1 + 0.5i, 2 + 0.5i}}, true},
+		{"path ending inside", Path{Points: []complex128{-1 - 1i, 0.5 + 0.5i}}, true},
+		{"path along the lower edge", Path{Points: []complex128{-1, 2}}, true},
+		{"path along the upper edge", Path{Points: []complex128{-1 + 1i, 2 + 1i}}, false},
+		{"path through the upper corner", Path{Points: []complex128{0 + 2i, 2 + 0i}}, false},
+		{"path disjoint", Path{Points: []complex128{2 + 2i, 3 + 3i, 4 + 2i}}, false},
+		{"path point inside", Path{Points: []complex128{0.1 + 0.9i}}, true},
+	}
+
+	for _, c := range cases {
+		if got := c.region.Intersects(tileMin, tileMax); got != c.want {
+			t.Errorf("%s: intersects %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestParseRect(t *testing.T) {
+	r, err := ParseRect("-30, -1.5,30,1e3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Min != complex(-30, -1.5) || r.Max != complex(30, 1000) {
+		t.Errorf("parsed %v", r)
+	}
+
+	for _, s := range []string{"", "1,2,3", "1,2,3,4,5", "a,0,1,1", "1,0,0,1", "0,1,1,0"} {
+		if _, err := ParseRect(s); err == nil {
+			t.Errorf("%q parsed without an error", s)
+		}
+	}
+}
+
+func TestLoadRegions(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, doc string) string {
+		fname := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(fname, []byte(doc), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return fname
+	}
+
+	fname := write("regions.json", `{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "properties": {"name": "critical line", "minZoom": 4, "maxZoom": 10},
+		 "geometry": {"type": "LineString", "coordinates": [[0.5, 0], [0.5, 1000]]}},
+		{"type": "Feature", "properties": {"name": "squares"},
+		 "geometry": {"type": "MultiPolygon", "coordinates": [
+			[[[0, 0], [1, 0], [1, 1], [0, 1]]],
+			[[[5, 5], [6, 5], [6, 6]]]
+		 ]}}
+	]}`)
+
+	specs, err := LoadRegions(fname, 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 3 {
+		t.Fatalf("loaded %d regions, want 3", len(specs))
+	}
+	if s := specs[0]; s.Name != "critical line" || s.MinZoom != 4 || s.MaxZoom != 10 {
+		t.Errorf("line is %+v", s)
+	}
+	if _, ok := specs[0].Region.(Path); !ok {
+		t.Errorf("line is a %T", specs[0].Region)
+	}
+	for _, s := range specs[1:] {
+		if s.Name != "squares" || s.MinZoom != 1 || s.MaxZoom != 3 {
+			t.Errorf("square is %+v", s)
+		}
+	}
+	if !specs[1].Region.Intersects(tileMin, tileMax) || specs[2].Region.Intersects(tileMin, tileMax) {
+		t.Error("the polygons were read wrongly")
+	}
+
+	malformed := map[string]string{
+		"json":       `{"type": "FeatureCollection", "features": [`,
+		"geometry":   `{"type": "Feature", "properties": {}}`,
+		"type":       `{"type": "Feature", "geometry": {"type": "Point", "coordinates": [0, 0]}}`,
+		"triangle":   `{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": [[[0, 0], [1, 1]]]}}`,
+		"line":       `{"type": "Feature", "geometry": {"type": "LineString", "coordinates": []}}`,
+		"coordinate": `{"type": "Feature", "geometry": {"type": "LineString", "coordinates": [["a", 0]]}}`,
+	}
+	for name, doc := range malformed {
+		if _, err := LoadRegions(write(name+".json", doc), 1, 3); err == nil {
+			t.Errorf("malformed %s loaded without an error", name)
+		}
+	}
+	if _, err := LoadRegions(filepath.Join(dir, "missing.json"), 1, 3); err == nil {
+		t.Error("loaded a missing file")
+	}
+}
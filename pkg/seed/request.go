@@ -2,8 +2,8 @@ package seed
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
-	"math"
 	"os"
 	"zetamachine/pkg/zeta"
 
@@ -11,17 +11,35 @@ import (
 	"github.com/nsqio/go-nsq"
 )
 
-// Requester ...
+// RequestTopic returns the topic tile requests of the given priority tier are
+// published on. Tier zero is the highest priority and uses the original
+// request topic so that a single tier behaves as it always has.
+func RequestTopic(tier int) string {
+	if tier == 0 {
+		return requestPatchTopic
+	}
+	return fmt.Sprintf("%s-%d", requestPatchTopic, tier)
+}
+
+// Requester publishes a request for every missing tile in its coverage. Each
+// zoom level is sorted by the order, lowest zoom first, and split evenly
+// across the priority tiers so the first tiles in the order are published
+// to the highest priority topic. The tiers are per zoom level, not across
+// them, so the first tiles of every zoom come before the last of any.
 type Requester struct {
 	producer *nsq.Producer
 	valve    *valve.Valve
-	minZoom  int
-	maxZoom  int
-	bulbOnly bool
+	coverage *Coverage
+	order    Order
+	tiers    int
 }
 
-// NewRequester ...
-func NewRequester(v *valve.Valve, minZoom, maxZoom int, bulbOnly bool) (*Requester, error) {
+// NewRequester constructs a Requester for the tiles in the coverage
+func NewRequester(v *valve.Valve, coverage *Coverage, order Order, tiers int) (*Requester, error) {
+	if tiers < 1 {
+		return nil, fmt.Errorf("need at least one priority tier, got %d", tiers)
+	}
+
 	config := nsq.NewConfig()
 	p, err := nsq.NewProducer(os.Getenv("ZETA_NSQD"), config)
 	if err != nil {
@@ -32,9 +50,9 @@ func NewRequester(v *valve.Valve, minZoom, maxZoom int, bulbOnly bool) (*Request
 	return &Requester{
 		producer: p,
 		valve:    v,
-		minZoom:  minZoom,
-		maxZoom:  maxZoom,
-		bulbOnly: bulbOnly,
+		coverage: coverage,
+		order:    order,
+		tiers:    tiers,
 	}, nil
 }
 
@@ -43,114 +61,62 @@ func (r *Requester) Start() {
 	go func() {
 		defer r.producer.Stop()
 
-		log.Println("[request] zoom:", r.minZoom, "-", r.maxZoom)
+		minZoom, maxZoom := r.coverage.ZoomRange()
+		log.Println("[request] zoom:", minZoom, "-", maxZoom)
 
 		// tileCount := int(math.Pow(2, float64(zoom+1)))
-		for zoom := r.minZoom; zoom <= r.maxZoom; zoom++ {
+		for zoom := minZoom; zoom <= maxZoom; zoom++ {
+			sent, skipped, ok := r.requestZoom(zoom)
+			log.Println("zoom:", zoom, " done. sent:", sent, " skipped:", skipped)
 
-			yCount := r.requestBulb(zoom)
-
-			if !r.bulbOnly {
-				r.requestArms(yCount, zoom)
+			if !ok {
+				break
 			}
-
-			log.Println("zoom:", zoom, " done")
 		}
 		r.valve.Shutdown(0)
 	}()
 }
 
-// requestBulb is a helper that requests tiles around the bulb area in the middle
-// of the display near the origin. It is only valid for zoom levels of 1 or greater.
-func (r *Requester) requestBulb(zoom int) int {
-	log.Println("[request] -- requesting bulb --")
-	xRange := math.Max(float64(zeta.TileWidth/zoom/8), 30.0)
-	yRange := math.Max(float64(zeta.TileWidth/zoom/8), 20.0)
-
-	ppu := math.Pow(2, float64(zoom))
-	units := float64(zeta.TileWidth) / ppu // units per tile
-
-	// how many patches in each direction
-	xCount := int(math.Max(1, xRange/units))
-	yCount := int(math.Max(1, yRange/units))
-
-	r.requestRange(zoom, xCount, -yCount, yCount)
-	return yCount
-}
-
-// requestArms is a helper to request tiles for the arms extending from the bulb
-// in the imaginary axis. It is hard coded to only go to +/- 4096 on the 
-// imaginary axis.
-func (r *Requester) requestArms(yStart, zoom int) {
-	xRange := math.Max(float64(zeta.TileWidth/zoom/8), 6.0)
-	yRange := 4096.0 // same
-
-	ppu := math.Pow(2, float64(zoom))
-	units := float64(zeta.TileWidth) / ppu // units per tile
-
-	// how many patches in each direction
-	xCount := int(math.Max(2, xRange/units))
-	yCount := int(math.Max(2, yRange/units))
-
-	log.Println("[request] -- requesting positive arm -- ", yStart, yCount, " yrange, units", yRange, units)
-	r.requestRange(zoom, xCount, yStart, yCount)
-
-	log.Println("[request] -- requesting negative arm --", -yCount+1, -yStart)
-	r.requestRange(zoom, xCount, -yCount+1, -yStart) // count and start need reversed
-}
-
-// requestRange generates request messages for tiles. Tiles are numbered in the
-// x and y directions. The number of tiles in the x-direction (the real axis)
-// are from -xCount to xCount-1
-func (r *Requester) requestRange(zoom, xCount, yStart, yEnd int) (int, int) {
-	log.Println("[requestRange] zoom:", zoom, " xrange ", -xCount, " to ", xCount-1)
-	log.Println("\tyrange ", yStart, " to ", yEnd-1)
-
-	sent := 0
+// requestZoom generates request messages for every tile covered at this zoom
+// level. It returns false if the valve was shut down part way through.
+func (r *Requester) requestZoom(zoom int) (int, int, bool) {
 	skipped := 0
+	tiles := []*zeta.Tile{}
+
+	r.coverage.Each(zoom, func(t *zeta.Tile) bool {
+		info, _ := t.Exists()
+		if info != nil {
+			log.Println("[request] skipping. tile exists: ", t)
+			skipped++
+			return true
+		}
 
-	for x := -xCount; x < xCount; x++ {
-		for y := yStart; y < yEnd; y++ {
+		tiles = append(tiles, t)
+		return true
+	})
 
-			// rl := -xr + units*float64(x+xCount)
-			// im := -yRange + units*float64(y+yCount)
+	r.order.Sort(tiles)
 
-			t := &zeta.Tile{
-				Zoom:  int(zoom),
-				X:     x,
-				Y:     y,
-				Width: zeta.TileWidth,
-			}
+	for i, t := range tiles {
+		tier := i * r.tiers / len(tiles)
+		log.Println("[request] tier:", tier, "tile:", t)
 
-			info, err := t.Exists()
-			if info != nil {
-				log.Println("[request] skipping. tile exists: ", t)
-				skipped++
-				continue
-			}
-
-			log.Println("[request] tile:", t)
-
-			_, err = r.send(t)
-			if err != nil {
-				log.Fatal(err)
-			}
-			sent++
+		if _, err := r.send(t, RequestTopic(tier)); err != nil {
+			log.Fatal(err)
+		}
 
-			select {
-			case <-r.valve.Stop(): // valve is being shutdown
-				return sent, skipped
-			default:
-			}
+		select {
+		case <-r.valve.Stop(): // valve is being shutdown
+			return i + 1, skipped, false
+		default:
 		}
 	}
 
-	log.Println("[requestRange] sent:", sent, " skipped:", skipped)
-	return sent, skipped
+	return len(tiles), skipped, true
 }
 
 // Send ...
-func (r *Requester) send(tile *zeta.Tile) (bool, error) {
+func (r *Requester) send(tile *zeta.Tile, topic string) (bool, error) {
 
 	msg, err := json.Marshal(tile)
 	if err != nil {
@@ -160,7 +126,7 @@ func (r *Requester) send(tile *zeta.Tile) (bool, error) {
 
 	// Synchronously publish a single message to the specified topic.
 	// Messages can also be sent asynchronously and/or in batches.
-	err = r.producer.Publish(requestPatchTopic, msg)
+	err = r.producer.Publish(topic, msg)
 	if err != nil {
 		log.Println("[requester] failed to publish message: ", err)
 		return false, err
@@ -168,3 +134,32 @@ func (r *Requester) send(tile *zeta.Tile) (bool, error) {
 
 	return true, nil
 }
+
+// Republish requests each tile again on the highest priority topic, such as
+// tiles whose stored data was found damaged and removed
+func Republish(tiles []*zeta.Tile) error {
+	p, err := nsq.NewProducer(os.Getenv("ZETA_NSQD"), nsq.NewConfig())
+	if err != nil {
+		return err
+	}
+	defer p.Stop()
+
+	r := &Requester{producer: p}
+	for _, t := range tiles {
+		req := &zeta.Tile{
+			Zoom:     t.Zoom,
+			X:        t.X,
+			Y:        t.Y,
+			Width:    t.Width,
+			Function: t.Function,
+			Params:   t.Params,
+			Mode:     t.Mode,
+			Sampling: t.Sampling,
+			LUT:      t.LUT,
+		}
+		if _, err := r.send(req, RequestTopic(0)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,13 +2,13 @@ package seed
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"path"
+	"os"
 	"runtime"
-	"strings"
 	"time"
-	"zetamachine/pkg/palette"
+	"zetamachine/pkg/rendition"
 	"zetamachine/pkg/utils"
 	"zetamachine/pkg/zeta"
 
@@ -23,15 +23,24 @@ const (
 
 // Store handles the storage of completed tiles to local disk
 type Store struct {
-	valve *valve.Valve
-	spin  *spinner.Spinner
+	valve      *valve.Valve
+	spin       *spinner.Spinner
+	renditions []*rendition.Rendition
+	widths     *zeta.SetWidths
 }
 
-// NewStore constructs a new Store instance
-func NewStore(v *valve.Valve) (*Store, error) {
+// NewStore constructs a new Store instance that draws each tile it stores in
+// every rendition
+func NewStore(v *valve.Valve, renditions []*rendition.Rendition) (*Store, error) {
+	if len(renditions) == 0 {
+		return nil, errors.New("the store needs at least one rendition")
+	}
+
 	s := &Store{
-		valve: v,
-		spin:  spinner.New(spinner.CharSets[43], 100*time.Millisecond),
+		valve:      v,
+		spin:       spinner.New(spinner.CharSets[43], 100*time.Millisecond),
+		renditions: renditions,
+		widths:     zeta.NewSetWidths(os.Getenv("ZETA_TILE_PATH")),
 	}
 
 	return s, nil
@@ -72,17 +81,31 @@ func (s *Store) HandleMessage(m *nsq.Message) error {
 		return err
 	}
 
+	// a tile that doesn't fit its set would never be stored, so it is
+	// dropped rather than requeued
+	width, err := s.widths.Width(tile.Set())
+	if err != nil {
+		log.Println("[store] failed to read set metadata: ", err)
+		return err
+	}
+	if tile.Width != width {
+		log.Println("[store] dropping tile", tile.Width, "pixels wide in a set of", width, "pixel tiles:", tile)
+		return nil
+	}
+
 	// s.spin.Suffix = " saving " + tile.Filename()
 	if err := tile.Save(); err != nil {
 		log.Println("[store] error saving tile: ", err)
 		return err
 	}
 
-	fname := strings.TrimSuffix(tile.Filename(), ".dat.gz")
-	fpath := path.Join(tile.Path(), fname+".png")
-	s.spin.Suffix = " saving png " + fpath
-	if err := tile.SavePNG(palette.DefaultPalette, fpath); err != nil {
-		log.Println("[store] error saving tile: ", err)
+	// the data is safe, so a rendition that fails is not worth requeueing for
+	root := os.Getenv("ZETA_TILE_PATH")
+	for _, r := range s.renditions {
+		s.spin.Suffix = " saving " + r.Filename(root, tile)
+		if err := r.Write(root, tile); err != nil {
+			log.Println("[store] error saving tile: ", err)
+		}
 	}
 
 	// Returning a non-nil error will automatically send a REQ command to NSQ to re-queue the message.
@@ -2,11 +2,16 @@ package web
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
 	"image/png"
 	"log"
+	"math"
+	"math/cmplx"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"strconv"
@@ -35,14 +40,31 @@ func (s *Server) serveIndex() http.HandlerFunc {
 			im, err = strconv.ParseFloat(r.URL.Query().Get("imag"), 64)
 		}
 
+		// the function and its parameters select the set of tiles shown
+		tileSet, err := querySet(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		proj, err := s.setProjection(tileSet)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if tileSet != "" {
+			tileSet += "/"
+		}
+
 		goview.DefaultConfig.DisableCache = true
 		err = goview.Render(w, http.StatusOK, "index.html", goview.M{
-			"host":       s.host + ":" + s.port,
-			"subdomains": strings.Join(s.subdomains, ""),
-			"zoom":       zoom,
-			"real":       rl,
-			"imag":       im,
-			"tileSize":   zeta.TileWidth,
+			"host":           s.host + ":" + s.port,
+			"subdomains":     strings.Join(s.subdomains, ""),
+			"zoom":           zoom,
+			"real":           rl,
+			"imag":           im,
+			"tileSize":       proj.TileWidth,
+			"transformation": proj.Transformation(),
+			"tileSet":        tileSet,
 		})
 
 		if err != nil {
@@ -52,11 +74,215 @@ func (s *Server) serveIndex() http.HandlerFunc {
 	}
 }
 
+// querySet returns the name of the tile set selected by the mode, function
+// and parameters in the index query
+func querySet(r *http.Request) (string, error) {
+	t := &zeta.Tile{}
+	if err := t.ParseQuery(r.URL.Query()); err != nil {
+		return "", err
+	}
+	return t.Set(), nil
+}
+
+// setProjection returns the projection of the set's tiles, which are as wide
+// as its metadata says
+func (s *Server) setProjection(set string) (zeta.Projection, error) {
+	width, err := s.widths.Width(set)
+	if err != nil {
+		return zeta.Projection{}, err
+	}
+	return zeta.Projection{TileWidth: width}, nil
+}
+
+// serveOrbit returns the orbit of the point real + imag i as JSON. Any other
+// query values select the function as they do for tiles.
+func (s *Server) serveOrbit() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		pt, err := queryPoint(query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		t := &zeta.Tile{}
+		if err := t.ParseQuery(query); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		f, _ := t.Func()
+		trace := zeta.Orbit(pt, zeta.OrbitParams{Func: f})
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(trace); err != nil {
+			log.Println("[orbit] failed to encode:", err)
+		}
+	}
+}
+
+// queryPoint parses the point real + imag i of an orbit or probe query. Both
+// parts must be finite.
+func queryPoint(query url.Values) (complex128, error) {
+	var parts [2]float64
+	for i, name := range []string{"real", "imag"} {
+		v, err := strconv.ParseFloat(query.Get(name), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %v", name, err)
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return 0, fmt.Errorf("invalid %s: %s is not finite", name, query.Get(name))
+		}
+		parts[i] = v
+	}
+	return complex(parts[0], parts[1]), nil
+}
+
+// maxProbeZoom is the deepest zoom level searched for a stored tile
+const maxProbeZoom = 9
+
+// probe describes a single point of the map
+type probe struct {
+	Real       float64      `json:"real"`
+	Imag       float64      `json:"imag"`
+	Stored     *storedPixel `json:"stored"`           // nil if no tile is stored
+	Iterations uint16       `json:"iterations"`       // the pixel's data computed afresh
+	Reason     string       `json:"reason,omitempty"` // why the iteration stopped, empty without one
+	Value      *[2]float64  `json:"value"`            // f(s) as [real, imag], null if not finite
+}
+
+// storedPixel is the pixel containing a point in the deepest stored tile
+type storedPixel struct {
+	Zoom   int         `json:"zoom"`
+	X      int         `json:"x"`
+	Y      int         `json:"y"`
+	PixelX int         `json:"pixelX"`
+	PixelY int         `json:"pixelY"`
+	Data   uint16      `json:"data"`            // iteration count or Newton root and count
+	Value  *[2]float64 `json:"value,omitempty"` // domain colouring value
+}
+
+// serveProbe reports on the point real + imag i: the pixel stored in the
+// deepest tile at or above zoom that contains it, the data of that pixel
+// computed afresh the same way with the reason the point's iteration stopped
+// (the zeta orbit, or Newton's method in ModeNewton), and the value of the function there. With no tile stored the pixel is
+// computed in the tile at zoom. Any other query values select the function as
+// they do for tiles.
+func (s *Server) serveProbe() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		pt, err := queryPoint(query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		zoom := maxProbeZoom
+		if query.Get("zoom") != "" {
+			zoom, err = strconv.Atoi(query.Get("zoom"))
+			if err != nil {
+				http.Error(w, "invalid zoom: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if zoom < 0 || zoom > maxProbeZoom {
+				http.Error(w, fmt.Sprintf("invalid zoom: %d is not from 0 to %d", zoom, maxProbeZoom), http.StatusBadRequest)
+				return
+			}
+		}
+
+		set := &zeta.Tile{}
+		if err := set.ParseQuery(query); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f, _ := set.Func()
+
+		proj, err := s.setProjection(set.Set())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		tile, stored := findStored(proj, set, pt, zoom)
+		p := probe{
+			Real:   real(pt),
+			Imag:   imag(pt),
+			Stored: stored,
+			Value:  jsonComplex(f.Eval(pt)),
+		}
+		switch set.Mode {
+		case zeta.ModeNewton:
+			p.Reason = zeta.NewtonReason(f, pt)
+		case "":
+			p.Reason = zeta.Orbit(pt, zeta.OrbitParams{Func: f}).Reason
+		}
+		if set.Mode != zeta.ModeDomain {
+			x, y := tile.Pixel(pt)
+			p.Iterations = tile.ComputePixel(f, x, y)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(p); err != nil {
+			log.Println("[probe] failed to encode:", err)
+		}
+	}
+}
+
+// findStored searches from zoom up to zoom 0 for a stored tile of the set
+// containing the point and returns it and its pixel there. If there is none
+// it returns the tile at zoom and a nil pixel.
+func findStored(proj zeta.Projection, set *zeta.Tile, pt complex128, zoom int) (*zeta.Tile, *storedPixel) {
+	at := func(zoom int) *zeta.Tile {
+		t := proj.TileAt(zoom, pt)
+		t.Function, t.Params, t.Mode, t.Sampling, t.LUT = set.Function, set.Params, set.Mode, set.Sampling, set.LUT
+		return t
+	}
+
+	for z := zoom; z >= 0; z-- {
+		t := at(z)
+		if info, _ := t.Exists(); info == nil {
+			continue
+		}
+		if err := t.Load(); err != nil {
+			log.Println("[probe] failed to load tile:", t, err)
+			continue
+		}
+
+		x, y := t.Pixel(pt)
+		i := y*t.Width + x
+		sp := &storedPixel{Zoom: t.Zoom, X: t.X, Y: t.Y, PixelX: x, PixelY: y}
+		switch {
+		case t.Mode == zeta.ModeDomain && 2*i+1 < len(t.Values):
+			sp.Value = jsonComplex(t.DomainValue(i))
+		case i < len(t.Data):
+			sp.Data = t.Data[i]
+		default:
+			continue
+		}
+		return t, sp
+	}
+	return at(zoom), nil
+}
+
+// jsonComplex returns z as [real, imag] or nil if it can't be encoded
+func jsonComplex(z complex128) *[2]float64 {
+	if cmplx.IsNaN(z) || cmplx.IsInf(z) {
+		return nil
+	}
+	return &[2]float64{real(z), imag(z)}
+}
+
 func (s *Server) serveTile() http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var img image.Image
 
-		tile, err := zeta.RequestToTile(r)
+		tile, err := zeta.RequestToTile(r, s.widths)
+		if errors.Is(err, zeta.ErrInvalidRequest) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -0,0 +1,188 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"zetamachine/pkg/zeta"
+)
+
+func TestServeOrbit(t *testing.T) {
+	s := &Server{}
+
+	for _, query := range []string{"real=Inf&imag=0", "real=0&imag=NaN", "real=-inf&imag=1", "real=x&imag=1", "imag=1", "real=1&imag=2&function=gamma"} {
+		rec := httptest.NewRecorder()
+		s.serveOrbit()(rec, httptest.NewRequest("GET", "/orbit?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.serveOrbit()(rec, httptest.NewRequest("GET", "/orbit?real=-3&imag=1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	trace := &zeta.Trace{}
+	if err := json.NewDecoder(rec.Body).Decode(trace); err != nil {
+		t.Fatal(err)
+	}
+	if want := zeta.Orbit(-3+1i, zeta.OrbitParams{}); trace.Iterations != want.Iterations || len(trace.Steps) != len(want.Steps) {
+		t.Errorf("served %d iterations in %d steps, want %d in %d", trace.Iterations, len(trace.Steps), want.Iterations, len(want.Steps))
+	}
+}
+
+func TestServeProbe(t *testing.T) {
+	defer os.Setenv("ZETA_TILE_PATH", os.Getenv("ZETA_TILE_PATH"))
+	root := t.TempDir()
+	os.Setenv("ZETA_TILE_PATH", root)
+
+	stored := &zeta.Tile{Zoom: 2, X: -1, Y: 0, Width: 16}
+	if err := zeta.SaveMetadata(root, stored.Set(), &zeta.Metadata{TileWidth: 16}); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&zeta.CPUBackend{}).Compute(context.Background(), stored); err != nil {
+		t.Fatal(err)
+	}
+	if err := stored.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{widths: zeta.NewSetWidths(root)}
+	get := func(query string) (*probe, int) {
+		rec := httptest.NewRecorder()
+		s.serveProbe()(rec, httptest.NewRequest("GET", "/probe?"+query, nil))
+		if rec.Code != http.StatusOK {
+			return nil, rec.Code
+		}
+		p := &probe{}
+		if err := json.NewDecoder(rec.Body).Decode(p); err != nil {
+			t.Fatal(err)
+		}
+		return p, rec.Code
+	}
+
+	// every pixel found below the stored zoom is computed afresh to the same
+	// count
+	for y := 0; y < stored.Width; y++ {
+		for x := 0; x < stored.Width; x++ {
+			pt := stored.Coord(x, y) + complex(0.1, 0.1)/complex(stored.PPU(), 0)
+			p, code := get(fmt.Sprintf("real=%g&imag=%g&zoom=5", real(pt), imag(pt)))
+			if p == nil {
+				t.Fatalf("%v: status %d", pt, code)
+			}
+			if p.Stored == nil || p.Stored.Zoom != 2 || p.Stored.PixelX != x || p.Stored.PixelY != y {
+				t.Fatalf("%v: stored pixel %+v, want %d, %d at zoom 2", pt, p.Stored, x, y)
+			}
+			if p.Stored.Data != stored.Data[y*stored.Width+x] || p.Iterations != p.Stored.Data {
+				t.Fatalf("%v: stored %d, probed %d stored and %d fresh", pt, stored.Data[y*stored.Width+x], p.Stored.Data, p.Iterations)
+			}
+		}
+	}
+
+	// with nothing stored the pixel of the tile at zoom is computed
+	p, _ := get("real=2.3&imag=1.7&zoom=3")
+	at := zeta.Projection{TileWidth: 16}.TileAt(3, 2.3+1.7i)
+	x, y := at.Pixel(2.3 + 1.7i)
+	if p.Stored != nil || p.Iterations != at.ComputePixel(zeta.Zeta{}, x, y) || p.Reason == "" {
+		t.Errorf("unstored point: %+v", p)
+	}
+
+	// in Newton mode the reason is why Newton's method stopped, not the orbit
+	for query, want := range map[string]string{
+		"real=0.6&imag=14.2&mode=newton": zeta.Converged,
+		"real=30&imag=1&mode=newton":     zeta.Diverged,
+		"real=30&imag=1":                 zeta.Escaped,
+		"real=30&imag=1&mode=domain":     "",
+	} {
+		if p, code := get(query); p == nil || p.Reason != want {
+			t.Errorf("%s: status %d, probe %+v, want reason %q", query, code, p, want)
+		}
+	}
+	if p, _ := get("real=0.6&imag=14.2&mode=newton"); p != nil {
+		if root, _ := zeta.NewtonData(p.Iterations); root != 1 {
+			t.Errorf("Newton probe found root %d, want 1", root)
+		}
+	}
+
+	for _, zoom := range []string{"-1", "10", "1000000000", "x"} {
+		if _, code := get("real=-2&imag=1&zoom=" + zoom); code != http.StatusBadRequest {
+			t.Errorf("zoom %s: status %d, want %d", zoom, code, http.StatusBadRequest)
+		}
+	}
+	for _, query := range []string{"real=Inf&imag=0", "real=0&imag=NaN", "real=1&imag=-Inf"} {
+		if _, code := get(query); code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", query, code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestServeTile(t *testing.T) {
+	defer os.Setenv("ZETA_TILE_PATH", os.Getenv("ZETA_TILE_PATH"))
+	root := t.TempDir()
+	os.Setenv("ZETA_TILE_PATH", root)
+
+	s := &Server{widths: zeta.NewSetWidths(root)}
+	r, err := s.routes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+		return rec
+	}
+
+	// a set with no tiles yet may be stored at any width, so its width is
+	// not remembered
+	if rec := get("/tile/1/0/0/?function=eta"); rec.Code != http.StatusNotFound {
+		t.Fatalf("unstored tile: status %d", rec.Code)
+	}
+
+	stored := &zeta.Tile{Zoom: 1, X: 0, Y: 0, Width: 16, Function: "eta"}
+	if err := zeta.SaveMetadata(root, stored.Set(), &zeta.Metadata{TileWidth: 16}); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&zeta.CPUBackend{}).Compute(context.Background(), stored); err != nil {
+		t.Fatal(err)
+	}
+	if err := stored.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// values the function doesn't take, like a cache buster, are ignored
+	rec := get("/tile/1/0/0/?function=eta&v=123")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	img, err := png.Decode(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 16 {
+		t.Fatalf("tile is %d pixels wide, want 16", img.Bounds().Dx())
+	}
+
+	// the width is read once, not on every request
+	if err := zeta.SaveMetadata(root, stored.Set(), &zeta.Metadata{TileWidth: 8}); err != nil {
+		t.Fatal(err)
+	}
+	rec = get("/tile/1/0/0/?function=eta")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if img, err = png.Decode(rec.Body); err != nil || img.Bounds().Dx() != 16 {
+		t.Fatalf("the set's width was read again: %v", err)
+	}
+
+	for _, url := range []string{"/tile/1/0/0/?function=gamma", "/tile/1/0/0/?function=hurwitz", "/tile/1/0/0/?function=hurwitz&a=x", "/tile/x/0/0/", "/tile/1/0/0/?lut=maybe"} {
+		if rec := get(url); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", url, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"strings"
 	"time"
+	"zetamachine/pkg/zeta"
 
 	"github.com/go-chi/valve"
 
@@ -21,6 +22,7 @@ type Server struct {
 	port       string
 	subdomains []string
 	valve      *valve.Valve
+	widths     *zeta.SetWidths
 }
 
 // Run reads the configuration from the environment etc., configures routes and
@@ -54,6 +56,7 @@ func (s *Server) config() error {
 	s.port = os.Getenv("ZETA_PORT")
 	s.subdomains = strings.Split(os.Getenv("ZETA_SUBDOMAINS"), ",")
 	s.valve = valve.New()
+	s.widths = zeta.NewSetWidths(os.Getenv("ZETA_TILE_PATH"))
 
 	return nil
 }
@@ -85,6 +88,8 @@ func (s *Server) routes() (*chi.Mux, error) {
 	r.Use(middleware.Logger)
 	r.Get("/", s.serveIndex())
 	r.Get("/tile/{zoom}/{y}/{x}/", s.serveTile())
+	r.Get("/orbit", s.serveOrbit())
+	r.Get("/probe", s.serveProbe())
 
 	return r, nil
 }
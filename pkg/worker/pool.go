@@ -0,0 +1,371 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"zetamachine/pkg/zeta"
+)
+
+const (
+	// DefaultHealthInterval is how often workers are health checked
+	DefaultHealthInterval = 10 * time.Second
+
+	healthTimeout = 5 * time.Second
+)
+
+func init() {
+	zeta.RegisterBackend("remote", func() (zeta.Backend, error) {
+		urls := []string{}
+		for _, u := range strings.Split(os.Getenv("ZETA_WORKERS"), ",") {
+			if u = strings.TrimSpace(u); u != "" {
+				urls = append(urls, u)
+			}
+		}
+
+		perWorker := 1
+		if n := os.Getenv("ZETA_WORKER_CONCURRENCY"); n != "" {
+			var err error
+			if perWorker, err = strconv.Atoi(n); err != nil {
+				return nil, fmt.Errorf("invalid ZETA_WORKER_CONCURRENCY: %v", err)
+			}
+		}
+
+		p, err := NewPool(urls, perWorker)
+		if err != nil {
+			return nil, err
+		}
+		p.Start(DefaultHealthInterval)
+		return p, nil
+	})
+}
+
+// Pool is a backend that sends tiles to a pool of remote workers (see
+// Server). Each worker is sent at most perWorker tiles at once. If a worker
+// fails the tile is sent to another one and the failed worker is skipped
+// until it passes a health check again. A worker whose backend can't compute
+// the tile is still healthy, the tile is just sent to another one.
+type Pool struct {
+	workers []*remote
+	client  *http.Client
+
+	released chan struct{} // signalled when a worker slot is freed
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	mu   sync.Mutex
+	next int // round robin start
+}
+
+// remote is a single worker in the pool
+type remote struct {
+	url   string
+	slots chan struct{}
+
+	mu      sync.Mutex
+	healthy bool
+}
+
+// permanentError is returned by a worker that refused the tile itself, so
+// sending it to another worker won't help
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// unsupportedError is returned by a worker whose backend can't compute the
+// tile, which another worker's may
+type unsupportedError struct {
+	err error
+}
+
+func (e *unsupportedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *unsupportedError) Unwrap() error {
+	return zeta.ErrUnsupported
+}
+
+// errBusy is returned by a worker already computing as many tiles as it can,
+// perhaps for another pool
+var errBusy = errors.New("worker is busy")
+
+// NewPool constructs a pool of the workers at the base URLs
+func NewPool(urls []string, perWorker int) (*Pool, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no workers given")
+	}
+	if perWorker < 1 {
+		return nil, fmt.Errorf("need at least one tile per worker, got %d", perWorker)
+	}
+
+	p := &Pool{
+		client:   &http.Client{},
+		released: make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+	}
+	for _, u := range urls {
+		p.workers = append(p.workers, &remote{
+			url:     strings.TrimSuffix(u, "/"),
+			slots:   make(chan struct{}, perWorker),
+			healthy: true,
+		})
+	}
+	return p, nil
+}
+
+// Name ...
+func (p *Pool) Name() string {
+	return "remote"
+}
+
+// Start health checks every worker now and then every interval until the
+// pool is closed
+func (p *Pool) Start(interval time.Duration) {
+	p.checkHealth()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.checkHealth()
+			}
+		}
+	}()
+}
+
+// Close stops the health checks
+func (p *Pool) Close() {
+	p.stopOnce.Do(func() { close(p.stop) })
+}
+
+// Healthy returns the number of workers that passed their last health check
+func (p *Pool) Healthy() int {
+	n := 0
+	for _, w := range p.workers {
+		if w.isHealthy() {
+			n++
+		}
+	}
+	return n
+}
+
+// checkHealth checks every worker in parallel
+func (p *Pool) checkHealth() {
+	var wg sync.WaitGroup
+	for _, w := range p.workers {
+		wg.Add(1)
+		go func(w *remote) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+			defer cancel()
+
+			healthy := false
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.url+"/healthz", nil)
+			if err == nil {
+				var resp *http.Response
+				if resp, err = p.client.Do(req); err == nil {
+					resp.Body.Close()
+					healthy = resp.StatusCode == http.StatusOK
+				}
+			}
+
+			if w.isHealthy() != healthy {
+				log.Println("[pool] worker", w.url, "healthy:", healthy, err)
+			}
+			w.setHealthy(healthy)
+		}(w)
+	}
+	wg.Wait()
+}
+
+// Compute sends the tile to a worker, failing over to the others until one
+// computes it
+func (p *Pool) Compute(ctx context.Context, t *zeta.Tile) error {
+	tried := make(map[*remote]bool)
+	var lastErr error
+
+	for {
+		w, err := p.acquire(ctx, tried)
+		if err != nil {
+			if lastErr != nil {
+				return fmt.Errorf("%v, last error: %w", err, lastErr)
+			}
+			return err
+		}
+
+		err = p.send(ctx, w, t)
+		<-w.slots
+		select {
+		case p.released <- struct{}{}:
+		default:
+		}
+
+		if err == nil {
+			return nil
+		}
+
+		var perm *permanentError
+		if errors.As(err, &perm) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// a busy worker is fine, just try again shortly
+		if err == errBusy {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(100 * time.Millisecond):
+			}
+			continue
+		}
+
+		var unsupported *unsupportedError
+		if errors.As(err, &unsupported) {
+			log.Println("[pool] worker", w.url, "can't compute the tile, trying another:", err)
+		} else {
+			log.Println("[pool] worker", w.url, "failed, trying another:", err)
+			w.setHealthy(false)
+		}
+		tried[w] = true
+		lastErr = err
+	}
+}
+
+// acquire waits for a free slot on a worker that hasn't been tried yet,
+// preferring healthy workers
+func (p *Pool) acquire(ctx context.Context, tried map[*remote]bool) (*remote, error) {
+	for {
+		candidates := p.candidates(tried)
+		if len(candidates) == 0 {
+			return nil, errors.New("no workers left to try")
+		}
+
+		for _, w := range candidates {
+			select {
+			case w.slots <- struct{}{}:
+				return w, nil
+			default:
+			}
+		}
+
+		// every candidate is busy
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-p.released:
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// candidates returns the untried workers, healthy ones first, starting from
+// a different worker each time to spread the load
+func (p *Pool) candidates(tried map[*remote]bool) []*remote {
+	p.mu.Lock()
+	start := p.next
+	p.next = (p.next + 1) % len(p.workers)
+	p.mu.Unlock()
+
+	healthy, unhealthy := []*remote{}, []*remote{}
+	for i := range p.workers {
+		w := p.workers[(start+i)%len(p.workers)]
+		if tried[w] {
+			continue
+		}
+		if w.isHealthy() {
+			healthy = append(healthy, w)
+		} else {
+			unhealthy = append(unhealthy, w)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// send computes the tile on the worker
+func (p *Pool) send(ctx context.Context, w *remote, t *zeta.Tile) error {
+	body, err := json.Marshal(t)
+	if err != nil {
+		return &permanentError{err}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url+"/compute", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		e := errorResponse{}
+		json.NewDecoder(resp.Body).Decode(&e)
+		err := fmt.Errorf("worker %s: %s: %s", w.url, resp.Status, e.Error)
+		switch resp.StatusCode {
+		case http.StatusBadRequest:
+			return &permanentError{err}
+		case http.StatusServiceUnavailable:
+			return errBusy
+		case http.StatusUnprocessableEntity:
+			return &unsupportedError{err}
+		}
+		return err
+	}
+
+	result := &zeta.Tile{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("worker %s: invalid tile: %v", w.url, err)
+	}
+
+	// a reply of the wrong size is refused like a tile the worker can't
+	// compute, so another worker gets it
+	n, want := len(result.Data), t.Width*t.Width
+	if t.Mode == zeta.ModeDomain {
+		n, want = len(result.Values), 2*t.Width*t.Width
+	}
+	if n != want {
+		return &unsupportedError{fmt.Errorf("worker %s: %d values returned, expected %d", w.url, n, want)}
+	}
+
+	t.Data = result.Data
+	t.Values = result.Values
+	return nil
+}
+
+func (w *remote) isHealthy() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.healthy
+}
+
+func (w *remote) setHealthy(healthy bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.healthy = healthy
+}
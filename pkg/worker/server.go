@@ -0,0 +1,113 @@
+package worker
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"zetamachine/pkg/zeta"
+
+	"github.com/go-chi/chi"
+)
+
+// Server exposes a backend to remote clients (see Pool) over HTTP.
+//
+//	POST /compute  computes the JSON tile in the body and responds with it
+//	GET  /healthz  reports the backend and how busy the worker is
+//
+// At most limit tiles are computed at once. Requests beyond that are refused
+// with 503 so the client can send them to another worker. Tiles the backend
+// can't compute, such as eta on the GPU, are refused with 422 for the same
+// reason.
+type Server struct {
+	backend zeta.Backend
+	slots   chan struct{}
+	busy    int32
+}
+
+// Health is the response to a health check
+type Health struct {
+	Backend string `json:"backend"`
+	Busy    int    `json:"busy"`
+	Limit   int    `json:"limit"`
+}
+
+// errorResponse is the body of every error response
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewServer constructs a worker server computing up to limit tiles at once
+func NewServer(backend zeta.Backend, limit int) *Server {
+	if limit < 1 {
+		limit = 1
+	}
+	return &Server{backend: backend, slots: make(chan struct{}, limit)}
+}
+
+// Routes returns the handler for the worker API
+func (s *Server) Routes() http.Handler {
+	r := chi.NewRouter()
+	r.Post("/compute", s.compute)
+	r.Get("/healthz", s.health)
+	return r
+}
+
+func (s *Server) compute(w http.ResponseWriter, r *http.Request) {
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	default:
+		writeError(w, http.StatusServiceUnavailable, "worker is busy")
+		return
+	}
+
+	atomic.AddInt32(&s.busy, 1)
+	defer atomic.AddInt32(&s.busy, -1)
+
+	t := &zeta.Tile{}
+	if err := json.NewDecoder(r.Body).Decode(t); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid tile: "+err.Error())
+		return
+	}
+
+	if _, err := t.Func(); err != nil || t.Width <= 0 {
+		msg := "invalid tile width"
+		if err != nil {
+			msg = err.Error()
+		}
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if err := s.backend.Compute(r.Context(), t); err != nil {
+		if errors.Is(err, zeta.ErrUnsupported) {
+			writeError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		log.Println("[worker] failed to compute tile:", t, err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(t); err != nil {
+		log.Println("[worker] failed to write tile:", err)
+	}
+}
+
+func (s *Server) health(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Health{
+		Backend: s.backend.Name(),
+		Busy:    int(atomic.LoadInt32(&s.busy)),
+		Limit:   cap(s.slots),
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: msg})
+}
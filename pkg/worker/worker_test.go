@@ -0,0 +1,300 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"zetamachine/pkg/zeta"
+	"zetamachine/pkg/zeta/zetatest"
+)
+
+// fakeBackend fills tiles with a constant after a delay and records how
+// many it computes at once
+type fakeBackend struct {
+	delay time.Duration
+	fail  bool
+	short bool // leave the last pixel out of the data
+
+	running int32
+	peak    int32
+	calls   int32
+}
+
+func (f *fakeBackend) Name() string {
+	return "fake"
+}
+
+func (f *fakeBackend) Compute(ctx context.Context, t *zeta.Tile) error {
+	atomic.AddInt32(&f.calls, 1)
+	n := atomic.AddInt32(&f.running, 1)
+	defer atomic.AddInt32(&f.running, -1)
+	for {
+		peak := atomic.LoadInt32(&f.peak)
+		if n <= peak || atomic.CompareAndSwapInt32(&f.peak, peak, n) {
+			break
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(f.delay):
+	}
+
+	if f.fail {
+		return errors.New("backend failed")
+	}
+	t.Data = make([]uint16, t.Width*t.Width)
+	for i := range t.Data {
+		t.Data[i] = 7
+	}
+	if f.short {
+		t.Data = t.Data[1:]
+	}
+	return nil
+}
+
+// zetaOnlyBackend computes iterated zeta on the CPU and refuses every other
+// set like the CUDA backend
+type zetaOnlyBackend struct {
+	zeta.CPUBackend
+	refused int32
+}
+
+func (z *zetaOnlyBackend) Name() string {
+	return "zeta-only"
+}
+
+func (z *zetaOnlyBackend) Compute(ctx context.Context, t *zeta.Tile) error {
+	if t.Set() != "" {
+		atomic.AddInt32(&z.refused, 1)
+		return fmt.Errorf("only renders zeta, not %s: %w", t.Set(), zeta.ErrUnsupported)
+	}
+	return z.CPUBackend.Compute(ctx, t)
+}
+
+func newWorker(t *testing.T, b zeta.Backend, limit int) *httptest.Server {
+	s := httptest.NewServer(NewServer(b, limit).Routes())
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newPool(t *testing.T, perWorker int, urls ...string) *Pool {
+	p, err := NewPool(urls, perWorker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+	return p
+}
+
+func smallTile() *zeta.Tile {
+	return &zeta.Tile{Zoom: 4, X: 1, Y: 1, Width: 4}
+}
+
+func TestPoolConformance(t *testing.T) {
+	a := newWorker(t, &zeta.CPUBackend{}, 2)
+	b := newWorker(t, &zeta.CPUBackend{}, 2)
+	zetatest.TestBackend(t, newPool(t, 2, a.URL, b.URL))
+}
+
+func TestPoolFailover(t *testing.T) {
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	failing := newWorker(t, &fakeBackend{fail: true}, 1)
+	good := newWorker(t, &fakeBackend{}, 1)
+
+	p := newPool(t, 1, dead.URL, failing.URL, good.URL)
+	for i := 0; i < 3; i++ {
+		tile := smallTile()
+		if err := p.Compute(context.Background(), tile); err != nil {
+			t.Fatal(err)
+		}
+		if len(tile.Data) != 16 || tile.Data[0] != 7 {
+			t.Fatalf("tile %d was not computed: %v", i, tile.Data)
+		}
+	}
+
+	if n := p.Healthy(); n != 1 {
+		t.Fatalf("%d workers are healthy, want 1", n)
+	}
+}
+
+func TestPoolAllFail(t *testing.T) {
+	failing := newWorker(t, &fakeBackend{fail: true}, 1)
+	p := newPool(t, 1, failing.URL)
+
+	if err := p.Compute(context.Background(), smallTile()); err == nil {
+		t.Fatal("tile computed without a working worker")
+	}
+}
+
+func TestPoolConcurrencyLimit(t *testing.T) {
+	backend := &fakeBackend{delay: 50 * time.Millisecond}
+	w := newWorker(t, backend, 8)
+	p := newPool(t, 2, w.URL)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 6)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = p.Compute(context.Background(), smallTile())
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if peak := atomic.LoadInt32(&backend.peak); peak != 2 {
+		t.Fatalf("worker computed %d tiles at once, want 2", peak)
+	}
+}
+
+func TestPoolBusyWorker(t *testing.T) {
+	// another client keeps the worker busy for a while
+	backend := &fakeBackend{delay: 200 * time.Millisecond}
+	w := newWorker(t, backend, 1)
+	other := newPool(t, 1, w.URL)
+	go other.Compute(context.Background(), smallTile())
+	time.Sleep(50 * time.Millisecond)
+
+	p := newPool(t, 1, w.URL)
+	if err := p.Compute(context.Background(), smallTile()); err != nil {
+		t.Fatal(err)
+	}
+	if p.Healthy() != 1 {
+		t.Fatal("busy worker was marked unhealthy")
+	}
+}
+
+func TestPoolBadTile(t *testing.T) {
+	backend := &fakeBackend{}
+	a := newWorker(t, backend, 1)
+	b := newWorker(t, backend, 1)
+	p := newPool(t, 1, a.URL, b.URL)
+
+	tile := smallTile()
+	tile.Function = "gamma"
+	if err := p.Compute(context.Background(), tile); err == nil {
+		t.Fatal("invalid tile did not return an error")
+	}
+	if n := atomic.LoadInt32(&backend.calls); n != 0 {
+		t.Fatalf("invalid tile was computed %d times", n)
+	}
+	if p.Healthy() != 2 {
+		t.Fatal("worker was marked unhealthy for an invalid tile")
+	}
+}
+
+func TestPoolUnsupportedTile(t *testing.T) {
+	gpu := &zetaOnlyBackend{}
+	p := newPool(t, 1, newWorker(t, gpu, 1).URL, newWorker(t, &zeta.CPUBackend{}, 1).URL)
+
+	// each worker is tried first in turn, and the refusing one hands eta to
+	// the other without being marked unhealthy
+	for _, set := range []string{"eta", ""} {
+		for i := 0; i < 4; i++ {
+			tile := smallTile()
+			tile.Function = set
+			if err := p.Compute(context.Background(), tile); err != nil {
+				t.Fatal(err)
+			}
+			if len(tile.Data) != 16 {
+				t.Fatalf("%q tile was not computed: %v", set, tile.Data)
+			}
+			if n := p.Healthy(); n != 2 {
+				t.Fatalf("%d workers are healthy after a %q tile, want 2", n, set)
+			}
+		}
+	}
+	if n := atomic.LoadInt32(&gpu.refused); n == 0 {
+		t.Fatal("no eta tile was sent to the zeta only worker")
+	}
+
+	// with nothing else to try the refusal is returned
+	only := newPool(t, 1, newWorker(t, &zetaOnlyBackend{}, 1).URL)
+	tile := smallTile()
+	tile.Function = "eta"
+	if err := only.Compute(context.Background(), tile); !errors.Is(err, zeta.ErrUnsupported) {
+		t.Fatalf("computing eta on the zeta only worker returned %v", err)
+	}
+	if only.Healthy() != 1 {
+		t.Fatal("worker was marked unhealthy for a tile it can't compute")
+	}
+}
+
+func TestPoolWrongSizeReply(t *testing.T) {
+	short := newWorker(t, &fakeBackend{short: true}, 1)
+	p := newPool(t, 1, short.URL, newWorker(t, &zeta.CPUBackend{}, 1).URL)
+
+	// replies missing pixels, or with data in place of a domain tile's
+	// values, go to the other worker, and neither is marked unhealthy
+	for _, mode := range []string{"", zeta.ModeDomain} {
+		for i := 0; i < 4; i++ {
+			tile := smallTile()
+			tile.Mode = mode
+			if err := p.Compute(context.Background(), tile); err != nil {
+				t.Fatal(err)
+			}
+			if n := len(tile.Data) + len(tile.Values)/2; n != 16 {
+				t.Fatalf("%q tile has %d pixels, want 16", mode, n)
+			}
+			if n := p.Healthy(); n != 2 {
+				t.Fatalf("%d workers are healthy after a %q tile, want 2", n, mode)
+			}
+		}
+	}
+
+	only := newPool(t, 1, short.URL)
+	if err := only.Compute(context.Background(), smallTile()); err == nil {
+		t.Fatal("a reply missing a pixel was accepted")
+	}
+}
+
+func TestServerUnsupported(t *testing.T) {
+	w := newWorker(t, &zetaOnlyBackend{}, 1)
+	resp, err := http.Post(w.URL+"/compute", "application/json", strings.NewReader(`{"zoom":4,"x":1,"y":1,"width":4,"function":"eta"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("status %s, want %d", resp.Status, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestPoolHealthCheck(t *testing.T) {
+	good := newWorker(t, &fakeBackend{}, 1)
+	dead := newWorker(t, &fakeBackend{}, 1)
+	p := newPool(t, 1, good.URL, dead.URL)
+
+	dead.Close()
+	p.Start(time.Hour)
+	if n := p.Healthy(); n != 1 {
+		t.Fatalf("%d workers are healthy, want 1", n)
+	}
+}
+
+func TestServerHealth(t *testing.T) {
+	w := newWorker(t, &fakeBackend{}, 3)
+	resp, err := http.Get(w.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("health check failed:", resp.Status)
+	}
+}
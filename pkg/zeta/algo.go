@@ -3,58 +3,31 @@ package zeta
 import (
 	"context"
 	"fmt"
-	"image/color"
 	"log"
 	"math"
 	"math/cmplx"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// func DumpPalette() {
-// 	fmt.Println("var Original = []color.Color{")
-// 	for i := 0; i < len(cc); i += 3 {
-// 		fmt.Printf("\tcolor.RGBA{0x%s, 0x%s, 0x%s, 0xff},\n",
-// 			strconv.FormatInt(int64(cc[i]), 16),
-// 			strconv.FormatInt(int64(cc[i+1]), 16),
-// 			strconv.FormatInt(int64(cc[i+2]), 16))
-// 	}
-
-// 	for i := len(cc) / 3; i < 256; i++ {
-// 		fmt.Printf("\tcolor.RGBA{0xff, 0x00, 0xff, 0xff},\n")
-// 	}
-// 	fmt.Println("}")
-// }
-var reverse map[color.RGBA]uint16
-
-func init() {
-
-	// attempting to reverse the color from a lookup table to an iteration
-	reverse := make(map[color.RGBA]uint8)
-	for i := 0; i < len(cc); i += 3 {
-		col := color.RGBA{
-			R: cc[i],
-			G: cc[i+1],
-			B: cc[i+2],
-			A: 255,
-		}
-
-		reverse[col] = uint8(i)
-	}
-}
-
 const (
+	epsilon  = 1e-15
 	minN     = 100
 	maxN     = 1000000
 	cabsZMax = 10000.0
 	maxITs   = 5000
-	maxGamma = 450.0
+
+	// maxTileITs is the largest iteration count stored in a tile, the last
+	// colour of a 256 colour palette. Zeta always decides well before this
+	// but other functions can wander without converging or escaping.
+	maxTileITs = 255
+	maxGamma   = 450.0
 )
 
 var (
 	sqrt2Pi = math.Sqrt(math.Pi * 2)
-	cc      = [300]uint8{0, 0, 0, 0, 0, 0, 0, 0, 255, 0, 60, 255, 0, 100, 255, 0, 125, 255, 0, 140, 255, 0, 155, 255, 0, 170, 255, 0, 180, 255, 0, 190, 255, 0, 200, 255, 0, 210, 255, 0, 220, 255, 0, 225, 255, 0, 230, 255, 0, 235, 255, 0, 238, 255, 0, 241, 255, 0, 244, 255, 0, 247, 255, 0, 250, 255, 0, 253, 255, 0, 255, 255, 0, 255, 255, 0, 255, 255, 0, 255, 255, 0, 255, 255, 0, 255, 255, 0, 255, 255, 0, 255, 255, 0, 255, 255, 0, 255, 255, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 23, 255, 0, 46, 255, 0, 70, 255, 0, 93, 255, 0, 116, 255, 0, 139, 255, 0, 163, 255, 0, 186, 255, 0, 209, 255, 0, 232, 255, 0, 255, 255, 0, 255, 237, 0, 255, 218, 0, 255, 200, 0, 255, 181, 0, 255, 163, 0, 255, 146, 0, 255, 128, 0, 255, 111, 0, 255, 93, 0, 255, 76, 0, 255, 63, 0, 255, 51, 0, 255, 42, 0, 255, 39, 0, 255, 36, 0, 255, 33, 0, 255, 30, 0, 255, 27, 0, 255, 24, 0, 255, 21, 0, 255, 18, 0, 255, 15, 0, 255, 12, 0, 255, 9, 0, 255, 6, 0, 255, 3, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0, 255, 0, 0}
 	bCoeff  = [20]float64{
 		1.0000000000000000000000000000000,
 		0.0833333333333333333333333333333,
@@ -98,102 +71,420 @@ var (
 
 // Algo ...
 type Algo struct {
-	data []uint16
-	wg   *sync.WaitGroup
+	// Func is the function that is iterated. Zeta is used if it is nil.
+	Func Function
+
+	// Mode is how each pixel is computed, the iteration count by default,
+	// ModeNewton or ModeDomain. Func must implement Derivative for
+	// ModeNewton.
+	Mode string
+
+	// Checkpoint, if set, periodically saves completed rows so the tile can be
+	// resumed if computing it is interrupted
+	Checkpoint *Checkpoint
+
+	// LUTs, if set, finish the iteration of zeta early once an iterate lands
+	// on a lookup table close enough to the tile's resolution (see
+	// LUTTolerance)
+	LUTs *LUTSet
+
+	// Sampling chooses the points each pixel is computed from, the lower
+	// left corner by default
+	Sampling Sampling
+
+	data   []uint16
+	values []float32  // real, imaginary pairs for ModeDomain
+	kernel *rowKernel // evaluates the first iterate of whole rows of zeta
+	done   []int32    // set to 1 once a row is complete
+	wg     *sync.WaitGroup
 }
 
 // Compute ...
 func (a *Algo) Compute(ctx context.Context, min, max complex128, tileWidth int) []uint16 {
 	// a.ppu = int(float64(TileWidth) / (real(max - min)))
 	a.data = make([]uint16, tileWidth*tileWidth)
+	a.done = make([]int32, tileWidth)
 	a.wg = &sync.WaitGroup{}
+	if a.Mode == ModeDomain {
+		a.values = make([]float32, 2*tileWidth*tileWidth)
+	}
 
-	stride := len(a.data) / runtime.GOMAXPROCS(0) //TileWidth * TileWidth / 8 // 8 jobs per tile
 	ts := time.Now()
 
-	jobID := 0
-	for start := 0; start < len(a.data); start += stride {
+	if a.Func == nil {
+		a.Func = Zeta{}
+	}
+	if _, ok := a.Func.(Zeta); ok && a.Mode == "" {
+		a.kernel = newRowKernel(min, max)
+	}
+
+	resumed := 0
+	if a.Checkpoint != nil {
+		resumed = a.Checkpoint.restore(a.data, a.done, tileWidth)
+	}
 
-		if start+stride >= len(a.data) {
-			stride = len(a.data) - start
+	// rows are handed out to the jobs one at a time so the completed rows can
+	// be checkpointed
+	rows := make(chan int, tileWidth)
+	for y := 0; y < tileWidth; y++ {
+		if a.done[y] == 0 {
+			rows <- y
 		}
+	}
+	close(rows)
 
+	jobs := runtime.GOMAXPROCS(0)
+	for jobID := 0; jobID < jobs; jobID++ {
 		a.wg.Add(1)
-		go a.computePatch(ctx, jobID, start, stride, min, max, tileWidth)
-		jobID++
+		go a.computePatch(ctx, jobID, rows, min, max, tileWidth)
+	}
+
+	fmt.Println("[algo] computing", min, max, "with", jobs, "jobs.", resumed, "rows resumed from checkpoint")
+
+	finished := make(chan struct{})
+	if a.Checkpoint != nil {
+		go a.Checkpoint.run(finished, a.data, a.done, tileWidth)
 	}
 
-	fmt.Println("[algo] computing", min, max, "with", jobID, "jobs")
 	a.wg.Wait()
+	close(finished)
+
+	if a.Checkpoint != nil {
+		a.Checkpoint.finish(a.data, a.done, tileWidth)
+	}
+
+	if a.Sampling.Method == SampleAdaptive && ctx.Err() == nil {
+		a.refine(ctx, min, max, tileWidth)
+	}
+
 	log.Println("[algo] tile computed in", time.Since(ts))
 	return a.data
 }
 
-// computePatch ...
-func (a *Algo) computePatch(ctx context.Context, jobID, start, stride int, min, max complex128, tileWidth int) {
+// computePatch computes rows until there are none left or the context is
+// canceled
+func (a *Algo) computePatch(ctx context.Context, jobID int, rows <-chan int, min, max complex128, tileWidth int) {
 	defer a.wg.Done()
 
 	ts := time.Now()
+	count := 0
+	offsets := a.Sampling.offsets()
+
+	for y := range rows {
+		if !a.computeRow(ctx, y, offsets, min, max, tileWidth) {
+			log.Println("[algo] job", jobID, "canceled")
+			return
+		}
+		atomic.StoreInt32(&a.done[y], 1)
+		count++
+	}
+
+	if jobID < 8 {
+		fmt.Println("\t", jobID, min, max, count, "rows finished in", time.Since(ts))
+	}
+}
+
+// computeRow samples every pixel of row y at each offset and reduces the
+// samples to the pixel's data. It returns false if the context is canceled.
+func (a *Algo) computeRow(ctx context.Context, y int, offsets [][2]float64, min, max complex128, tileWidth int) bool {
+	counts := make([][]uint16, len(offsets))
+	smooth := make([][]float64, len(offsets))
+	values := make([][]complex128, len(offsets))
+	for i, o := range offsets {
+		switch {
+		case a.Mode == ModeDomain:
+			values[i] = make([]complex128, tileWidth)
+		case a.smooth():
+			smooth[i] = make([]float64, tileWidth)
+		default:
+			counts[i] = make([]uint16, tileWidth)
+		}
+		if !a.sampleRow(ctx, y, o, min, max, tileWidth, counts[i], smooth[i], values[i]) {
+			return false
+		}
+	}
+
+	pc := make([]uint16, len(offsets))
+	ps := make([]float64, len(offsets))
+	pv := make([]complex128, len(offsets))
+	for x := 0; x < tileWidth; x++ {
+		i := y*tileWidth + x
+		switch {
+		case a.Mode == ModeDomain:
+			for k := range offsets {
+				pv[k] = values[k][x]
+			}
+			z := a.Sampling.reduceValues(pv)
+			a.values[2*i] = float32(real(z))
+			a.values[2*i+1] = float32(imag(z))
+		case a.smooth():
+			for k := range offsets {
+				ps[k] = smooth[k][x]
+			}
+			a.data[i] = a.Sampling.reduceSmooth(ps)
+		default:
+			for k := range offsets {
+				pc[k] = counts[k][x]
+			}
+			a.data[i] = a.reduce(pc)
+		}
+	}
+	return true
+}
+
+// smooth reports whether samples are smooth iteration counts to be averaged
+func (a *Algo) smooth() bool {
+	return a.Mode == "" && a.Sampling.Reducer == ReduceMean
+}
+
+// reduce combines the iteration counts or Newton data of a pixel's samples
+func (a *Algo) reduce(samples []uint16) uint16 {
+	if a.Mode == ModeNewton {
+		return a.Sampling.voteRoot(samples)
+	}
+	return a.Sampling.vote(samples)
+}
+
+// sampleRow computes the point at offset o within each pixel of row y, into
+// counts, smooth if the samples are averaged or, in ModeDomain, values.
+// Iterated zeta is computed with the first
+// iterate of every point evaluated together by the row kernel and the rest by
+// its table driven zeta. It returns false if the context is canceled.
+func (a *Algo) sampleRow(ctx context.Context, y int, o [2]float64, min, max complex128, tileWidth int, counts []uint16, smooth []float64, values []complex128) bool {
 	span := max - min
+	v := (float64(y) + o[1]) / float64(tileWidth)
+	t := imag(min) + imag(span)*v
+	ppu := float64(tileWidth) / real(span)
+
+	sig := make([]float64, tileWidth)
+	for x := range sig {
+		u := (float64(x) + o[0]) / float64(tileWidth)
+		sig[x] = real(min) + real(span)*u
+	}
 
-	for index := start; index < start+stride; index++ {
-		x := index % tileWidth
-		y := index / tileWidth
-		u := float64(x) / float64(tileWidth)
-		v := float64(y) / float64(tileWidth)
-		s := min + complex(real(span)*u, imag(span)*v)
+	var first []complex128
+	if a.kernel != nil {
+		first = make([]complex128, tileWidth)
+		a.kernel.zetaRow(sig, t, first)
+	}
 
+	for x := range sig {
 		select {
 		case <-ctx.Done():
-			log.Println("[algo] job", jobID, "canceled")
-			return
+			return false
+		default:
+		}
+
+		s := complex(sig[x], t)
+		switch {
+		case a.Mode == ModeDomain:
+			values[x] = a.Func.Eval(s)
+		case smooth != nil && first != nil:
+			smooth[x] = a.smoothPoint(s, first[x], ppu)
+		case smooth != nil:
+			smooth[x] = a.smoothPoint(s, a.Func.Eval(s), ppu)
+		case first != nil && a.LUTs != nil:
+			counts[x] = iterateLUT(a.kernel, a.LUTs, ppu, s, first[x], epsilon)
+		case first != nil:
+			counts[x] = iterateFrom(a.kernel, s, first[x], epsilon)
 		default:
+			counts[x] = a.point(s, ppu)
 		}
+	}
+	return true
+}
 
-		var its uint16
+// pixel computes pixel x, y on its own from samples at the offsets. It
+// returns the pixel's data, or its value in ModeDomain.
+func (a *Algo) pixel(x, y int, offsets [][2]float64, min, max complex128, tileWidth int) (uint16, complex128) {
+	span := max - min
+	ppu := float64(tileWidth) / real(span)
+
+	counts := make([]uint16, len(offsets))
+	smooth := make([]float64, len(offsets))
+	values := make([]complex128, len(offsets))
+	for k, o := range offsets {
+		u := (float64(x) + o[0]) / float64(tileWidth)
+		v := (float64(y) + o[1]) / float64(tileWidth)
+		s := min + complex(real(span)*u, imag(span)*v)
 
-		its = iterate(s, 1e-15)
-		a.data[index] = its
+		switch {
+		case a.Mode == ModeDomain:
+			values[k] = a.Func.Eval(s)
+		case a.smooth() && a.kernel != nil:
+			smooth[k] = a.smoothPoint(s, a.kernel.Eval(s), ppu)
+		case a.smooth():
+			smooth[k] = a.smoothPoint(s, a.Func.Eval(s), ppu)
+		default:
+			counts[k] = a.point(s, ppu)
+		}
 	}
 
-	if jobID < 8 {
-		fmt.Println("\t", jobID, min, max, stride, "finished in", time.Since(ts))
+	switch {
+	case a.Mode == ModeDomain:
+		return 0, a.Sampling.reduceValues(values)
+	case a.smooth():
+		return a.Sampling.reduceSmooth(smooth), 0
 	}
+	return a.reduce(counts), 0
 }
 
-func iterate(s complex128, epsilon float64) uint16 {
-	var i uint16
-	var cabsz float64
-	var diff float64 = 100
+// point computes the iteration count or Newton data of a single point
+func (a *Algo) point(s complex128, ppu float64) uint16 {
+	switch {
+	case a.Mode == ModeNewton:
+		return newton(a.Func, a.Func.(Derivative), s)
+	case a.kernel != nil && a.LUTs != nil:
+		return iterateLUT(a.kernel, a.LUTs, ppu, s, a.kernel.Eval(s), epsilon)
+	case a.kernel != nil:
+		return iterateFrom(a.kernel, s, a.kernel.Eval(s), epsilon)
+	}
+	return iterate(a.Func, s, epsilon)
+}
 
-	var z complex128
+// smoothPoint computes the smooth iteration count of a single point whose
+// first iterate is z. Counts finished from a lookup table are whole.
+func (a *Algo) smoothPoint(s, z complex128, ppu float64) float64 {
+	if a.kernel == nil {
+		return smoothCount(a.Func, s, z, epsilon)
+	}
+	if a.LUTs != nil {
+		return float64(iterateLUT(a.kernel, a.LUTs, ppu, s, z, epsilon))
+	}
+	return smoothCount(a.kernel, s, z, epsilon)
+}
+
+// refine supersamples the pixels that differ from a neighbour after the
+// first pass of adaptive sampling
+func (a *Algo) refine(ctx context.Context, min, max complex128, tileWidth int) {
+	edges := a.edges(tileWidth)
 
-	for !math.IsNaN(cabsz) && diff > epsilon && cabsz < cabsZMax && i < maxITs {
-		z = zeta(s)
-		diff = math.Abs(real(z) - real(s))
-		cabsz = mod(z)
-		i++
-		s = z
+	rows := make(chan int, tileWidth)
+	refined := int64(0)
+	for y := 0; y < tileWidth; y++ {
+		rows <- y
 	}
+	close(rows)
+
+	grid := a.Sampling.grid()
+
+	wg := &sync.WaitGroup{}
+	for j := 0; j < runtime.GOMAXPROCS(0); j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for y := range rows {
+				for x := 0; x < tileWidth; x++ {
+					if !edges[y*tileWidth+x] {
+						continue
+					}
+					if ctx.Err() != nil {
+						return
+					}
+
+					a.data[y*tileWidth+x], _ = a.pixel(x, y, grid, min, max, tileWidth)
+					atomic.AddInt64(&refined, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
 
-	if !math.IsNaN(cabsz) && cabsz >= cabsZMax {
-		if real(z) < 0.0 {
-			i++
-		} else {
-			i += 2
+	log.Println("[algo] supersampled", refined, "edge pixels")
+}
+
+// edges marks the pixels whose data differs from a neighbour's. Newton
+// pixels differ when they found different roots.
+func (a *Algo) edges(tileWidth int) []bool {
+	key := func(x, y int) uint16 {
+		d := a.data[y*tileWidth+x]
+		if a.Mode == ModeNewton {
+			root, _ := NewtonData(d)
+			return uint16(root)
 		}
+		return d
 	}
 
-	if i > 255 {
-		log.Fatal("Iterations overflows uint8. iterations:", i, " s:", s)
+	edges := make([]bool, tileWidth*tileWidth)
+	for y := 0; y < tileWidth; y++ {
+		for x := 0; x < tileWidth; x++ {
+			k := key(x, y)
+			edges[y*tileWidth+x] = (x > 0 && key(x-1, y) != k) || (x+1 < tileWidth && key(x+1, y) != k) ||
+				(y > 0 && key(x, y-1) != k) || (y+1 < tileWidth && key(x, y+1) != k)
+		}
 	}
+	return edges
+}
+
+// Values returns the function values computed in ModeDomain
+func (a *Algo) Values() []float32 {
+	return a.values
+}
 
+func iterate(f Function, s complex128, epsilon float64) uint16 {
+	return iterateFrom(f, s, f.Eval(s), epsilon)
+}
+
+// iterateFrom is iterate when the first iterate z = f(s) is already known
+func iterateFrom(f Function, s, z complex128, epsilon float64) uint16 {
+	i, _ := iterateOrbit(f, s, z, epsilon, maxTileITs, nil)
+	return tileCount(i)
+}
+
+// smoothCount is the iteration count of s made continuous. The iteration
+// stops on the step that takes |z| past cabsZMax or the change in Re(z)
+// below epsilon, and the fraction of that step, in log scale, at which it
+// crossed the limit is added to the count. It is shifted by a half so that
+// it rounds to the whole count, and counts of neighbouring pixels either
+// side of a band meet at the half.
+func smoothCount(f Function, s, z complex128, epsilon float64) float64 {
+	diffs := [2]float64{math.NaN(), math.NaN()}
+	abss := [2]float64{mod(s), mod(s)}
+	i, _ := iterateOrbit(f, s, z, epsilon, maxTileITs, func(z complex128, diff, cabsz float64) {
+		diffs[0], diffs[1] = diffs[1], diff
+		abss[0], abss[1] = abss[1], cabsz
+	})
+
+	frac := 0.5
+	switch {
+	case abss[1] >= cabsZMax && abss[0] < cabsZMax:
+		frac = crossing(abss[0], abss[1], cabsZMax)
+	case diffs[1] <= epsilon && diffs[0] > epsilon:
+		frac = crossing(diffs[0], diffs[1], epsilon)
+	}
+	return float64(tileCount(i)) - 0.5 + frac
+}
+
+// crossing returns how far from 0 to just under 1 through a step from a to
+// b the limit is crossed, interpolating log(a) to log(b)
+func crossing(a, b, limit float64) float64 {
+	f := (math.Log(limit) - math.Log(a)) / (math.Log(b) - math.Log(a))
+	if math.IsNaN(f) || f < 0 {
+		return 0
+	}
+	return math.Min(f, math.Nextafter(1, 0))
+}
+
+// tileCount clamps an iteration count to the range stored in tiles
+func tileCount(i uint16) uint16 {
+	if i > maxTileITs {
+		return maxTileITs
+	}
 	return i
 }
 
 func zeta(s complex128) complex128 {
+	return zetaWith(s, ems)
+}
+
+// zetaWith is zeta with the Euler-Maclaurin sum computed by ems
+func zetaWith(s complex128, ems func(complex128) complex128) complex128 {
 	var z complex128
 
+	if math.Abs(imag(s)) > rsThreshold {
+		return riemannSiegel(s)
+	}
+
 	if real(s) < 0.0 {
 		if math.Abs(imag(s)) < maxGamma {
 			s = 1.0 - s
@@ -222,14 +513,8 @@ func gamma(s complex128) complex128 {
 }
 
 func ems(s complex128) complex128 {
-	N := int(cmplx.Abs(s))
+	N := emsTerms(s)
 	var z, t, temp complex128
-	if N > maxN {
-		N = maxN
-	}
-	if N < minN {
-		N = minN
-	}
 	for k := 1; k < N; k++ {
 		z += pow(float64(k), -s)
 	}
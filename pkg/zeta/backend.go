@@ -0,0 +1,92 @@
+package zeta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+)
+
+const (
+	// AutoBackend picks the fastest backend that is available, falling back to
+	// the CPU
+	AutoBackend = "auto"
+)
+
+// Backend computes the data of tiles. The tile describes what to compute and
+// the backend fills in its Data (or Values for ModeDomain). Backends that
+// can't compute a tile, for example a function they don't implement, return
+// an error wrapping ErrUnsupported rather than wrong data.
+type Backend interface {
+	Name() string
+	Compute(ctx context.Context, t *Tile) error
+}
+
+// ErrUnsupported is wrapped by the error a backend returns for a tile it
+// can't compute, which another backend may
+var ErrUnsupported = errors.New("not supported by this backend")
+
+// BackendFactory constructs a backend. It returns an error if the backend is
+// not available on this machine.
+type BackendFactory func() (Backend, error)
+
+var backends = map[string]BackendFactory{}
+
+// autoOrder is the order backends are tried in by AutoBackend
+var autoOrder = []string{"cuda", "cpu"}
+
+// RegisterBackend makes a backend available by name
+func RegisterBackend(name string, factory BackendFactory) {
+	if _, ok := backends[name]; ok {
+		panic("zeta: backend registered twice: " + name)
+	}
+	backends[name] = factory
+}
+
+// NewBackend constructs the named backend. AutoBackend tries each backend
+// that may be faster than the CPU and falls back to the CPU if none of them
+// load. Tiles the backend it picks doesn't support, such as every set but
+// iterated zeta on the GPU, are computed on the CPU too.
+func NewBackend(name string) (Backend, error) {
+	if name == "" || name == AutoBackend {
+		for _, n := range autoOrder {
+			factory, ok := backends[n]
+			if !ok {
+				continue
+			}
+
+			b, err := factory()
+			if err != nil {
+				log.Println("[backend]", n, "is not available:", err)
+				continue
+			}
+			log.Println("[backend] using", n)
+			if cpu, ok := backends["cpu"]; ok && n != "cpu" {
+				fallback, err := cpu()
+				if err != nil {
+					return nil, err
+				}
+				return &fallbackBackend{Backend: b, fallback: fallback}, nil
+			}
+			return b, nil
+		}
+		return nil, fmt.Errorf("no backend is available")
+	}
+
+	factory, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %q, have %v", name, Backends())
+	}
+	return factory()
+}
+
+// Backends returns the names of every registered backend
+func Backends() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,32 @@
+package zeta_test
+
+import (
+	"os"
+	"testing"
+	"zetamachine/pkg/zeta"
+	"zetamachine/pkg/zeta/zetatest"
+)
+
+func TestCPUBackendConformance(t *testing.T) {
+	zetatest.TestBackend(t, &zeta.CPUBackend{})
+}
+
+func TestCPUBackendCheckpoints(t *testing.T) {
+	defer os.Setenv("ZETA_SCRATCH_PATH", os.Getenv("ZETA_SCRATCH_PATH"))
+	os.Setenv("ZETA_SCRATCH_PATH", t.TempDir())
+	zetatest.TestBackend(t, &zeta.CPUBackend{Checkpoint: true})
+}
+
+func TestNewBackend(t *testing.T) {
+	b, err := zeta.NewBackend(zeta.AutoBackend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Name() == "" {
+		t.Fatal("auto backend has no name")
+	}
+
+	if _, err := zeta.NewBackend("abacus"); err == nil {
+		t.Fatal("unknown backend did not return an error")
+	}
+}
@@ -0,0 +1,136 @@
+package zeta
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"zetamachine/pkg/palette"
+)
+
+// benchPoints are representative seed points: the bulb near the origin, the
+// arm along the critical line, far up the arm where ems sums many terms and
+// above rsThreshold where Riemann-Siegel takes over
+var benchPoints = []struct {
+	name string
+	s    complex128
+}{
+	{"bulb", complex(-1.5, 0.5)},
+	{"arm", complex(0.5, 150)},
+	{"far", complex(0.5, 900)},
+	{"rs", complex(0.5, 5000)},
+}
+
+// benchTiles are representative tiles, computed 32 pixels wide to keep the
+// benchmarks short
+var benchTiles = []struct {
+	name string
+	tile *Tile
+}{
+	{"bulb", &Tile{Zoom: 6, X: -2, Y: 0, Width: 32}},
+	{"arm", &Tile{Zoom: 6, X: 1, Y: 300, Width: 32}},
+	{"deep", &Tile{Zoom: 14, X: 256, Y: 76800, Width: 32}},
+}
+
+func BenchmarkIterate(b *testing.B) {
+	for _, p := range benchPoints {
+		b.Run(p.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				iterate(Zeta{}, p.s, epsilon)
+			}
+		})
+	}
+}
+
+func BenchmarkZeta(b *testing.B) {
+	for _, p := range benchPoints {
+		b.Run(p.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				zeta(p.s)
+			}
+		})
+	}
+}
+
+func BenchmarkEms(b *testing.B) {
+	for _, p := range benchPoints[:3] {
+		b.Run(p.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ems(p.s)
+			}
+		})
+	}
+}
+
+func BenchmarkAlgoCompute(b *testing.B) {
+	for _, bt := range benchTiles {
+		t := bt.tile
+		b.Run(bt.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				(&Algo{}).Compute(context.Background(), t.Min(), t.Max(), t.Width)
+			}
+		})
+	}
+}
+
+// benchTile returns a full size tile with iteration counts like a real one
+func benchTile() *Tile {
+	t := &Tile{Zoom: 4, X: -1, Y: 0, Width: TileWidth}
+	t.Data = make([]uint16, t.Width*t.Width)
+	for i := range t.Data {
+		t.Data[i] = uint16(i/t.Width/8+i%t.Width/32) % 60
+	}
+	return t
+}
+
+func BenchmarkTileSave(b *testing.B) {
+	dir, err := ioutil.TempDir("", "zeta-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("ZETA_TILE_PATH", os.Getenv("ZETA_TILE_PATH"))
+	os.Setenv("ZETA_TILE_PATH", dir)
+
+	t := benchTile()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := t.Save(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTileLoad(b *testing.B) {
+	dir, err := ioutil.TempDir("", "zeta-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("ZETA_TILE_PATH", os.Getenv("ZETA_TILE_PATH"))
+	os.Setenv("ZETA_TILE_PATH", dir)
+
+	if err := benchTile().Save(); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		t := &Tile{Zoom: 4, X: -1, Y: 0, Width: TileWidth}
+		if err := t.Load(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTileRender(b *testing.B) {
+	t := benchTile()
+	colors := palette.DefaultPalette
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := t.Render(colors); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package zeta
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultCheckpointInterval is how often completed rows are saved if
+	// ZETA_CHECKPOINT_INTERVAL is not set
+	DefaultCheckpointInterval = 30 * time.Second
+)
+
+// Checkpoint periodically saves the completed rows of a tile to local scratch
+// storage while it is being computed. If the same tile is computed again
+// after a crash (for example when NSQ redelivers the request) the saved rows
+// are restored instead of being computed again.
+type Checkpoint struct {
+	Key      string
+	Dir      string
+	Interval time.Duration
+}
+
+// checkpointFile is the gob encoded contents of a checkpoint
+type checkpointFile struct {
+	Key   string
+	Width int
+	Rows  map[int][]uint16
+}
+
+// NewCheckpoint creates a checkpoint for the tile. The scratch directory is
+// read from ZETA_SCRATCH_PATH (defaulting to the system temp directory) and
+// the interval from ZETA_CHECKPOINT_INTERVAL.
+func NewCheckpoint(t *Tile) *Checkpoint {
+	dir := os.Getenv("ZETA_SCRATCH_PATH")
+	if dir == "" {
+		dir = path.Join(os.TempDir(), "zeta-checkpoints")
+	}
+
+	interval := DefaultCheckpointInterval
+	if d, err := time.ParseDuration(os.Getenv("ZETA_CHECKPOINT_INTERVAL")); err == nil && d > 0 {
+		interval = d
+	}
+
+	return &Checkpoint{
+		Key:      t.checkpointKey(),
+		Dir:      dir,
+		Interval: interval,
+	}
+}
+
+// checkpointKey identifies the tile and every parameter that affects its data
+func (t *Tile) checkpointKey() string {
+	return fmt.Sprint(t.Set(), " ", t.Zoom, "/", t.X, "/", t.Y, " width:", t.Width,
+		" min:", t.Min(), " max:", t.Max(), " ", algoParams())
+}
+
+// algoParams describes the parameters of the iteration algorithm
+func algoParams() string {
+	return fmt.Sprint("epsilon:", epsilon, " maxTileITs:", maxTileITs, " cabsZMax:", cabsZMax,
+		" minN:", minN, " maxN:", maxN, " maxGamma:", maxGamma, " rsThreshold:", rsThreshold)
+}
+
+// Filename returns the full path to the checkpoint file
+func (c *Checkpoint) Filename() string {
+	sum := sha1.Sum([]byte(c.Key))
+	return path.Join(c.Dir, hex.EncodeToString(sum[:])+".ckpt")
+}
+
+// restore copies any saved rows into data and marks them as done. It returns
+// the number of rows restored.
+func (c *Checkpoint) restore(data []uint16, done []int32, width int) int {
+	f, err := os.Open(c.Filename())
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	b, err := decompress(f)
+	if err != nil {
+		log.Println("[checkpoint] ignoring unreadable checkpoint:", err)
+		return 0
+	}
+
+	var cf checkpointFile
+	if err := gob.NewDecoder(bytes.NewBuffer(b)).Decode(&cf); err != nil {
+		log.Println("[checkpoint] ignoring undecodable checkpoint:", err)
+		return 0
+	}
+
+	if cf.Key != c.Key || cf.Width != width {
+		log.Println("[checkpoint] ignoring checkpoint for a different tile:", cf.Key)
+		return 0
+	}
+
+	count := 0
+	for y, row := range cf.Rows {
+		if y < 0 || y >= width || len(row) != width {
+			continue
+		}
+		copy(data[y*width:], row)
+		done[y] = 1
+		count++
+	}
+
+	log.Println("[checkpoint] restored", count, "rows from", c.Filename())
+	return count
+}
+
+// run saves the completed rows every interval until finished is closed
+func (c *Checkpoint) run(finished <-chan struct{}, data []uint16, done []int32, width int) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-finished:
+			return
+		case <-ticker.C:
+			if err := c.save(data, done, width); err != nil {
+				log.Println("[checkpoint] failed to save:", err)
+			}
+		}
+	}
+}
+
+// finish removes the checkpoint once every row is complete. If the tile was
+// canceled part way through the completed rows are saved instead.
+func (c *Checkpoint) finish(data []uint16, done []int32, width int) {
+	for y := range done {
+		if atomic.LoadInt32(&done[y]) == 0 {
+			if err := c.save(data, done, width); err != nil {
+				log.Println("[checkpoint] failed to save:", err)
+			}
+			return
+		}
+	}
+
+	if err := os.Remove(c.Filename()); err != nil && !os.IsNotExist(err) {
+		log.Println("[checkpoint] failed to remove:", err)
+	}
+}
+
+// save writes the completed rows to a temporary file then renames it over the
+// checkpoint so a crash while saving never leaves a partial file behind
+func (c *Checkpoint) save(data []uint16, done []int32, width int) error {
+	cf := checkpointFile{Key: c.Key, Width: width, Rows: make(map[int][]uint16)}
+	for y := range done {
+		if atomic.LoadInt32(&done[y]) == 1 {
+			row := make([]uint16, width)
+			copy(row, data[y*width:(y+1)*width])
+			cf.Rows[y] = row
+		}
+	}
+
+	if len(cf.Rows) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(cf); err != nil {
+		return err
+	}
+
+	comp, err := compress(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.Dir, os.ModeDir|os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(c.Dir, "ckpt")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(comp); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	log.Println("[checkpoint] saved", len(cf.Rows), "of", width, "rows to", c.Filename())
+	return os.Rename(tmp.Name(), c.Filename())
+}
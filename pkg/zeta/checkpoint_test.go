@@ -0,0 +1,119 @@
+package zeta
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingFunc counts its evaluations and calls stop after the first n
+type countingFunc struct {
+	Function
+	evals *int64
+	n     int64
+	stop  func()
+}
+
+func (f countingFunc) Eval(s complex128) complex128 {
+	if atomic.AddInt64(f.evals, 1) == f.n {
+		f.stop()
+	}
+	return f.Function.Eval(s)
+}
+
+func TestCheckpointResume(t *testing.T) {
+	tile := &Tile{Zoom: 3, X: -1, Y: 0, Width: 16, Function: "eta"}
+	f, err := tile.Func()
+	if err != nil {
+		t.Fatal(err)
+	}
+	min, max := tile.Min(), tile.Max()
+
+	var evals int64
+	full := (&Algo{Func: countingFunc{Function: f, evals: &evals}}).Compute(context.Background(), min, max, tile.Width)
+
+	// cancel half way through the same tile with a checkpoint
+	c := &Checkpoint{Key: tile.checkpointKey(), Dir: t.TempDir(), Interval: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	var partial int64
+	(&Algo{Func: countingFunc{Function: f, evals: &partial, n: evals / 2, stop: cancel}, Checkpoint: c}).Compute(ctx, min, max, tile.Width)
+	cancel()
+
+	data := make([]uint16, tile.Width*tile.Width)
+	done := make([]int32, tile.Width)
+	restored := c.restore(data, done, tile.Width)
+	if restored == 0 || restored == tile.Width {
+		t.Fatalf("restored %d of %d rows", restored, tile.Width)
+	}
+
+	// resuming computes only the rest of the rows and gets the same data
+	var resumed int64
+	got := (&Algo{Func: countingFunc{Function: f, evals: &resumed}, Checkpoint: c}).Compute(context.Background(), min, max, tile.Width)
+	if !reflect.DeepEqual(got, full) {
+		t.Fatal("resumed tile differs from the tile computed in one go")
+	}
+	if resumed >= evals {
+		t.Errorf("resuming evaluated %d points, the whole tile %d", resumed, evals)
+	}
+	if _, err := os.Stat(c.Filename()); !os.IsNotExist(err) {
+		t.Errorf("checkpoint of a finished tile is still there: %v", err)
+	}
+}
+
+func TestCheckpointKey(t *testing.T) {
+	dir := t.TempDir()
+	tile := &Tile{Zoom: 3, X: 0, Y: 0, Width: 4, Function: "hurwitz", Params: map[string]float64{"a": 0.5}}
+	c := &Checkpoint{Key: tile.checkpointKey(), Dir: dir}
+
+	// the key names the limit stored counts are clamped to
+	if want := fmt.Sprint(" maxTileITs:", maxTileITs, " "); !strings.Contains(c.Key, want) {
+		t.Errorf("key %q doesn't contain %q", c.Key, want)
+	}
+
+	rows := []uint16{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	if err := c.save(rows, []int32{1, 1, 0, 0}, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]uint16, 16)
+	done := make([]int32, 4)
+	if n := c.restore(data, done, 4); n != 2 || !reflect.DeepEqual(data[:8], rows[:8]) || done[2] != 0 {
+		t.Fatalf("restored %d rows %v, done %v", n, data, done)
+	}
+
+	// a checkpoint of another width or parameters isn't restored, even from
+	// its file
+	other := *tile
+	other.Params = map[string]float64{"a": 0.6}
+	wide := *tile
+	wide.Width = 8
+	for _, o := range []*Tile{&other, &wide} {
+		if o.checkpointKey() == c.Key {
+			t.Fatalf("%v has the same key", o)
+		}
+		oc := &Checkpoint{Key: o.checkpointKey(), Dir: dir}
+		if n := oc.restore(make([]uint16, o.Width*o.Width), make([]int32, o.Width), o.Width); n != 0 {
+			t.Errorf("%v restored %d rows", o, n)
+		}
+
+		b, err := ioutil.ReadFile(c.Filename())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(oc.Filename(), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if n := oc.restore(make([]uint16, o.Width*o.Width), make([]int32, o.Width), o.Width); n != 0 {
+			t.Errorf("%v restored %d rows from the checkpoint of %v", o, n, tile)
+		}
+	}
+	if n := c.restore(make([]uint16, 64), make([]int32, 8), 8); n != 0 {
+		t.Errorf("restored %d rows into a wider tile", n)
+	}
+}
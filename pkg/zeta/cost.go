@@ -0,0 +1,101 @@
+package zeta
+
+import (
+	"math"
+	"math/cmplx"
+	"time"
+)
+
+// CostModel estimates how long a tile will take to compute. Nearly all of the
+// time is spent summing terms in ems, where the number of terms is N = |s|
+// clamped between minN and maxN, or in the Riemann-Siegel sums far up the
+// imaginary axis. The first evaluation at each pixel sets N from the pixel
+// itself; later iterates almost always land close to the origin and are
+// modelled as a fixed number of extra minN term evaluations.
+type CostModel struct {
+	// Samples is the number of sample points along each side of a tile
+	Samples int
+
+	// FollowIterations is the assumed number of evaluations per pixel after
+	// the first
+	FollowIterations float64
+
+	// TermCost is the time it takes to sum a single term
+	TermCost time.Duration
+}
+
+// NewCostModel returns a cost model with default sampling. If termCost is zero
+// it is measured on this machine.
+func NewCostModel(termCost time.Duration) *CostModel {
+	if termCost <= 0 {
+		termCost = CalibrateTermCost()
+	}
+
+	return &CostModel{
+		Samples:          4,
+		FollowIterations: 10,
+		TermCost:         termCost,
+	}
+}
+
+// Terms returns the number of terms summed when evaluating zeta at s
+func Terms(s complex128) int {
+	if math.Abs(imag(s)) > rsThreshold {
+		// two sums of sqrt(t/2π) terms each
+		return 2 * int(math.Sqrt(math.Abs(imag(s))/(2*math.Pi)))
+	}
+
+	if real(s) < 0.0 && math.Abs(imag(s)) < maxGamma {
+		s = 1.0 - s
+	}
+
+	N := int(cmplx.Abs(s))
+	if N > maxN {
+		N = maxN
+	}
+	if N < minN {
+		N = minN
+	}
+	return N
+}
+
+// TileTerms estimates the total number of terms summed to compute a tile by
+// sampling the term count on a grid of points across it
+func (m *CostModel) TileTerms(t *Tile) float64 {
+	min := t.Min()
+	span := t.Max() - min
+
+	total := 0.0
+	for i := 0; i < m.Samples; i++ {
+		for j := 0; j < m.Samples; j++ {
+			u := (float64(i) + 0.5) / float64(m.Samples)
+			v := (float64(j) + 0.5) / float64(m.Samples)
+			s := min + complex(real(span)*u, imag(span)*v)
+			total += float64(Terms(s)) + m.FollowIterations*minN
+		}
+	}
+
+	sampling, _ := ParseSampling(t.Sampling)
+	pixels := float64(t.Width*t.Width) * sampling.perPixel()
+	return total / float64(m.Samples*m.Samples) * pixels
+}
+
+// TileCost estimates how long it will take to compute the tile
+func (m *CostModel) TileCost(t *Tile) time.Duration {
+	return time.Duration(m.TileTerms(t) * float64(m.TermCost))
+}
+
+// CalibrateTermCost measures how long it takes ems to sum a single term
+func CalibrateTermCost() time.Duration {
+	s := complex(0.5, 20000)
+	N := int(cmplx.Abs(s)) // ems sums N = |s| terms
+
+	ems(s) // warm up
+	runs := 5
+	start := time.Now()
+	for i := 0; i < runs; i++ {
+		ems(s)
+	}
+
+	return time.Since(start) / time.Duration(runs*N)
+}
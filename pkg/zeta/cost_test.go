@@ -0,0 +1,62 @@
+package zeta
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTerms(t *testing.T) {
+	cases := []struct {
+		s    complex128
+		want int
+	}{
+		{0.5 + 10i, minN},
+		{0.5 + 500i, 500},
+		{-300 + 400i, 500}, // reflected to 301 - 400i
+		{-300 + 500i, 583}, // above maxGamma it isn't reflected
+		{0.5 + 20000i, 2 * 56},
+		{3e6, maxN},
+	}
+	for _, c := range cases {
+		if got := Terms(c.s); got != c.want {
+			t.Errorf("Terms(%v) = %d, want %d", c.s, got, c.want)
+		}
+	}
+}
+
+func TestTileCost(t *testing.T) {
+	m := &CostModel{Samples: 3, FollowIterations: 0, TermCost: time.Microsecond}
+
+	// the cost of a tile is the mean term count of its samples for every
+	// pixel, so it scales with both
+	near := &Tile{Zoom: 0, X: 0, Y: 0, Width: 4}
+	far := &Tile{Zoom: 0, X: 0, Y: 100, Width: 4}
+	if got := m.TileTerms(near); got != minN*16 {
+		t.Errorf("tile near the origin sums %g terms, want %d", got, minN*16)
+	}
+
+	mean := 0.0
+	for _, s := range []complex128{0.5 + 400.5i, 2 + 400.5i, 3.5 + 400.5i, 0.5 + 402i, 2 + 402i, 3.5 + 402i, 0.5 + 403.5i, 2 + 403.5i, 3.5 + 403.5i} {
+		mean += float64(Terms(s)) / 9
+	}
+	if mean <= minN {
+		t.Fatalf("tile far up the imaginary axis averages %g terms", mean)
+	}
+	if got := m.TileTerms(far); math.Abs(got-mean*16) > 1e-9*got {
+		t.Errorf("tile sums %g terms, want %g", got, mean*16)
+	}
+
+	wide := &Tile{Zoom: 1, X: 0, Y: 0, Width: 8}
+	if got := m.TileTerms(wide); got != 4*m.TileTerms(near) {
+		t.Errorf("a tile four times the pixels sums %g terms, want %g", got, 4*m.TileTerms(near))
+	}
+	sampled := &Tile{Zoom: 0, Width: 4, Sampling: "ss2mean"}
+	if got := m.TileTerms(sampled); got != 4*m.TileTerms(near) {
+		t.Errorf("a 2×2 supersampled tile sums %g terms, want %g", got, 4*m.TileTerms(near))
+	}
+
+	if got := m.TileCost(near); got != 16*minN*time.Microsecond {
+		t.Errorf("tile costs %s", got)
+	}
+}
@@ -0,0 +1,83 @@
+package zeta
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+func init() {
+	RegisterBackend("cpu", func() (Backend, error) {
+		b := &CPUBackend{Checkpoint: true}
+
+		if manifest := os.Getenv("ZETA_LUT_MANIFEST"); manifest != "" {
+			luts, err := LoadLUTs(manifest)
+			if err != nil {
+				return nil, err
+			}
+			log.Println("[cpu] loaded", len(luts.LUTs), "lookup tables from", manifest)
+			b.LUTs = luts
+		}
+		return b, nil
+	})
+}
+
+// CPUBackend computes tiles on every core of this machine. It supports every
+// function and mode.
+type CPUBackend struct {
+	// Checkpoint saves the completed rows of long running tiles so they can
+	// be resumed after a crash (see NewCheckpoint)
+	Checkpoint bool
+
+	// LUTs speed up zeta iteration tiles of the lookup table sets (see
+	// Tile.LUT and Algo.LUTs)
+	LUTs *LUTSet
+}
+
+// Name ...
+func (b *CPUBackend) Name() string {
+	return "cpu"
+}
+
+// Compute ...
+func (b *CPUBackend) Compute(ctx context.Context, t *Tile) error {
+	f, err := t.Func()
+	if err != nil {
+		return err
+	}
+
+	sampling, err := ParseSampling(t.Sampling)
+	if err != nil {
+		return err
+	}
+
+	if t.LUT && b.LUTs == nil {
+		return fmt.Errorf("no lookup tables are loaded for %s: %w", t.Set(), ErrUnsupported)
+	}
+
+	start := time.Now()
+	algo := &Algo{Func: f, Mode: t.Mode, Sampling: sampling}
+	if t.LUT {
+		algo.LUTs = b.LUTs
+	}
+	if t.Mode == ModeDomain {
+		// a single evaluation per pixel is quick enough not to checkpoint
+		algo.Compute(ctx, t.Min(), t.Max(), t.Width)
+		t.Values = algo.Values()
+	} else {
+		if b.Checkpoint {
+			algo.Checkpoint = NewCheckpoint(t)
+		}
+		t.Data = algo.Compute(ctx, t.Min(), t.Max(), t.Width)
+	}
+
+	// a canceled tile is incomplete
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	log.Println("[tile] compute complete in ", time.Since(start), t)
+	return nil
+}
@@ -1,11 +1,17 @@
+//go:build BUILD_CUDA
 // +build BUILD_CUDA
 
 package zeta
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"os"
+	"sync"
 	"time"
+	"unsafe"
 )
 
 // compile the cuda code from the root workspace folder with:
@@ -16,21 +22,113 @@ import (
 //
 //	go build -tags BUILD_CUDA -o build/cuda ./cmd/cuda/.
 //
+// The library is loaded when the backend is first used rather than linked, so
+// a binary built with BUILD_CUDA still runs (on the CPU backend) on machines
+// without the library or a GPU. Set ZETA_CUDA_LIB to its path if it is not on
+// the library search path.
 
 /*
-void generate(double minR, double maxR, double minI, double maxI, unsigned int size, unsigned int* data);
-#cgo LDFLAGS: -L. -L./ -lzm
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdlib.h>
+
+typedef void (*generate_fn)(double minR, double maxR, double minI, double maxI, unsigned int size, unsigned int* data);
+
+static void* zm_open(const char* path) { return dlopen(path, RTLD_NOW); }
+static void* zm_sym(void* lib, const char* name) { return dlsym(lib, name); }
+static const char* zm_error() { return dlerror(); }
+
+static void zm_generate(void* fn, double minR, double maxR, double minI, double maxI, unsigned int size, unsigned int* data) {
+	((generate_fn)fn)(minR, maxR, minI, maxI, size, data);
+}
 */
 import "C"
 
-// Generate tile data via call to cuda zeta machine library
-func (t *Tile) ComputeRequest(ctx context.Context) {
+const defaultCUDALib = "libzm.so"
+
+func init() {
+	RegisterBackend("cuda", func() (Backend, error) {
+		return newCUDABackend()
+	})
+}
+
+var (
+	cudaOnce     sync.Once
+	cudaGenerate unsafe.Pointer
+	cudaErr      error
+)
+
+// loadCUDA loads the cuda library once and looks up its generate function
+func loadCUDA() (unsafe.Pointer, error) {
+	cudaOnce.Do(func() {
+		lib := os.Getenv("ZETA_CUDA_LIB")
+		if lib == "" {
+			lib = defaultCUDALib
+		}
+
+		path := C.CString(lib)
+		defer C.free(unsafe.Pointer(path))
+
+		handle := C.zm_open(path)
+		if handle == nil {
+			cudaErr = errors.New(C.GoString(C.zm_error()))
+			return
+		}
+
+		name := C.CString("generate")
+		defer C.free(unsafe.Pointer(name))
+
+		cudaGenerate = C.zm_sym(handle, name)
+		if cudaGenerate == nil {
+			cudaErr = errors.New(C.GoString(C.zm_error()))
+		}
+	})
+	return cudaGenerate, cudaErr
+}
+
+// CUDABackend computes zeta iteration tiles on an NVidia GPU. It only
+// supports the zeta function in the default mode, and returns ErrUnsupported
+// for the tiles of every other set. AutoBackend computes those on the CPU.
+type CUDABackend struct {
+	generate unsafe.Pointer
+	mu       sync.Mutex // the library renders one tile at a time
+}
+
+func newCUDABackend() (*CUDABackend, error) {
+	fn, err := loadCUDA()
+	if err != nil {
+		return nil, err
+	}
+	return &CUDABackend{generate: fn}, nil
+}
+
+// Name ...
+func (b *CUDABackend) Name() string {
+	return "cuda"
+}
+
+// Compute generates tile data via a call to the cuda zeta machine library
+func (b *CUDABackend) Compute(ctx context.Context, t *Tile) error {
+	if t.Set() != "" {
+		return fmt.Errorf("the cuda library only renders zeta, not %s: %w", t.Set(), ErrUnsupported)
+	}
+	if t.Width <= 0 {
+		return fmt.Errorf("invalid tile width %d", t.Width)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	start := time.Now()
 	buf := make([]C.uint, t.Width*t.Width)
 	min := t.Min()
 	max := t.Max()
-	C.generate(C.double(real(min)), C.double(real(max)), 
-		C.double(imag(min)), C.double(imag(max)), 
+	C.zm_generate(b.generate, C.double(real(min)), C.double(real(max)),
+		C.double(imag(min)), C.double(imag(max)),
 		C.uint(t.Width), &buf[0])
 
 	t.Data = make([]uint16, len(buf))
@@ -38,4 +136,5 @@ func (t *Tile) ComputeRequest(ctx context.Context) {
 		t.Data[i] = uint16(buf[i])
 	}
 	log.Println("[tile] compute complete in ", time.Since(start), t)
+	return nil
 }
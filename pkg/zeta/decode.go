@@ -0,0 +1,58 @@
+package zeta
+
+import (
+	"fmt"
+	"image"
+	"zetamachine/pkg/palette"
+)
+
+// DecodeStats counts the pixels of an image that could not be decoded to
+// their exact iteration count
+type DecodeStats struct {
+	// Ambiguous pixels have a colour the palette renders more than one count
+	// as. They are given the lowest.
+	Ambiguous int
+
+	// Unknown pixels have a colour that is not in the palette. They are given
+	// the count of the nearest colour.
+	Unknown int
+}
+
+// Decode recovers the iteration data of the tile from an image of it rendered
+// with the palette rev was built from. Images are drawn with the imaginary
+// axis pointing up, or upside down if legacy, as they were before that was
+// fixed.
+func (t *Tile) Decode(img image.Image, rev *palette.Reverse, legacy bool) (DecodeStats, error) {
+	stats := DecodeStats{}
+
+	if t.Mode != "" {
+		return stats, fmt.Errorf("can not decode %s mode tiles", t.Mode)
+	}
+
+	b := img.Bounds()
+	if b.Dx() != b.Dy() {
+		return stats, fmt.Errorf("tile image is %dx%d, not square", b.Dx(), b.Dy())
+	}
+
+	t.Width = b.Dx()
+	t.Data = make([]uint16, t.Width*t.Width)
+	for y := 0; y < t.Width; y++ {
+		row := ImageRow(t.Width, y)
+		if legacy {
+			row = y
+		}
+		for x := 0; x < t.Width; x++ {
+			c := img.At(b.Min.X+x, b.Min.Y+row)
+
+			its, ambiguous, ok := rev.Lookup(c)
+			if !ok {
+				its = rev.Nearest(c)
+				stats.Unknown++
+			} else if ambiguous {
+				stats.Ambiguous++
+			}
+			t.Data[y*t.Width+x] = its
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,115 @@
+package zeta
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"zetamachine/pkg/palette"
+)
+
+func TestDecode(t *testing.T) {
+	colors := palette.Original
+	rev := palette.NewReverse(colors)
+
+	tile := &Tile{Width: 16}
+	tile.Data = make([]uint16, 256)
+	for i := range tile.Data {
+		tile.Data[i] = uint16(i)
+	}
+	img, err := tile.Render(colors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := &Tile{}
+	stats, err := decoded.Decode(img, rev, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Width != 16 || stats.Unknown != 0 {
+		t.Fatalf("decoded width %d with %d unknown colours", decoded.Width, stats.Unknown)
+	}
+
+	ambiguous := 0
+	for i, its := range decoded.Data {
+		if len(rev.Counts(colors[i])) > 1 {
+			ambiguous++
+		} else if its != uint16(i) {
+			t.Fatalf("count %d decoded as %d", i, its)
+		}
+
+		// ambiguous counts still render the same
+		if colors[its] != colors[i] {
+			t.Fatalf("count %d decoded as %d, which renders differently", i, its)
+		}
+	}
+	if ambiguous == 0 || stats.Ambiguous != ambiguous {
+		t.Fatalf("%d ambiguous pixels reported, want %d", stats.Ambiguous, ambiguous)
+	}
+}
+
+func TestDecodeNearest(t *testing.T) {
+	rev := palette.NewReverse(palette.Original)
+
+	// a slightly off colour from lossy compression
+	img := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.RGBA{0x01, 0x3e, 0xfd, 0xff})
+
+	tile := &Tile{}
+	stats, err := tile.Decode(img, rev, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Unknown != 1 || tile.Data[0] != 3 {
+		t.Fatalf("decoded %d with %d unknown, want 3", tile.Data[0], stats.Unknown)
+	}
+
+	if _, err := tile.Decode(image.NewNRGBA(image.Rect(0, 0, 2, 1)), rev, false); err == nil {
+		t.Fatal("decoded an image that is not square")
+	}
+}
+
+func TestDecodeOrientation(t *testing.T) {
+	colors := palette.Original
+	rev := palette.NewReverse(colors)
+
+	// counts 2 to 17 from the bottom row up, none of them ambiguous
+	tile := &Tile{Width: 4, Data: make([]uint16, 16)}
+	for i := range tile.Data {
+		tile.Data[i] = uint16(2 + i)
+	}
+
+	up, err := tile.Render(colors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// images were drawn with row y of the data at row y of the image
+	legacy := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for i, c := range tile.Data {
+		legacy.Set(i%4, i/4, colors[c])
+	}
+
+	for _, img := range []struct {
+		name   string
+		img    image.Image
+		legacy bool
+	}{{"up", up, false}, {"legacy", legacy, true}} {
+		decoded := &Tile{}
+		if _, err := decoded.Decode(img.img, rev, img.legacy); err != nil {
+			t.Fatal(err)
+		}
+		for i, its := range decoded.Data {
+			if its != tile.Data[i] {
+				t.Fatalf("%s image: count %d decoded as %d", img.name, tile.Data[i], its)
+			}
+		}
+
+		// the other way up gives the rows reversed
+		decoded = &Tile{}
+		decoded.Decode(img.img, rev, !img.legacy)
+		if decoded.Data[0] != tile.Data[12] {
+			t.Fatalf("%s image decoded the wrong way up gives %d, want %d", img.name, decoded.Data[0], tile.Data[12])
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package zeta
+
+const (
+	// ModeDomain evaluates the function once per pixel and stores the value
+	// itself in Tile.Values for domain colouring instead of iterating it
+	ModeDomain = "domain"
+)
+
+// DomainValue returns the value of pixel i of a ModeDomain tile
+func (t *Tile) DomainValue(i int) complex128 {
+	return complex(float64(t.Values[2*i]), float64(t.Values[2*i+1]))
+}
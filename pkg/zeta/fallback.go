@@ -0,0 +1,22 @@
+package zeta
+
+import (
+	"context"
+	"errors"
+)
+
+// fallbackBackend computes tiles its backend doesn't support, such as the
+// sets other than iterated zeta on the GPU, with another backend instead
+type fallbackBackend struct {
+	Backend
+	fallback Backend
+}
+
+// Compute ...
+func (b *fallbackBackend) Compute(ctx context.Context, t *Tile) error {
+	err := b.Backend.Compute(ctx, t)
+	if errors.Is(err, ErrUnsupported) {
+		return b.fallback.Compute(ctx, t)
+	}
+	return err
+}
@@ -0,0 +1,48 @@
+package zeta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// zetaOnly refuses every set but iterated zeta, like the CUDA backend
+type zetaOnly struct {
+	CPUBackend
+	computed int
+}
+
+func (z *zetaOnly) Compute(ctx context.Context, t *Tile) error {
+	if t.Set() != "" {
+		return fmt.Errorf("not %s: %w", t.Set(), ErrUnsupported)
+	}
+	if t.Width < 0 {
+		return errors.New("invalid width")
+	}
+	z.computed++
+	return z.CPUBackend.Compute(ctx, t)
+}
+
+func TestFallbackBackend(t *testing.T) {
+	primary := &zetaOnly{}
+	b := &fallbackBackend{Backend: primary, fallback: &CPUBackend{}}
+
+	for _, fn := range []string{"", "eta"} {
+		tile := &Tile{Zoom: 4, X: 1, Y: 1, Width: 4, Function: fn}
+		if err := b.Compute(context.Background(), tile); err != nil {
+			t.Fatalf("%q: %v", fn, err)
+		}
+		if len(tile.Data) != 16 {
+			t.Fatalf("%q tile was not computed: %v", fn, tile.Data)
+		}
+	}
+	if primary.computed != 1 {
+		t.Errorf("the backend computed %d tiles, want only the zeta one", primary.computed)
+	}
+
+	// other errors are not retried
+	if err := b.Compute(context.Background(), &Tile{Width: -1}); err == nil || primary.computed != 1 {
+		t.Errorf("failed tile returned %v after %d tiles computed", err, primary.computed)
+	}
+}
@@ -0,0 +1,379 @@
+package zeta
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Function is a complex function whose iteration s -> f(s) is rendered. Zeta
+// is the default but any registered function can be rendered with the same
+// tiles, storage and map.
+type Function interface {
+	Eval(s complex128) complex128
+}
+
+// FunctionFactory constructs a Function from its named parameters
+type FunctionFactory func(params map[string]float64) (Function, error)
+
+const (
+	// DefaultFunction is the name of the Riemann zeta function
+	DefaultFunction = "zeta"
+)
+
+// registered is a function's factory and the names of its parameters
+type registered struct {
+	factory FunctionFactory
+	params  []string
+}
+
+var functions = map[string]registered{}
+
+// RegisterFunction makes a function available by name, taking the named
+// parameters
+func RegisterFunction(name string, factory FunctionFactory, params ...string) {
+	if _, ok := functions[name]; ok {
+		panic("zeta: function registered twice: " + name)
+	}
+	functions[name] = registered{factory: factory, params: params}
+}
+
+// NewFunction constructs the named function. An empty name is zeta.
+func NewFunction(name string, params map[string]float64) (Function, error) {
+	if name == "" {
+		name = DefaultFunction
+	}
+
+	r, ok := functions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", name)
+	}
+	if err := checkParams(params, r.params...); err != nil {
+		return nil, err
+	}
+	return r.factory(params)
+}
+
+// FunctionParams returns the names of the named function's parameters. An
+// empty name is zeta.
+func FunctionParams(name string) ([]string, error) {
+	if name == "" {
+		name = DefaultFunction
+	}
+
+	r, ok := functions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", name)
+	}
+	return r.params, nil
+}
+
+// Functions returns the names of every registered function
+func Functions() []string {
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseParams parses function parameters given as "a=0.5,q=5"
+func ParseParams(s string) (map[string]float64, error) {
+	params := make(map[string]float64)
+	if strings.TrimSpace(s) == "" {
+		return params, nil
+	}
+
+	for _, kv := range strings.Split(s, ",") {
+		tok := strings.SplitN(kv, "=", 2)
+		if len(tok) != 2 {
+			return nil, fmt.Errorf("expected name=value but got %q", kv)
+		}
+
+		v, err := strconv.ParseFloat(strings.TrimSpace(tok[1]), 64)
+		if err != nil {
+			return nil, err
+		}
+		params[strings.TrimSpace(tok[0])] = v
+	}
+	return params, nil
+}
+
+// SetName identifies the tiles of a render mode, function and its parameters
+// in storage. Iterated zeta tiles have an empty set name so they keep their
+// original location.
+func SetName(mode, name string, params map[string]float64) string {
+	if name == "" || name == DefaultFunction {
+		if len(params) == 0 && mode == "" {
+			return ""
+		}
+		name = DefaultFunction
+	}
+	if mode != "" {
+		name = mode + "_" + name
+	}
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	set := name
+	for _, k := range keys {
+		set += "_" + k + strconv.FormatFloat(params[k], 'g', -1, 64)
+	}
+	return set
+}
+
+// ParseSetName returns a tile with the mode, function, parameters, sampling
+// and lookup table use of the set named by Tile.Set
+func ParseSetName(set string) (*Tile, error) {
+	t := &Tile{Width: TileWidth}
+	if set == "" {
+		return t, nil
+	}
+
+	tok := strings.Split(set, "_")
+	if tok[0] == ModeNewton || tok[0] == ModeDomain {
+		t.Mode, tok = tok[0], tok[1:]
+	}
+	if len(tok) == 0 {
+		return nil, fmt.Errorf("set %q has no function", set)
+	}
+	if _, ok := functions[tok[0]]; !ok {
+		return nil, fmt.Errorf("set %q: unknown function %q", set, tok[0])
+	}
+	t.Function, tok = tok[0], tok[1:]
+
+	if n := len(tok); n > 0 && tok[n-1] == lutSuffix {
+		t.LUT, tok = true, tok[:n-1]
+	}
+	if n := len(tok); n > 0 {
+		if _, err := ParseSampling(tok[n-1]); err == nil {
+			t.Sampling, tok = tok[n-1], tok[:n-1]
+		}
+	}
+
+	for _, kv := range tok {
+		i := strings.IndexFunc(kv, func(r rune) bool {
+			return !unicode.IsLetter(r)
+		})
+		if i <= 0 {
+			return nil, fmt.Errorf("set %q: invalid parameter %q", set, kv)
+		}
+
+		v, err := strconv.ParseFloat(kv[i:], 64)
+		if err != nil {
+			return nil, fmt.Errorf("set %q: invalid parameter %q", set, kv)
+		}
+		if t.Params == nil {
+			t.Params = make(map[string]float64)
+		}
+		t.Params[kv[:i]] = v
+	}
+	return t, nil
+}
+
+func init() {
+	RegisterFunction("zeta", func(params map[string]float64) (Function, error) {
+		return Zeta{}, nil
+	})
+
+	RegisterFunction("eta", func(params map[string]float64) (Function, error) {
+		return Eta{}, nil
+	})
+
+	RegisterFunction("xi", func(params map[string]float64) (Function, error) {
+		return Xi{}, nil
+	})
+
+	RegisterFunction("hurwitz", func(params map[string]float64) (Function, error) {
+		a, ok := params["a"]
+		if !ok || a <= 0 || a > 1 {
+			return nil, fmt.Errorf("hurwitz needs a parameter 0 < a <= 1")
+		}
+		return Hurwitz{A: a}, nil
+	}, "a")
+
+	RegisterFunction("dirichlet", func(params map[string]float64) (Function, error) {
+		q, k := params["q"], params["k"]
+		if q != math.Trunc(q) || k != math.Trunc(k) || math.IsInf(q, 0) || math.IsInf(k, 0) {
+			return nil, fmt.Errorf("dirichlet needs whole numbers q and k, got q=%g k=%g", q, k)
+		}
+		return NewDirichletL(int(q), int(k))
+	}, "q", "k")
+}
+
+// checkParams makes sure only the allowed parameters are given
+func checkParams(params map[string]float64, allowed ...string) error {
+	for k := range params {
+		ok := false
+		for _, a := range allowed {
+			ok = ok || k == a
+		}
+		if !ok {
+			return fmt.Errorf("unexpected parameter %q", k)
+		}
+	}
+	return nil
+}
+
+// Zeta is the Riemann zeta function ζ(s)
+type Zeta struct{}
+
+// Eval ...
+func (Zeta) Eval(s complex128) complex128 {
+	return zeta(s)
+}
+
+// Eta is the Dirichlet eta function η(s) = (1 - 2^(1-s)) ζ(s)
+type Eta struct{}
+
+// Eval ...
+func (Eta) Eval(s complex128) complex128 {
+	return (1 - pow(2, 1-s)) * zeta(s)
+}
+
+// Xi is the Riemann xi function ξ(s) = s(s-1)/2 π^(-s/2) Γ(s/2) ζ(s)
+type Xi struct{}
+
+// Eval ...
+func (Xi) Eval(s complex128) complex128 {
+	g := cmplx.Exp(-s/2*complex(math.Log(math.Pi), 0) + logGamma(s/2))
+	return s * (s - 1) / 2 * g * zeta(s)
+}
+
+// Hurwitz is the Hurwitz zeta function ζ(s, a) = Σ (k + a)^-s. It is computed
+// with the same Euler-Maclaurin formula as ems.
+type Hurwitz struct {
+	A float64
+}
+
+// Eval ...
+func (h Hurwitz) Eval(s complex128) complex128 {
+	return hurwitz(s, h.A)
+}
+
+func hurwitz(s complex128, a float64) complex128 {
+	N := int(cmplx.Abs(s))
+	if N > maxN {
+		N = maxN
+	}
+	if N < minN {
+		N = minN
+	}
+
+	var z, t, temp complex128
+	for k := 0; k < N; k++ {
+		z += pow(float64(k)+a, -s)
+	}
+
+	q := float64(N) + a
+	z += pow(q, 1-s) / (s - 1)
+	z += 0.5 * pow(q, -s)
+
+	for k := 1; k < 20; k++ {
+		t += complex(bCoeff[k], 0) * pochhammer(s, (2*k)-1) * pow(q, complex(float64(1-(2*k)), 0)-s)
+
+		if real(t-temp) == 0.0 {
+			break
+		}
+		temp = t
+	}
+	return z + t
+}
+
+// DirichletL is the Dirichlet L-function L(s, χ) = Σ χ(n) n^-s for the k'th
+// character modulo q. It is computed from Hurwitz zeta functions:
+//
+//	L(s, χ) = q^-s Σ χ(r) ζ(s, r/q)    for r = 1 ... q
+type DirichletL struct {
+	Q, K  int
+	chars []complex128
+}
+
+// NewDirichletL constructs the L-function for the k'th character modulo q.
+// Small odd primes and 4 are supported. Characters modulo a prime q are
+// numbered 0 (the principal character) to q-2.
+func NewDirichletL(q, k int) (*DirichletL, error) {
+	chars, err := dirichletCharacter(q, k)
+	if err != nil {
+		return nil, err
+	}
+	return &DirichletL{Q: q, K: k, chars: chars}, nil
+}
+
+// Eval ...
+func (l *DirichletL) Eval(s complex128) complex128 {
+	var z complex128
+	for r := 1; r < l.Q; r++ {
+		if l.chars[r] != 0 {
+			z += l.chars[r] * hurwitz(s, float64(r)/float64(l.Q))
+		}
+	}
+	return pow(float64(l.Q), -s) * z
+}
+
+// dirichletCharacter returns χ(r) for r = 0 ... q-1
+func dirichletCharacter(q, k int) ([]complex128, error) {
+	chars := make([]complex128, q)
+
+	if q == 4 {
+		if k < 0 || k > 1 {
+			return nil, fmt.Errorf("there are 2 characters modulo 4, got k=%d", k)
+		}
+		chars[1] = 1
+		chars[3] = 1
+		if k == 1 {
+			chars[3] = -1
+		}
+		return chars, nil
+	}
+
+	if q < 3 || q > 100 || !isPrime(q) {
+		return nil, fmt.Errorf("modulus must be 4 or an odd prime up to 100, got q=%d", q)
+	}
+	if k < 0 || k > q-2 {
+		return nil, fmt.Errorf("there are %d characters modulo %d, got k=%d", q-1, q, k)
+	}
+
+	// χ(g^j) = e^(2πijk/(q-1)) for a primitive root g
+	g := primitiveRoot(q)
+	r := 1
+	for j := 0; j < q-1; j++ {
+		chars[r] = cmplx.Exp(complex(0, 2*math.Pi*float64(j*k)/float64(q-1)))
+		r = r * g % q
+	}
+	return chars, nil
+}
+
+func isPrime(n int) bool {
+	for d := 2; d*d <= n; d++ {
+		if n%d == 0 {
+			return false
+		}
+	}
+	return n > 1
+}
+
+// primitiveRoot finds the smallest generator of the multiplicative group
+// modulo the prime q
+func primitiveRoot(q int) int {
+	for g := 2; g < q; g++ {
+		r, order := g, 1
+		for r != 1 {
+			r = r * g % q
+			order++
+		}
+		if order == q-1 {
+			return g
+		}
+	}
+	return 1
+}
@@ -0,0 +1,221 @@
+package zeta
+
+import (
+	"math"
+	"math/cmplx"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// closeTo reports whether got is within a relative tolerance of want
+func closeTo(got, want complex128, tol float64) bool {
+	return cmplx.Abs(got-want) <= tol*math.Max(1, cmplx.Abs(want))
+}
+
+var functionPoints = []complex128{2, 3 + 2i, 0.5 + 14.134725i, 0.25 + 30i, 1.5 - 7i}
+
+func TestHurwitz(t *testing.T) {
+	for _, s := range functionPoints {
+		if got, want := (Hurwitz{A: 1}).Eval(s), zeta(s); !closeTo(got, want, 1e-9) {
+			t.Errorf("ζ(%v, 1) = %v, want ζ(s) = %v", s, got, want)
+		}
+
+		// ζ(s, 1/2) = (2^s - 1) ζ(s)
+		if got, want := (Hurwitz{A: 0.5}).Eval(s), (pow(2, s)-1)*zeta(s); !closeTo(got, want, 1e-9) {
+			t.Errorf("ζ(%v, 1/2) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestEta(t *testing.T) {
+	known := map[complex128]float64{
+		2: math.Pi * math.Pi / 12,
+		4: 7 * math.Pow(math.Pi, 4) / 720,
+	}
+	for s, want := range known {
+		if got := (Eta{}).Eval(s); !closeTo(got, complex(want, 0), 1e-10) {
+			t.Errorf("η(%v) = %v, want %v", s, got, want)
+		}
+	}
+
+	for _, s := range functionPoints {
+		if got, want := (Eta{}).Eval(s), (1-pow(2, 1-s))*zeta(s); !closeTo(got, want, 1e-12) {
+			t.Errorf("η(%v) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestXi(t *testing.T) {
+	if got := (Xi{}).Eval(2); !closeTo(got, math.Pi/6, 1e-10) {
+		t.Errorf("ξ(2) = %v, want π/6", got)
+	}
+
+	for _, s := range append(functionPoints, -3+5i, 0.1+0.2i) {
+		if got, want := (Xi{}).Eval(s), (Xi{}).Eval(1-s); !closeTo(got, want, 1e-8) {
+			t.Errorf("ξ(%v) = %v but ξ(1-s) = %v", s, got, want)
+		}
+	}
+}
+
+func TestDirichletL(t *testing.T) {
+	// the non-principal character modulo 4 gives Dirichlet's beta function
+	beta, err := NewDirichletL(4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	known := map[complex128]float64{
+		2: 0.915965594177219015, // Catalan's constant
+		3: math.Pow(math.Pi, 3) / 32,
+		5: 5 * math.Pow(math.Pi, 5) / 1536,
+	}
+	for s, want := range known {
+		if got := beta.Eval(s); !closeTo(got, complex(want, 0), 1e-10) {
+			t.Errorf("β(%v) = %v, want %v", s, got, want)
+		}
+	}
+
+	// and the principal character ζ(s) without its even terms
+	principal, err := NewDirichletL(4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range functionPoints {
+		if got, want := principal.Eval(s), (1-pow(2, -s))*zeta(s); !closeTo(got, want, 1e-9) {
+			t.Errorf("L(%v, χ0 mod 4) = %v, want %v", s, got, want)
+		}
+	}
+
+	// characters modulo 5 are periodic and multiplicative
+	chars, err := dirichletCharacter(5, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for a := 1; a < 5; a++ {
+		for b := 1; b < 5; b++ {
+			if got := chars[a*b%5]; !closeTo(got, chars[a]*chars[b], 1e-12) {
+				t.Errorf("χ(%d·%d) = %v, want %v", a, b, got, chars[a]*chars[b])
+			}
+		}
+	}
+}
+
+func TestNewFunction(t *testing.T) {
+	valid := []struct {
+		name   string
+		params map[string]float64
+	}{
+		{"", nil},
+		{"zeta", nil},
+		{"eta", nil},
+		{"xi", map[string]float64{}},
+		{"hurwitz", map[string]float64{"a": 1}},
+		{"dirichlet", map[string]float64{"q": 4, "k": 1}},
+		{"dirichlet", map[string]float64{"q": 97, "k": 95}},
+	}
+	for _, c := range valid {
+		if _, err := NewFunction(c.name, c.params); err != nil {
+			t.Errorf("%s %v: %v", c.name, c.params, err)
+		}
+	}
+
+	invalid := []struct {
+		name   string
+		params map[string]float64
+	}{
+		{"gamma", nil},
+		{"zeta", map[string]float64{"a": 1}},
+		{"hurwitz", nil},
+		{"hurwitz", map[string]float64{"a": 0}},
+		{"hurwitz", map[string]float64{"a": 1.5}},
+		{"hurwitz", map[string]float64{"a": 0.5, "q": 1}},
+		{"dirichlet", map[string]float64{"q": 6, "k": 1}},
+		{"dirichlet", map[string]float64{"q": 101, "k": 1}},
+		{"dirichlet", map[string]float64{"q": 4, "k": 2}},
+		{"dirichlet", map[string]float64{"q": 5, "k": 4}},
+		{"dirichlet", map[string]float64{"q": 5.5, "k": 1}},
+		{"dirichlet", map[string]float64{"q": 5, "k": 1.5}},
+		{"dirichlet", map[string]float64{"q": math.Inf(1), "k": 1}},
+	}
+	for _, c := range invalid {
+		if _, err := NewFunction(c.name, c.params); err == nil {
+			t.Errorf("%s %v constructed without an error", c.name, c.params)
+		}
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	got, err := ParseParams(" q=5, k = 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]float64{"q": 5, "k": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parsed %v, want %v", got, want)
+	}
+
+	if got, err := ParseParams(""); err != nil || len(got) != 0 {
+		t.Errorf("empty parameters parsed to %v, %v", got, err)
+	}
+
+	for _, s := range []string{"a", "a=", "a=x", "a=0.5,,"} {
+		if _, err := ParseParams(s); err == nil {
+			t.Errorf("%q parsed without an error", s)
+		}
+	}
+}
+
+func TestSetName(t *testing.T) {
+	cases := []struct {
+		mode, name string
+		params     map[string]float64
+		want       string
+	}{
+		{"", "", nil, ""},
+		{"", "zeta", nil, ""},
+		{"", "zeta", map[string]float64{}, ""},
+		{ModeNewton, "", nil, "newton_zeta"},
+		{"", "eta", nil, "eta"},
+		{"", "hurwitz", map[string]float64{"a": 0.5}, "hurwitz_a0.5"},
+		{ModeDomain, "dirichlet", map[string]float64{"q": 5, "k": 2}, "domain_dirichlet_k2_q5"},
+		{"", "hurwitz", map[string]float64{"a": 1e-7}, "hurwitz_a1e-07"},
+	}
+	for _, c := range cases {
+		set := SetName(c.mode, c.name, c.params)
+		if set != c.want {
+			t.Errorf("SetName(%q, %q, %v) = %q, want %q", c.mode, c.name, c.params, set, c.want)
+		}
+
+		// and back again
+		parsed, err := ParseSetName(set)
+		if err != nil {
+			t.Fatalf("%q: %v", set, err)
+		}
+		if again := SetName(parsed.Mode, parsed.Function, parsed.Params); again != set {
+			t.Errorf("%q parsed to %q", set, again)
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	query, _ := url.ParseQuery("function=hurwitz&a=0.5&v=123&zoom=3&mode=domain")
+	tile := &Tile{}
+	if err := tile.ParseQuery(query); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tile.Params, map[string]float64{"a": 0.5}) || tile.Mode != ModeDomain {
+		t.Errorf("parsed params %v in mode %q", tile.Params, tile.Mode)
+	}
+
+	// a parameter of another function is not one of zeta's
+	query, _ = url.ParseQuery("a=0.5&q=5")
+	if err := tile.ParseQuery(query); err != nil || tile.Params != nil || tile.Set() != "" {
+		t.Errorf("zeta parsed params %v, %v", tile.Params, err)
+	}
+
+	for _, q := range []string{"function=gamma", "function=hurwitz", "function=hurwitz&a=x", "lut=maybe", "mode=newton&function=xi"} {
+		query, _ := url.ParseQuery(q)
+		if err := (&Tile{}).ParseQuery(query); err == nil {
+			t.Errorf("%s parsed without an error", q)
+		}
+	}
+}
@@ -1,15 +1,20 @@
 package zeta
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
+	"image/png"
+	"io/ioutil"
+	"math"
 	"os"
-	"path"
+	"path/filepath"
 	"sort"
 	"sync"
-
-	"golang.org/x/image/bmp"
+	"time"
 )
 
 const (
@@ -20,41 +25,82 @@ const (
 	// the lookup table:
 	// 250 <= 100*4
 	LUTTolerance = 4
+
+	// lutMaxSpread is how much neighbouring counts may differ for a count to
+	// be looked up. Counts near the fixed point already vary this much with
+	// rounding.
+	lutMaxSpread = 2
+
+	// lutSuffix ends the names of sets computed with lookup tables
+	lutSuffix = "lut"
 )
 
-// LUT ...
+// LUT is a lookup table of zeta iteration counts over a rectangle of the
+// plane. Row y, column x holds the count of the point
+// Min + (x + 0.5)/PPU + (y + 0.5)/PPU i. A count of 0 is unknown.
 type LUT struct {
-	ID            int
-	FName         string
-	Min, Max      complex128
-	PPU           int
+	Name string     `json:"name"`
+	File string     `json:"file"` // PNG file, relative to the manifest
+	Min  [2]float64 `json:"min"`  // real, imaginary
+	Max  [2]float64 `json:"max"`
+	PPU  float64    `json:"ppu"`
+
 	width, height int
-	img           image.Image
+	counts        []uint16
+}
+
+// LUTSet is the set of lookup tables listed in a manifest file, ordered from
+// the finest resolution to the coarsest
+type LUTSet struct {
+	// Tolerance replaces LUTTolerance if it is set
+	Tolerance float64 `json:"tolerance,omitempty"`
+	LUTs      []*LUT  `json:"luts"`
+
+	dir string
+}
+
+// ReadLUTManifest reads the manifest without loading the tables
+func ReadLUTManifest(fname string) (*LUTSet, error) {
+	b, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &LUTSet{}
+	if err := json.Unmarshal(b, set); err != nil {
+		return nil, fmt.Errorf("invalid LUT manifest %s: %v", fname, err)
+	}
+	set.dir = filepath.Dir(fname)
+
+	for _, l := range set.LUTs {
+		if l.PPU <= 0 || l.Max[0] <= l.Min[0] || l.Max[1] <= l.Min[1] {
+			return nil, fmt.Errorf("invalid LUT %s in %s", l.Name, fname)
+		}
+		l.width = int(math.Round((l.Max[0] - l.Min[0]) * l.PPU))
+		l.height = int(math.Round((l.Max[1] - l.Min[1]) * l.PPU))
+	}
+	sort.SliceStable(set.LUTs, func(i, j int) bool { return set.LUTs[i].PPU > set.LUTs[j].PPU })
+
+	return set, nil
 }
 
-// LoadLUTs loads all look up tables and returns them in a sorted array
-func LoadLUTs() ([]*LUT, error) {
-	luts := make([]*LUT, 6)
-	luts[0] = &LUT{ID: 0, FName: "CL100000.bmp", Min: .975 - .025i, Max: 1.025 + .025i, PPU: 100000}
-	luts[1] = &LUT{ID: 1, FName: "CL010000.bmp", Min: .75 - .25i, Max: 1.25 + .25i, PPU: 10000}
-	luts[2] = &LUT{ID: 2, FName: "CL001000.bmp", Min: -1.5 - 2.5i, Max: 3.5 + 2.5i, PPU: 1000}
-	luts[3] = &LUT{ID: 3, FName: "CL000100.bmp", Min: -24 - 25i, Max: 26 + 25i, PPU: 100}
-	luts[4] = &LUT{ID: 4, FName: "CL000010.bmp", Min: -249 - 250i, Max: 251 + 250i, PPU: 10}
-	luts[5] = &LUT{ID: 5, FName: "CL000001.bmp", Min: -2499 - 2500i, Max: 2501 + 2500i, PPU: 1}
+// LoadLUTs reads the manifest and loads every lookup table in it
+func LoadLUTs(fname string) (*LUTSet, error) {
+	set, err := ReadLUTManifest(fname)
+	if err != nil {
+		return nil, err
+	}
 
-	errs := make(chan error, len(luts))
+	errs := make(chan error, len(set.LUTs))
 	wg := &sync.WaitGroup{}
-	wg.Add(len(luts))
-	for i := range luts {
-		go func(id int) {
+	wg.Add(len(set.LUTs))
+	for _, l := range set.LUTs {
+		go func(l *LUT) {
 			defer wg.Done()
-
-			l := luts[id]
-			if err := l.Load(); err != nil {
-				fmt.Println(err)
+			if err := l.Load(set.Path(l)); err != nil {
 				errs <- err
 			}
-		}(i)
+		}(l)
 	}
 	wg.Wait()
 
@@ -62,52 +108,311 @@ func LoadLUTs() ([]*LUT, error) {
 	if len(errs) > 0 {
 		return nil, <-errs
 	}
+	return set, nil
+}
+
+// Path returns the path to the file of a table in the set
+func (s *LUTSet) Path(l *LUT) string {
+	if filepath.IsAbs(l.File) {
+		return l.File
+	}
+	return filepath.Join(s.dir, l.File)
+}
+
+// Lookup finds the iteration count of z in the finest table that covers it,
+// has the count and is within the tolerance of the resolution ppu
+func (s *LUTSet) Lookup(z complex128, ppu float64) (uint16, bool) {
+	tolerance := s.Tolerance
+	if tolerance == 0 {
+		tolerance = LUTTolerance
+	}
+
+	for _, l := range s.LUTs {
+		if ppu > l.PPU*tolerance {
+			// the rest are coarser still
+			return 0, false
+		}
+		if its, ok := l.Lookup(z); ok {
+			return its, true
+		}
+	}
+	return 0, false
+}
+
+// Size returns the width and height of the table in pixels
+func (l *LUT) Size() (int, int) {
+	return l.width, l.height
+}
+
+// Coord returns the point pixel x, y of the table holds the count of
+func (l *LUT) Coord(x, y int) complex128 {
+	return complex(l.Min[0]+(float64(x)+0.5)/l.PPU, l.Min[1]+(float64(y)+0.5)/l.PPU)
+}
+
+// Lookup returns the iteration count of z if the table has it. Counts that
+// differ from their neighbours by more than lutMaxSpread are not used, z is
+// too close to the edge of a basin for the table to tell its count.
+func (l *LUT) Lookup(z complex128) (uint16, bool) {
+	if l.counts == nil {
+		return 0, false
+	}
+
+	u := (real(z) - l.Min[0]) * l.PPU
+	v := (imag(z) - l.Min[1]) * l.PPU
+	if !(u >= 1 && v >= 1 && u < float64(l.width-1) && v < float64(l.height-1)) {
+		return 0, false
+	}
 
-	sort.Slice(luts, func(i, j int) bool { return luts[i].ID < luts[j].ID })
+	i := int(v)*l.width + int(u)
+	its := l.counts[i]
+	if its == 0 {
+		return 0, false
+	}
+	for _, n := range [4]int{i - 1, i + 1, i - l.width, i + l.width} {
+		if l.counts[n] == 0 || absDiff(l.counts[n], its) > lutMaxSpread {
+			return 0, false
+		}
+	}
+	return its, true
+}
+
+func absDiff(a, b uint16) uint16 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
 
-	return luts, nil
+// Counts returns the iteration counts row by row, allocating them if the
+// table isn't loaded
+func (l *LUT) Counts() []uint16 {
+	if l.counts == nil {
+		l.counts = make([]uint16, l.width*l.height)
+	}
+	return l.counts
 }
 
-// Load ...
-func (l *LUT) Load() error {
-	cwd, err := os.Getwd()
+// Load reads the counts from a 16 bit grayscale PNG
+func (l *LUT) Load(fname string) error {
+	f, err := os.Open(fname)
 	if err != nil {
 		return err
 	}
+	defer f.Close()
 
-	fpath := path.Join(cwd, "lut", l.FName)
-	f, err := os.Open(fpath)
+	img, err := png.Decode(f)
 	if err != nil {
-		return err
+		return fmt.Errorf("LUT %s: %v", fname, err)
+	}
+
+	gray, ok := img.(*image.Gray16)
+	if !ok {
+		return fmt.Errorf("LUT %s is not a 16 bit grayscale image", fname)
+	}
+
+	b := gray.Bounds()
+	if b.Dx() != l.width || b.Dy() != l.height {
+		return fmt.Errorf("LUT %s is %dx%d, expected %dx%d", fname, b.Dx(), b.Dy(), l.width, l.height)
 	}
 
-	img, err := bmp.Decode(f)
+	counts := make([]uint16, l.width*l.height)
+	for y := 0; y < l.height; y++ {
+		for x := 0; x < l.width; x++ {
+			counts[y*l.width+x] = gray.Gray16At(b.Min.X+x, b.Min.Y+y).Y
+		}
+	}
+	l.counts = counts
+	return nil
+}
+
+// Save writes the counts as a 16 bit grayscale PNG
+func (l *LUT) Save(fname string) error {
+	if l.counts == nil {
+		return errors.New("LUT " + l.Name + " has no counts")
+	}
+
+	img := image.NewGray16(image.Rect(0, 0, l.width, l.height))
+	for i, its := range l.counts {
+		img.SetGray16(i%l.width, i/l.width, color.Gray16{Y: its})
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fname), os.ModeDir|os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.Create(fname)
 	if err != nil {
 		return err
 	}
+	defer f.Close()
 
-	l.img = img
-	l.width = img.Bounds().Max.X - img.Bounds().Min.X
-	l.height = img.Bounds().Max.Y - img.Bounds().Min.Y
+	return png.Encode(f, img)
+}
 
-	return nil
+// Patches splits the table into tiles of width pixels that can be computed by
+// any backend, skipping those already known. Tiles on the right and top
+// edges overhang the table.
+func (l *LUT) Patches(width int) []*Tile {
+	units := float64(width) / l.PPU
+
+	patches := []*Tile{}
+	for py := 0; py*width < l.height; py++ {
+		for px := 0; px*width < l.width; px++ {
+			t := &Tile{X: px, Y: py, Width: width}
+			if l.known(t) {
+				continue
+			}
+
+			// tiles compute the corner of each pixel, tables the middle
+			c := l.Coord(px*width, py*width)
+			t.Region = &Region{Min: [2]float64{real(c), imag(c)}, Units: units}
+			patches = append(patches, t)
+		}
+	}
+	return patches
+}
+
+// SetPatch copies the counts of a computed patch into the table
+func (l *LUT) SetPatch(t *Tile) {
+	counts := l.Counts()
+	l.eachPixel(t, func(i, j int) {
+		counts[i] = t.Data[j]
+	})
 }
 
-// Lookup finds the color in a lookup table
-func (l *LUT) Lookup(z complex128, ppu int) (color.RGBA, bool) {
-	rng := l.Max - l.Min
-	u := (real(z) - real(l.Min)) / real(rng)
-	v := (imag(z) - imag(l.Min)) / imag(rng)
+// known checks every count of the patch is known
+func (l *LUT) known(t *Tile) bool {
+	counts := l.Counts()
+	known := true
+	l.eachPixel(t, func(i, j int) {
+		if counts[i] == 0 {
+			known = false
+		}
+	})
+	return known
+}
+
+// eachPixel calls fn with the index into the table and the patch of each of
+// the patch's pixels inside the table
+func (l *LUT) eachPixel(t *Tile, fn func(i, j int)) {
+	for y := 0; y < t.Width && t.Y*t.Width+y < l.height; y++ {
+		for x := 0; x < t.Width && t.X*t.Width+x < l.width; x++ {
+			fn((t.Y*t.Width+y)*l.width+t.X*t.Width+x, y*t.Width+x)
+		}
+	}
+}
+
+// iterateLUT is iterateFrom, finishing as soon as an iterate lands on a
+// lookup table. The count of s is the number of iterates taken to reach z
+// plus the count of z.
+func iterateLUT(f Function, luts *LUTSet, ppu float64, s, z complex128, epsilon float64) uint16 {
+	var its uint16 = 1
+	for {
+		diff := math.Abs(real(z) - real(s))
+		cabsz := mod(z)
+		if math.IsNaN(cabsz) || math.IsNaN(diff) || diff <= epsilon || cabsz >= cabsZMax || its >= maxTileITs {
+			// let the full iteration decide how this step ends
+			return tileCount(its - 1 + iterateFrom(f, s, z, epsilon))
+		}
 
-	x := int(u * float64(l.width))
-	y := int(v * float64(l.height))
+		if n, ok := luts.Lookup(z, ppu); ok {
+			return tileCount(its + n)
+		}
 
-	var col color.RGBA
-	ok := x >= 0 && x < l.width && y >= 0 && y < l.height && ppu <= l.PPU*4
-	if ok {
-		col = l.img.At(x, y).(color.RGBA)
-		col.A = 255
+		s, z = z, f.Eval(z)
+		its++
 	}
+}
+
+// Fill sets the unknown counts of the table from the stored iteration tiles
+// at the zoom level and returns how many it found. Tiles are looked up as
+// wide as the set's metadata says and those that aren't stored leave their
+// counts unknown.
+func (l *LUT) Fill(zoom int) (int, error) {
+	m, err := LoadMetadata(os.Getenv("ZETA_TILE_PATH"), "")
+	if err != nil && err != ErrNoMetadata {
+		return 0, err
+	}
+	proj := m.Projection()
+
+	counts := l.Counts()
+	filled := 0
+
+	// tiles are cached a row of tiles at a time, nil if missing
+	tileY := math.MinInt32
+	cache := make(map[int]*Tile)
+
+	for y := 0; y < l.height; y++ {
+		for x := 0; x < l.width; x++ {
+			if counts[y*l.width+x] != 0 {
+				continue
+			}
+
+			c := l.Coord(x, y)
+			at := proj.TileAt(zoom, c)
+			if at.Y != tileY {
+				tileY = at.Y
+				cache = make(map[int]*Tile)
+			}
+
+			t, ok := cache[at.X]
+			if !ok {
+				if _, err := at.Exists(); err == nil {
+					if err := at.Load(); err != nil {
+						return filled, err
+					}
+					t = at
+				}
+				cache[at.X] = t
+			}
+			if t == nil || len(t.Data) != t.Width*t.Width {
+				continue
+			}
+
+			px, py := t.Pixel(c)
+			counts[y*l.width+x] = t.Data[py*t.Width+px]
+			filled++
+		}
+	}
+	return filled, nil
+}
+
+// LUTReport compares a tile computed with lookup tables to the full
+// computation
+type LUTReport struct {
+	Tile       *Tile
+	Mismatched int    // pixels whose counts differ
+	MaxDiff    uint16 // largest difference in counts
+	Full       time.Duration
+	Assisted   time.Duration
+}
+
+// CompareLUT computes the zeta iteration tile with and without the lookup
+// tables
+func CompareLUT(ctx context.Context, t *Tile, luts *LUTSet) *LUTReport {
+	r := &LUTReport{Tile: t}
+
+	start := time.Now()
+	full := (&Algo{}).Compute(ctx, t.Min(), t.Max(), t.Width)
+	r.Full = time.Since(start)
+
+	start = time.Now()
+	assisted := (&Algo{LUTs: luts}).Compute(ctx, t.Min(), t.Max(), t.Width)
+	r.Assisted = time.Since(start)
+
+	for i := range full {
+		if full[i] == assisted[i] {
+			continue
+		}
+		r.Mismatched++
+
+		if d := absDiff(full[i], assisted[i]); d > r.MaxDiff {
+			r.MaxDiff = d
+		}
+	}
+	return r
+}
 
-	return col, ok
+// MismatchRate is the fraction of pixels whose counts differ
+func (r *LUTReport) MismatchRate() float64 {
+	return float64(r.Mismatched) / float64(r.Tile.Width*r.Tile.Width)
 }
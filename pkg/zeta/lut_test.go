@@ -0,0 +1,236 @@
+package zeta
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+var (
+	testLUTOnce   sync.Once
+	testLUTCounts map[string][]uint16
+)
+
+// testLUTs computes a lookup table around the attracting fixed point of zeta
+// near 1.83, which most iterations pass close to
+func testLUTs(t *testing.T) *LUTSet {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "manifest.json")
+	err := ioutil.WriteFile(manifest, []byte(`{
+	"luts": [
+		{"name": "coarse", "file": "coarse.png", "min": [0, -4], "max": [8, 4], "ppu": 4},
+		{"name": "fine", "file": "lut/fine.png", "min": [1.625, -0.25], "max": [2.125, 0.25], "ppu": 128}
+	]
+}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := ReadLUTManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLUTOnce.Do(func() {
+		testLUTCounts = make(map[string][]uint16)
+		for _, l := range set.LUTs {
+			counts := l.Counts()
+			w, h := l.Size()
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					counts[y*w+x] = iterate(Zeta{}, l.Coord(x, y), epsilon)
+				}
+			}
+			testLUTCounts[l.Name] = counts
+		}
+	})
+
+	for _, l := range set.LUTs {
+		copy(l.Counts(), testLUTCounts[l.Name])
+		if err := l.Save(set.Path(l)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loaded, err := LoadLUTs(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loaded
+}
+
+func TestLoadLUTs(t *testing.T) {
+	luts := testLUTs(t)
+	if len(luts.LUTs) != 2 || luts.LUTs[0].Name != "fine" {
+		t.Fatal("tables are not ordered finest first")
+	}
+
+	fine := luts.LUTs[0]
+	if w, h := fine.Size(); w != 64 || h != 64 {
+		t.Fatalf("fine table is %dx%d", w, h)
+	}
+
+	s := fine.Coord(10, 20)
+	its, ok := luts.Lookup(s, 128)
+	if !ok || its != fine.Counts()[20*64+10] {
+		t.Fatal("lookup does not match the saved count:", its, ok)
+	}
+
+	// too fine a resolution for any table
+	if _, ok := luts.Lookup(s, 128*LUTTolerance+1); ok {
+		t.Fatal("lookup ignored the tolerance")
+	}
+
+	// only the coarse table covers this
+	if _, ok := luts.Lookup(complex(5, 3), 16); !ok {
+		t.Fatal("lookup did not fall back to the coarse table")
+	}
+	if _, ok := luts.Lookup(complex(-5, 3), 1); ok {
+		t.Fatal("lookup found a point outside every table")
+	}
+}
+
+func TestLoadLUTsMissing(t *testing.T) {
+	manifest := filepath.Join(t.TempDir(), "manifest.json")
+	ioutil.WriteFile(manifest, []byte(`{"luts": [{"name": "a", "file": "a.png", "min": [0, 0], "max": [1, 1], "ppu": 8}]}`), 0644)
+
+	if _, err := LoadLUTs(manifest); err == nil {
+		t.Fatal("missing table did not return an error")
+	}
+	if _, err := LoadLUTs(filepath.Join(os.TempDir(), "no-such-manifest.json")); err == nil {
+		t.Fatal("missing manifest did not return an error")
+	}
+}
+
+func TestCompareLUT(t *testing.T) {
+	luts := testLUTs(t)
+
+	for _, tile := range []*Tile{
+		{Zoom: 6, X: 0, Y: 0, Width: 32},
+		{Zoom: 4, X: -1, Y: 1, Width: 32},
+		{Zoom: 3, X: 1, Y: 3, Width: 32},
+	} {
+		r := CompareLUT(context.Background(), tile, luts)
+		t.Log(tile, "mismatched:", r.Mismatched, "max diff:", r.MaxDiff, "full:", r.Full, "assisted:", r.Assisted)
+
+		if r.MismatchRate() > 0.05 || r.MaxDiff > 2 {
+			t.Errorf("%s: %.1f%% of counts differ by up to %d", tile, 100*r.MismatchRate(), r.MaxDiff)
+		}
+	}
+}
+
+func TestLUTSet(t *testing.T) {
+	luts := testLUTs(t)
+
+	tile := &Tile{Zoom: 4, X: -1, Y: 1, Width: 32, LUT: true}
+	if tile.Set() != "zeta_lut" {
+		t.Fatalf("lookup table tiles are in set %q", tile.Set())
+	}
+	if err := (&CPUBackend{}).Compute(context.Background(), tile); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("computed a lookup table tile without tables: %v", err)
+	}
+
+	// the tables are only used for the tiles of the lookup table set
+	if err := (&CPUBackend{LUTs: luts}).Compute(context.Background(), tile); err != nil {
+		t.Fatal(err)
+	}
+	exact := &Tile{Zoom: 4, X: -1, Y: 1, Width: 32}
+	if err := (&CPUBackend{LUTs: luts}).Compute(context.Background(), exact); err != nil {
+		t.Fatal(err)
+	}
+	if want := (&Algo{}).Compute(context.Background(), exact.Min(), exact.Max(), exact.Width); !reflect.DeepEqual(exact.Data, want) {
+		t.Error("the tables were used for a tile of the exact set")
+	}
+	if want := (&Algo{LUTs: luts}).Compute(context.Background(), tile.Min(), tile.Max(), tile.Width); !reflect.DeepEqual(tile.Data, want) {
+		t.Error("the tables weren't used for a tile of the lookup table set")
+	}
+
+	for _, other := range []*Tile{{Function: "eta", LUT: true}, {Mode: ModeNewton, LUT: true}} {
+		if _, err := other.Func(); err == nil {
+			t.Errorf("%s tiles can be computed with lookup tables", other.Set())
+		}
+	}
+}
+
+func TestLUTFill(t *testing.T) {
+	defer os.Setenv("ZETA_TILE_PATH", os.Getenv("ZETA_TILE_PATH"))
+
+	// the default store and one retiled into 256 pixel tiles
+	for _, width := range []int{TileWidth, 256} {
+		root := t.TempDir()
+		os.Setenv("ZETA_TILE_PATH", root)
+		if width != TileWidth {
+			if err := SaveMetadata(root, "", &Metadata{TileWidth: width}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// a stored tile at zoom 2 from 0 with counts from its columns
+		tile := &Tile{Zoom: 2, X: 0, Y: 0, Width: width}
+		tile.Data = make([]uint16, width*width)
+		for i := range tile.Data {
+			tile.Data[i] = uint16(1 + i%width)
+		}
+		if err := tile.Save(); err != nil {
+			t.Fatal(err)
+		}
+
+		// the table straddles the stored tile and the missing one to its left
+		l := &LUT{Name: "fill", Min: [2]float64{-2, 0}, Max: [2]float64{2, 2}, PPU: 2, width: 8, height: 4}
+		filled, err := l.Fill(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filled != 16 {
+			t.Fatalf("%d pixel tiles: filled %d counts, want 16", width, filled)
+		}
+
+		counts := l.Counts()
+		for x := 0; x < 8; x++ {
+			c := l.Coord(x, 1)
+			want := uint16(0)
+			if real(c) > 0 {
+				px, _ := tile.Pixel(c)
+				want = tile.Data[px]
+			}
+			if counts[8+x] != want {
+				t.Fatalf("%d pixel tiles: count %d is %d, want %d", width, x, counts[8+x], want)
+			}
+		}
+	}
+}
+
+func TestLUTPatches(t *testing.T) {
+	l := &LUT{Name: "patches", Min: [2]float64{1.5, 2}, Max: [2]float64{2.5, 2.875}, PPU: 8, width: 8, height: 7}
+
+	patches := l.Patches(3)
+	if len(patches) != 9 {
+		t.Fatalf("got %d patches, want 9", len(patches))
+	}
+
+	backend := &CPUBackend{}
+	for _, p := range patches {
+		if err := backend.Compute(context.Background(), p); err != nil {
+			t.Fatal(err)
+		}
+		l.SetPatch(p)
+	}
+
+	counts := l.Counts()
+	for y := 0; y < 7; y++ {
+		for x := 0; x < 8; x++ {
+			want := iterate(Zeta{}, l.Coord(x, y), epsilon)
+			if got := counts[y*8+x]; absDiff(got, want) > 1 {
+				t.Fatalf("count %d, %d is %d, want %d", x, y, got, want)
+			}
+		}
+	}
+
+	if n := len(l.Patches(3)); n != 0 {
+		t.Fatalf("%d patches left after computing them all", n)
+	}
+}
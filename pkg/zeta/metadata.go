@@ -0,0 +1,162 @@
+package zeta
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+)
+
+// MetadataFile is the name of the file in each set's directory of the store
+// describing the set
+const MetadataFile = "metadata.json"
+
+// ErrNoMetadata is returned when loading the metadata of a set that has none
+var ErrNoMetadata = errors.New("Set has no metadata")
+
+// Metadata describes every tile of a set in the store. Sets stored before
+// there was metadata have none and were all made with the defaults.
+type Metadata struct {
+	// TileWidth is the width of the set's tiles in pixels at every zoom
+	// level. Tiles are 2^zoom pixels per unit whatever their width, so
+	// narrower tiles cover less of the plane.
+	TileWidth int `json:"tileWidth"`
+}
+
+// DefaultMetadata returns the metadata of sets that have none
+func DefaultMetadata() *Metadata {
+	return &Metadata{TileWidth: TileWidth}
+}
+
+// Projection returns the projection of the set's tiles onto the map
+func (m *Metadata) Projection() Projection {
+	return Projection{TileWidth: m.TileWidth}
+}
+
+// SetDir returns the directory of the set in the store at root
+func SetDir(root, set string) string {
+	return path.Join(root, set)
+}
+
+// LoadMetadata reads the metadata of the set from the store at root. If the
+// set has none the defaults are returned with ErrNoMetadata.
+func LoadMetadata(root, set string) (*Metadata, error) {
+	b, err := ioutil.ReadFile(path.Join(SetDir(root, set), MetadataFile))
+	if os.IsNotExist(err) {
+		return DefaultMetadata(), ErrNoMetadata
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Metadata{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("set %q metadata: %v", set, err)
+	}
+	if m.TileWidth < 1 {
+		return nil, fmt.Errorf("set %q metadata: tile width %d", set, m.TileWidth)
+	}
+	return m, nil
+}
+
+// SaveMetadata writes the metadata of the set to the store at root
+func SaveMetadata(root, set string, m *Metadata) error {
+	dir := SetDir(root, set)
+	if err := os.MkdirAll(dir, os.ModeDir|os.ModePerm); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path.Join(dir, MetadataFile), append(b, '\n'), 0644)
+}
+
+// InitMetadata returns the metadata of the set, first writing it if the set
+// has none (see ResolveMetadata)
+func InitMetadata(root, set string, width int) (*Metadata, error) {
+	m, saved, err := ResolveMetadata(root, set, width)
+	if err != nil || saved {
+		return m, err
+	}
+	return m, SaveMetadata(root, set, m)
+}
+
+// ResolveMetadata works out the metadata of the set without writing it and
+// reports whether the set already has it. A new set gets tiles width pixels
+// wide and one with tiles stored before there was metadata the defaults.
+// Width 0 takes the set's width; any other width must match it, as tiles of
+// one set can't be mixed.
+func ResolveMetadata(root, set string, width int) (*Metadata, bool, error) {
+	m, err := LoadMetadata(root, set)
+	if err == nil {
+		if width != 0 && width != m.TileWidth {
+			return nil, true, fmt.Errorf("set %q has %d pixel tiles, not %d", set, m.TileWidth, width)
+		}
+		return m, true, nil
+	}
+	if err != ErrNoMetadata {
+		return nil, false, err
+	}
+
+	if !hasZooms(SetDir(root, set)) && width != 0 {
+		m.TileWidth = width
+	}
+	if width != 0 && width != m.TileWidth {
+		return nil, false, fmt.Errorf("set %q was stored with %d pixel tiles, not %d", set, m.TileWidth, width)
+	}
+	return m, false, nil
+}
+
+// hasZooms reports whether the set directory holds any zoom levels of tiles.
+// The unnamed set shares its directory with the store's other sets.
+func hasZooms(dir string) bool {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, info := range infos {
+		if _, err := strconv.Atoi(info.Name()); err == nil && info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// SetWidths looks up the tile width of sets in a store, reading each set's
+// metadata once. Sets with neither metadata nor tiles are read again each
+// time, as they may yet be stored with another width. It is safe for
+// concurrent use.
+type SetWidths struct {
+	root   string
+	mu     sync.Mutex
+	widths map[string]int
+}
+
+// NewSetWidths constructs a SetWidths for the store at root
+func NewSetWidths(root string) *SetWidths {
+	return &SetWidths{root: root, widths: make(map[string]int)}
+}
+
+// Width returns the width of the set's tiles
+func (s *SetWidths) Width(set string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if w, ok := s.widths[set]; ok {
+		return w, nil
+	}
+	m, err := LoadMetadata(s.root, set)
+	if err != nil && err != ErrNoMetadata {
+		return 0, err
+	}
+	if err == nil || hasZooms(SetDir(s.root, set)) {
+		s.widths[set] = m.TileWidth
+	}
+	return m.TileWidth, nil
+}
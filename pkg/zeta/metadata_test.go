@@ -0,0 +1,77 @@
+package zeta
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestInitMetadata(t *testing.T) {
+	root := t.TempDir()
+
+	// a new set takes the width asked for
+	m, err := InitMetadata(root, "eta", 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.TileWidth != 256 {
+		t.Fatalf("new set has %d pixel tiles, want 256", m.TileWidth)
+	}
+	if m, err := LoadMetadata(root, "eta"); err != nil || m.TileWidth != 256 {
+		t.Fatalf("loaded %v, %v", m, err)
+	}
+	if _, err := InitMetadata(root, "eta", 512); err == nil {
+		t.Error("asked for 512 pixel tiles of a 256 pixel set without an error")
+	}
+	if m, err := InitMetadata(root, "eta", 0); err != nil || m.TileWidth != 256 {
+		t.Errorf("set's own width is %v, %v", m, err)
+	}
+
+	// a set stored before there was metadata has the default width
+	if err := os.MkdirAll(path.Join(SetDir(root, "newton_zeta"), "3"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := LoadMetadata(root, "newton_zeta"); err != ErrNoMetadata || m.TileWidth != TileWidth {
+		t.Fatalf("loaded %v, %v", m, err)
+	}
+	if _, err := InitMetadata(root, "newton_zeta", 256); err == nil {
+		t.Error("gave a stored set 256 pixel tiles")
+	}
+	if _, _, err := ResolveMetadata(root, "newton_zeta", 256); err == nil {
+		t.Error("planned 256 pixel tiles for a stored set")
+	}
+
+	// working out a new set's metadata doesn't write it
+	if m, saved, err := ResolveMetadata(root, "gamma", 128); err != nil || saved || m.TileWidth != 128 {
+		t.Errorf("new set resolved to %v, %v, %v", m, saved, err)
+	}
+	if _, err := LoadMetadata(root, "gamma"); err != ErrNoMetadata {
+		t.Errorf("resolving wrote the metadata: %v", err)
+	}
+	if m, err := InitMetadata(root, "newton_zeta", 0); err != nil || m.TileWidth != TileWidth {
+		t.Errorf("stored set has %v, %v", m, err)
+	}
+
+	// the unnamed set is new while only other sets are stored
+	if m, err := InitMetadata(root, "", 128); err != nil || m.TileWidth != 128 {
+		t.Errorf("new unnamed set has %v, %v", m, err)
+	}
+
+	widths := NewSetWidths(root)
+	for set, want := range map[string]int{"eta": 256, "newton_zeta": TileWidth, "": 128} {
+		if w, err := widths.Width(set); err != nil || w != want {
+			t.Errorf("set %q is %d wide, %v, want %d", set, w, err, want)
+		}
+	}
+
+	// a set with nothing stored yet is looked up again once it has metadata
+	if w, err := widths.Width("gamma"); err != nil || w != TileWidth {
+		t.Errorf("new set is %d wide, %v", w, err)
+	}
+	if _, err := InitMetadata(root, "gamma", 64); err != nil {
+		t.Fatal(err)
+	}
+	if w, err := widths.Width("gamma"); err != nil || w != 64 {
+		t.Errorf("new set is %d wide once stored, %v, want 64", w, err)
+	}
+}
@@ -0,0 +1,225 @@
+package zeta
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+const (
+	// ModeNewton renders the basins of Newton's method s -> s - f(s)/f'(s).
+	// Each pixel stores the root it converged to in the high byte and the
+	// number of iterations in the low byte (see NewtonData).
+	ModeNewton = "newton"
+
+	newtonMaxITs = 100
+	newtonTol    = 1e-10
+
+	// root indexes. Nontrivial zeros are numbered 1 upwards from the real
+	// axis, wrapping after newtonRootCount zeros in each half plane.
+	newtonNoRoot    = 0
+	newtonRootCount = 120
+	newtonTrivial   = 2*newtonRootCount + 1
+	newtonOtherRoot = 255
+)
+
+var (
+	// digammaCoeff are B(2k) / 2k for the asymptotic series of ψ(z)
+	digammaCoeff = [7]float64{
+		1.0 / 12,
+		-1.0 / 120,
+		1.0 / 252,
+		-1.0 / 240,
+		1.0 / 132,
+		-691.0 / 32760,
+		1.0 / 12,
+	}
+)
+
+// Derivative is implemented by functions that can evaluate their derivative,
+// which is needed to render them in ModeNewton
+type Derivative interface {
+	Deriv(s complex128) complex128
+}
+
+// Deriv ...
+func (Zeta) Deriv(s complex128) complex128 {
+	return zetaDeriv(s)
+}
+
+// Deriv of η(s) = (1 - 2^(1-s)) ζ(s)
+func (Eta) Deriv(s complex128) complex128 {
+	p := pow(2, 1-s)
+	return complex(math.Ln2, 0)*p*zeta(s) + (1-p)*zetaDeriv(s)
+}
+
+// NewtonData splits the data of a ModeNewton pixel into the root index and
+// iteration count. Root 0 means the iteration did not converge.
+func NewtonData(d uint16) (root, its uint8) {
+	return uint8(d >> 8), uint8(d)
+}
+
+// newton iterates Newton's method from s and returns the root it converged to
+// and the number of iterations, encoded as described for ModeNewton
+func newton(f Function, df Derivative, s complex128) uint16 {
+	z, i, reason := newtonIterate(f, df, s)
+	if reason != Converged {
+		return newtonNoRoot<<8 | i
+	}
+	return uint16(newtonRoot(z))<<8 | i
+}
+
+// NewtonReason is why Newton's method for f stopped when started from s:
+// Converged, Diverged or MaxIterations. It is empty if f has no derivative.
+func NewtonReason(f Function, s complex128) string {
+	df, ok := f.(Derivative)
+	if !ok {
+		return ""
+	}
+	_, _, reason := newtonIterate(f, df, s)
+	return reason
+}
+
+// newtonIterate iterates Newton's method from s and returns the last iterate,
+// the number of iterations and the reason it stopped
+func newtonIterate(f Function, df Derivative, s complex128) (complex128, uint16, string) {
+	var i uint16
+	for i = 1; i < newtonMaxITs; i++ {
+		step := f.Eval(s) / df.Deriv(s)
+		s -= step
+
+		if cmplx.IsNaN(s) || cmplx.IsInf(s) || mod(s) > cabsZMax {
+			return s, i, Diverged
+		}
+
+		if mod(step) < newtonTol*math.Max(1, mod(s)) {
+			return s, i, Converged
+		}
+	}
+	return s, i, MaxIterations
+}
+
+// newtonRoot indexes the zero of ζ at z. Nontrivial zeros above the real axis
+// are 1 to newtonRootCount and those below follow. The index of a zero is
+// estimated from the Riemann-von Mangoldt formula n ≈ θ(γ)/π + 3/2. That is
+// only right while Gram's law holds, which it first fails to near the 127th
+// zero, so from there a zero can be numbered one off and two neighbouring
+// zeros can share a colour. Trivial zeros -2, -4, ... follow the nontrivial
+// zeros.
+func newtonRoot(z complex128) uint8 {
+	re, im := real(z), math.Abs(imag(z))
+
+	if im < 1e-6 && re < 0 {
+		n := int(math.Round(-re / 2))
+		return uint8(newtonTrivial + (n-1)%(newtonOtherRoot-newtonTrivial))
+	}
+
+	if math.Abs(re-0.5) > 1e-6 || im < 1 {
+		return newtonOtherRoot
+	}
+
+	theta := im/2*math.Log(im/(2*math.Pi)) - im/2 - math.Pi/8 + 1/(48*im)
+	n := int(math.Round(theta/math.Pi + 1.5))
+	if n < 1 {
+		n = 1
+	}
+
+	root := 1 + (n-1)%newtonRootCount
+	if imag(z) < 0 {
+		root += newtonRootCount
+	}
+	return uint8(root)
+}
+
+// zetaDeriv is ζ'(s). Left of the critical strip it is found from the
+// functional equation ζ(s) = χ(s) ζ(1-s) so that
+//
+//	ζ'(s) = χ(s) (χ'(s)/χ(s) ζ(1-s) - ζ'(1-s))
+//	χ'(s)/χ(s) = log 2π + π/2 cot(πs/2) - ψ(1-s)
+func zetaDeriv(s complex128) complex128 {
+	// ζ'(conj(s)) = conj(ζ'(s)) and chiFactor needs Im(s) >= 0
+	if imag(s) < 0 {
+		return cmplx.Conj(zetaDeriv(cmplx.Conj(s)))
+	}
+
+	if real(s) >= 0 || imag(s) >= maxGamma {
+		return emsDeriv(s)
+	}
+
+	w := math.Pi / 2 * s
+	cot := -1i // the limit of cot(w) far above the real axis
+	if imag(w) < 20 {
+		cot = cmplx.Cot(w)
+	}
+
+	logChi := complex(math.Log(2*math.Pi), 0) + math.Pi/2*cot - digamma(1-s)
+	return chiFactor(s) * (logChi*zeta(1-s) - emsDeriv(1-s))
+}
+
+// emsDeriv differentiates the Euler-Maclaurin sum of ems term by term
+//
+//	d/ds k^-s = -log(k) k^-s
+//	d/ds (s)_n = (s)_n Σ 1/(s+i)    for i = 0 ... n-1
+func emsDeriv(s complex128) complex128 {
+	N := int(cmplx.Abs(s))
+	if N > maxN {
+		N = maxN
+	}
+	if N < minN {
+		N = minN
+	}
+
+	var z, t, temp complex128
+	for k := 1; k < N; k++ {
+		z -= complex(math.Log(float64(k)), 0) * pow(float64(k), -s)
+	}
+
+	logN := complex(math.Log(float64(N)), 0)
+	nPow := pow(float64(N), 1-s)
+	z += -logN*nPow/(s-1) - nPow/((s-1)*(s-1))
+	z += -0.5 * logN * pow(float64(N), -s)
+
+	poch := 1 + 0i  // (s)_(2k-1)
+	dPoch := 0 + 0i // d/ds (s)_(2k-1)
+	for k := 1; k < 20; k++ {
+		// extend the rising factorial by the two new factors s+2k-3 and s+2k-2,
+		// just one for k = 1
+		for i := 2*k - 3; i <= 2*k-2; i++ {
+			if i < 0 {
+				continue
+			}
+			f := s + complex(float64(i), 0)
+			dPoch = dPoch*f + poch
+			poch *= f
+		}
+
+		p := pow(float64(N), complex(float64(1-(2*k)), 0)-s)
+		t += complex(bCoeff[k], 0) * (dPoch - logN*poch) * p
+
+		if real(t-temp) == 0.0 {
+			break
+		}
+		temp = t
+	}
+	return z + t
+}
+
+// digamma is ψ(z) = Γ'(z)/Γ(z) from its asymptotic series, shifting small
+// arguments up with ψ(z+1) = ψ(z) + 1/z. Only valid for Re(z) > 0.
+func digamma(z complex128) complex128 {
+	var shift complex128
+	for cmplx.Abs(z) < 20 {
+		shift -= 1 / z
+		z++
+	}
+
+	g := cmplx.Log(z) - 1/(2*z)
+
+	z2 := z * z
+	zk := z2
+	for _, c := range digammaCoeff {
+		g -= complex(c, 0) / zk
+		zk *= z2
+	}
+
+	return g + shift
+}
@@ -0,0 +1,85 @@
+package zeta
+
+import (
+	"math"
+	"testing"
+)
+
+func TestZetaDeriv(t *testing.T) {
+	points := []complex128{
+		2 + 3i, 0.5 + 14i, 0.1 - 7i, // right of the reflection
+		-3 + 5i, -10 + 20i, -2.5 - 3i, -0.5 + 0.5i, // reflected through the functional equation
+		-5 + 460i, -1 - 600i, // above maxGamma
+	}
+
+	const h = 1e-5
+	for _, s := range points {
+		want := (zeta(s+h) - zeta(s-h)) / (2 * h)
+		if got := zetaDeriv(s); !closeTo(got, want, 1e-6) {
+			t.Errorf("ζ'(%v) = %v, central difference %v", s, got, want)
+		}
+	}
+
+	for _, s := range []complex128{2 + 3i, -3 + 5i, 0.5 - 10i} {
+		want := ((Eta{}).Eval(s+h) - (Eta{}).Eval(s-h)) / (2 * h)
+		if got := (Eta{}).Deriv(s); !closeTo(got, want, 1e-6) {
+			t.Errorf("η'(%v) = %v, central difference %v", s, got, want)
+		}
+	}
+}
+
+func TestDigamma(t *testing.T) {
+	const euler = 0.57721566490153286
+	if got := digamma(1); !closeTo(got, -euler, 1e-12) {
+		t.Errorf("ψ(1) = %v, want %v", got, -euler)
+	}
+	if got, want := digamma(0.5), -euler-2*math.Ln2; !closeTo(got, complex(want, 0), 1e-12) {
+		t.Errorf("ψ(1/2) = %v, want %v", got, want)
+	}
+	for _, z := range []complex128{0.3 + 2i, 5 - 40i, 30 + 1i} {
+		if got, want := digamma(z+1), digamma(z)+1/z; !closeTo(got, want, 1e-12) {
+			t.Errorf("ψ(%v + 1) = %v, want %v", z, got, want)
+		}
+	}
+}
+
+func TestNewtonRoot(t *testing.T) {
+	cases := []struct {
+		start complex128
+		root  uint8
+	}{
+		{-2.2 + 0.05i, newtonTrivial},
+		{-1.8 - 0.05i, newtonTrivial},
+		{-4.1 + 0.01i, newtonTrivial + 1},
+		{0.6 + 14.2i, 1},
+		{0.4 + 14.0i, 1},
+		{0.5 + 21.1i, 2},
+		{0.5 + 25.0i, 3},
+		{0.6 - 14.2i, 1 + newtonRootCount},
+	}
+
+	for _, c := range cases {
+		d := newton(Zeta{}, Zeta{}, c.start)
+		if root, its := NewtonData(d); root != c.root || its >= newtonMaxITs {
+			t.Errorf("Newton's method from %v found root %d in %d steps, want root %d", c.start, root, its, c.root)
+		}
+	}
+
+	// the index is the same anywhere within the tolerance of a zero
+	zeros := map[complex128]uint8{
+		-2:                    newtonTrivial,
+		complex(-2, 1e-9):     newtonTrivial,
+		0.5 + 14.134725142i:   1,
+		0.5000000001 + 14.13i: 1,
+		0.5 + 21.022039639i:   2,
+		0.5 + 25.010857580i:   3,
+		0.5 + 236.524229666i:  100,
+		0.5 - 14.134725142i:   1 + newtonRootCount,
+		2 + 3i:                newtonOtherRoot,
+	}
+	for z, want := range zeros {
+		if got := newtonRoot(z); got != want {
+			t.Errorf("zero %v has index %d, want %d", z, got, want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package zeta
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// Reasons an iteration terminates
+const (
+	Converged     = "converged"      // successive iterates agree to within epsilon
+	Escaped       = "escaped"        // |z| reached cabsZMax
+	Undefined     = "undefined"      // the function returned NaN
+	MaxIterations = "max-iterations" // no decision within the iteration limit
+	Diverged      = "diverged"       // a Newton iterate left the plane or was NaN
+)
+
+// OrbitParams controls an orbit trace. Zero values use the same settings as
+// the tile renderer.
+type OrbitParams struct {
+	Func    Function
+	Epsilon float64
+	MaxITs  int
+}
+
+// OrbitStep is a single iterate z = f(s) along with the values used to
+// decide whether to stop
+type OrbitStep struct {
+	Real  float64 `json:"real"`
+	Imag  float64 `json:"imag"`
+	Diff  float64 `json:"diff"`
+	Cabsz float64 `json:"cabsz"`
+}
+
+// Trace is the orbit of a seed point under iteration
+type Trace struct {
+	Real       float64     `json:"real"`
+	Imag       float64     `json:"imag"`
+	Steps      []OrbitStep `json:"steps"`
+	Iterations uint16      `json:"iterations"` // the count stored in a tile, at most maxTileITs
+	Reason     string      `json:"reason"`
+}
+
+// Orbit iterates from s exactly as the tile renderer does and records every
+// iterate. Iterates that are not finite are left out of the steps, the
+// reason says why the iteration stopped.
+func Orbit(s complex128, params OrbitParams) *Trace {
+	if params.Func == nil {
+		params.Func = Zeta{}
+	}
+	// zeta tiles are iterated by the row kernel
+	if _, ok := params.Func.(Zeta); ok {
+		params.Func = newRowKernel(s, s)
+	}
+	if params.Epsilon == 0 {
+		params.Epsilon = epsilon
+	}
+	if params.MaxITs == 0 {
+		params.MaxITs = maxITs
+	}
+
+	t := &Trace{Real: real(s), Imag: imag(s), Steps: []OrbitStep{}}
+	t.Iterations, t.Reason = iterateOrbit(params.Func, s, params.Func.Eval(s), params.Epsilon, params.MaxITs,
+		func(z complex128, diff, cabsz float64) {
+			if cmplx.IsNaN(z) || cmplx.IsInf(z) || math.IsInf(diff, 0) {
+				return
+			}
+			t.Steps = append(t.Steps, OrbitStep{Real: real(z), Imag: imag(z), Diff: diff, Cabsz: cabsz})
+		})
+	t.Iterations = tileCount(t.Iterations)
+
+	return t
+}
+
+// iterateOrbit iterates s -> f(s) until the real parts of successive iterates
+// agree to within epsilon, the modulus escapes or the value is undefined. It
+// returns the iteration count encoded for tiles, where escaping adds one to
+// the count if the last iterate is left of the imaginary axis and two if it is
+// right of it. If visit is not nil it is called with every iterate. The first
+// iterate f(s) is given as first so it can be computed in bulk.
+func iterateOrbit(f Function, s, first complex128, epsilon float64, maxITs int, visit func(z complex128, diff, cabsz float64)) (uint16, string) {
+	var i uint16
+	var cabsz float64
+	var diff float64 = 100
+
+	var z complex128
+
+	for !math.IsNaN(cabsz) && diff > epsilon && cabsz < cabsZMax && int(i) < maxITs {
+		if i == 0 {
+			z = first
+		} else {
+			z = f.Eval(s)
+		}
+		diff = math.Abs(real(z) - real(s))
+		cabsz = mod(z)
+		i++
+		s = z
+
+		if visit != nil {
+			visit(z, diff, cabsz)
+		}
+	}
+
+	switch {
+	case math.IsNaN(cabsz) || math.IsNaN(diff):
+		return i, Undefined
+	case cabsz >= cabsZMax:
+		if real(z) < 0.0 {
+			i++
+		} else {
+			i += 2
+		}
+		return i, Escaped
+	case diff <= epsilon:
+		return i, Converged
+	}
+	return i, MaxIterations
+}
@@ -0,0 +1,69 @@
+package zeta
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestOrbitMatchesStoredTile(t *testing.T) {
+	defer os.Setenv("ZETA_TILE_PATH", os.Getenv("ZETA_TILE_PATH"))
+	os.Setenv("ZETA_TILE_PATH", t.TempDir())
+
+	tiles := []*Tile{
+		{Zoom: 2, X: -1, Y: 0, Width: 32},
+		{Zoom: 3, X: 0, Y: 1, Width: 32},
+		{Zoom: 2, X: -1, Y: 0, Width: 32, Function: "eta"},
+	}
+
+	for _, computed := range tiles {
+		if err := (&CPUBackend{}).Compute(context.Background(), computed); err != nil {
+			t.Fatal(err)
+		}
+		if err := computed.Save(); err != nil {
+			t.Fatal(err)
+		}
+
+		tile := &Tile{Zoom: computed.Zoom, X: computed.X, Y: computed.Y, Width: computed.Width, Function: computed.Function}
+		if err := tile.Load(); err != nil {
+			t.Fatal(err)
+		}
+		f, err := tile.Func()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for y := 0; y < tile.Width; y++ {
+			for x := 0; x < tile.Width; x++ {
+				s := tile.pixelCoord(x, y)
+				trace := Orbit(s, OrbitParams{Func: f})
+				if stored := tile.Data[y*tile.Width+x]; trace.Iterations != stored {
+					t.Fatalf("%s: orbit of pixel %d, %d at %v has %d iterations (%s), stored %d",
+						tile.Set(), x, y, s, trace.Iterations, trace.Reason, stored)
+				}
+			}
+		}
+	}
+}
+
+func TestOrbitSteps(t *testing.T) {
+	trace := Orbit(-3+1i, OrbitParams{})
+	if trace.Reason != Converged || int(trace.Iterations) != len(trace.Steps) {
+		t.Fatalf("%d iterations and %d steps, %s", trace.Iterations, len(trace.Steps), trace.Reason)
+	}
+	last := trace.Steps[len(trace.Steps)-1]
+	if last.Diff > epsilon {
+		t.Errorf("converged with the last step %v apart", last.Diff)
+	}
+
+	// escaping counts one more left of the imaginary axis, two more right of it
+	trace = Orbit(1+1e-9i, OrbitParams{})
+	if trace.Reason != Escaped || int(trace.Iterations) != len(trace.Steps)+2 {
+		t.Errorf("pole: %d iterations and %d steps, %s", trace.Iterations, len(trace.Steps), trace.Reason)
+	}
+
+	trace = Orbit(-3+1i, OrbitParams{MaxITs: 2})
+	if trace.Reason != MaxIterations || trace.Iterations != 2 {
+		t.Errorf("limited to 2: %d iterations, %s", trace.Iterations, trace.Reason)
+	}
+}
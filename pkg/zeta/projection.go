@@ -0,0 +1,76 @@
+package zeta
+
+import "math"
+
+// Projection maps the complex plane onto the pixels of a slippy map such as
+// Leaflet's, with the real axis to the right and the imaginary axis up. Map
+// pixels count right and down from the origin and map tiles are TileWidth
+// pixels square, so at zoom z a map tile is the same square of the plane as
+// the Tile at that zoom, with its rows counting down rather than up.
+//
+// A point exactly on the edge between two rows of tiles is in the Tile above
+// it but the map tile below it, as a Tile includes its bottom edge and a map
+// tile its top edge.
+//
+// This is the only place the orientation of the map is decided. The web page
+// builds its CRS from Transformation and its tile URLs from Tile.
+type Projection struct {
+	TileWidth int
+}
+
+// DefaultProjection is the projection of sets without metadata (see
+// Metadata.Projection)
+var DefaultProjection = Projection{TileWidth: TileWidth}
+
+// Scale returns the map pixels per unit at the zoom level, the same as the
+// PPU of the tiles there
+func (p Projection) Scale(zoom int) float64 {
+	return (&Tile{Zoom: zoom, Width: p.TileWidth}).PPU()
+}
+
+// Transformation returns the a, b, c, d of the Leaflet transformation from
+// (lng, lat) = (real, imag) to map pixels at zoom 0: x = a·real + b and
+// y = c·imag + d. Leaflet multiplies them by 2^zoom like Scale.
+func (p Projection) Transformation() [4]float64 {
+	k := p.Scale(0)
+	return [4]float64{k, 0, -k, 0}
+}
+
+// Project returns the map pixel of s at the zoom level
+func (p Projection) Project(s complex128, zoom int) (x, y float64) {
+	k := p.Scale(zoom)
+	return real(s) * k, -imag(s) * k
+}
+
+// Unproject returns the point at map pixel x, y of the zoom level
+func (p Projection) Unproject(x, y float64, zoom int) complex128 {
+	k := p.Scale(zoom)
+	return complex(x/k, -y/k)
+}
+
+// Tile returns the tile drawn at map tile x, y of the zoom level. Map tile
+// rows count down from the real axis so row y is the tile at -y-1.
+func (p Projection) Tile(zoom, x, y int) *Tile {
+	return &Tile{Zoom: zoom, X: x, Y: -y - 1, Width: p.TileWidth}
+}
+
+// TileAt returns the tile at the zoom level that contains the point s
+func (p Projection) TileAt(zoom int, s complex128) *Tile {
+	t := &Tile{Zoom: zoom, Width: p.TileWidth}
+	units := t.Units()
+	t.X = int(math.Floor(real(s) / units))
+	t.Y = int(math.Floor(imag(s) / units))
+	return t
+}
+
+// MapTile returns the map tile the tile is drawn at
+func (p Projection) MapTile(t *Tile) (x, y int) {
+	return t.X, -t.Y - 1
+}
+
+// ImageRow returns the row of a tile's image that row y of its data is drawn
+// in, and the other way around. Data rows run up the imaginary axis from Min
+// while images are drawn top down.
+func ImageRow(width, y int) int {
+	return width - 1 - y
+}
@@ -0,0 +1,101 @@
+package zeta
+
+import (
+	"image/color"
+	"math"
+	"testing"
+	"zetamachine/pkg/palette"
+)
+
+func TestProjectionCorners(t *testing.T) {
+	p := DefaultProjection
+	w := float64(p.TileWidth)
+
+	for _, zoom := range []int{0, 1, 4, 9, 20} {
+		for _, mt := range [][2]int{{0, 0}, {0, -1}, {-1, 0}, {3, -7}, {-12, 5}} {
+			tile := p.Tile(zoom, mt[0], mt[1])
+			if x, y := p.MapTile(tile); x != mt[0] || y != mt[1] {
+				t.Fatalf("map tile %v is drawn at %d, %d", mt, x, y)
+			}
+
+			// Min is the bottom left corner of the map tile and Max the top right
+			x, y := p.Project(tile.Min(), zoom)
+			if x != float64(mt[0])*w || y != float64(mt[1]+1)*w {
+				t.Errorf("zoom %d map tile %v: min %v projects to %g, %g", zoom, mt, tile.Min(), x, y)
+			}
+			x, y = p.Project(tile.Max(), zoom)
+			if x != float64(mt[0]+1)*w || y != float64(mt[1])*w {
+				t.Errorf("zoom %d map tile %v: max %v projects to %g, %g", zoom, mt, tile.Max(), x, y)
+			}
+
+			if s := p.Unproject(float64(mt[0])*w, float64(mt[1]+1)*w, zoom); s != tile.Min() {
+				t.Errorf("zoom %d map tile %v: corner unprojects to %v, want %v", zoom, mt, s, tile.Min())
+			}
+		}
+	}
+}
+
+// TestProjectionClick clicks pixels of the map and checks the point is in
+// the tile drawn there, in the pixel drawn there
+func TestProjectionClick(t *testing.T) {
+	p := Projection{TileWidth: 8}
+	marked := palette.Original[50]
+
+	clicks := []struct {
+		zoom, x, y int // map pixel
+	}{
+		{0, 1, 1},
+		{0, -3, 6},
+		{2, 13, -2},
+		{5, -17, -9},
+		{11, 100, -100},
+	}
+	for _, c := range clicks {
+		// the centre of the pixel, as Leaflet reports it
+		s := p.Unproject(float64(c.x)+0.5, float64(c.y)+0.5, c.zoom)
+
+		mx := int(math.Floor(float64(c.x) / float64(p.TileWidth)))
+		my := int(math.Floor(float64(c.y) / float64(p.TileWidth)))
+		tile := p.Tile(c.zoom, mx, my)
+
+		if real(s) < real(tile.Min()) || real(s) >= real(tile.Max()) || imag(s) < imag(tile.Min()) || imag(s) >= imag(tile.Max()) {
+			t.Fatalf("%+v: %v is outside %v", c, s, tile)
+		}
+
+		// the data pixel the point is in is drawn where it was clicked
+		px, py := tile.Pixel(s)
+		tile.Data = make([]uint16, p.TileWidth*p.TileWidth)
+		tile.Data[py*tile.Width+px] = 50
+		img, err := tile.Render(palette.Original)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ix, iy := c.x-mx*p.TileWidth, c.y-my*p.TileWidth
+		if color.NRGBAModel.Convert(img.At(ix, iy)) != color.NRGBAModel.Convert(marked) {
+			t.Errorf("%+v: data pixel %d, %d isn't drawn at image pixel %d, %d", c, px, py, ix, iy)
+		}
+		if ImageRow(tile.Width, py) != iy {
+			t.Errorf("%+v: data row %d is drawn in image row %d, not %d", c, py, ImageRow(tile.Width, py), iy)
+		}
+	}
+}
+
+func TestProjectionTileAt(t *testing.T) {
+	p := DefaultProjection
+
+	// points exactly on the edge of a row are in the map tile above but the
+	// Tile below, so none of these are on an edge at these zooms
+	for _, s := range []complex128{0.3 + 0.7i, -3 + 700.01i, 1234.5 - 0.001i} {
+		for _, zoom := range []int{0, 3, 12} {
+			x, y := p.Project(s, zoom)
+			mx := int(math.Floor(x / float64(p.TileWidth)))
+			my := int(math.Floor(y / float64(p.TileWidth)))
+
+			want := TileAt(zoom, s)
+			if got := p.Tile(zoom, mx, my); got.X != want.X || got.Y != want.Y {
+				t.Errorf("%v at zoom %d: map tile %d, %d shows tile %d, %d, want %d, %d", s, zoom, mx, my, got.X, got.Y, want.X, want.Y)
+			}
+		}
+	}
+}
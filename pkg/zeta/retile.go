@@ -0,0 +1,54 @@
+package zeta
+
+import "fmt"
+
+// Split divides the tile into tiles width pixels wide at the same zoom level.
+// Tiles are 2^zoom pixels per unit whatever their width, so together they
+// cover the tile's square of the plane with exactly its pixels. The tile's
+// width must be a multiple of width.
+func (t *Tile) Split(width int) ([]*Tile, error) {
+	if t.Region != nil {
+		return nil, fmt.Errorf("tiles off the tile grid can't be split")
+	}
+	if width < 1 || t.Width%width != 0 {
+		return nil, fmt.Errorf("a %d pixel tile can't be split into %d pixel tiles", t.Width, width)
+	}
+
+	n := len(t.Data)
+	if t.Mode == ModeDomain {
+		n = len(t.Values) / 2
+	}
+	if n != t.Width*t.Width {
+		return nil, fmt.Errorf("%d pixels of data, expected %d", n, t.Width*t.Width)
+	}
+
+	k := t.Width / width
+	tiles := make([]*Tile, 0, k*k)
+	for j := 0; j < k; j++ {
+		for i := 0; i < k; i++ {
+			s := &Tile{
+				Zoom:     t.Zoom,
+				X:        t.X*k + i,
+				Y:        t.Y*k + j,
+				Width:    width,
+				Function: t.Function,
+				Params:   t.Params,
+				Mode:     t.Mode,
+				Sampling: t.Sampling,
+				LUT:      t.LUT,
+			}
+
+			// rows of data run up the imaginary axis like the tiles
+			for y := 0; y < width; y++ {
+				p := (j*width+y)*t.Width + i*width
+				if t.Mode == ModeDomain {
+					s.Values = append(s.Values, t.Values[2*p:2*(p+width)]...)
+				} else {
+					s.Data = append(s.Data, t.Data[p:p+width]...)
+				}
+			}
+			tiles = append(tiles, s)
+		}
+	}
+	return tiles, nil
+}
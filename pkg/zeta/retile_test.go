@@ -0,0 +1,56 @@
+package zeta
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tile := &Tile{Zoom: 2, X: -1, Y: -1, Width: 16}
+	if err := (&CPUBackend{}).Compute(context.Background(), tile); err != nil {
+		t.Fatal(err)
+	}
+
+	tiles, err := tile.Split(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tiles) != 4 {
+		t.Fatalf("split into %d tiles, want 4", len(tiles))
+	}
+
+	// each is the tile computed at its own position and width
+	for _, s := range tiles {
+		if real(s.Min()) < real(tile.Min()) || imag(s.Max()) > imag(tile.Max()) {
+			t.Fatalf("%v is outside %v", s, tile)
+		}
+
+		want := &Tile{Zoom: s.Zoom, X: s.X, Y: s.Y, Width: 8}
+		if err := (&CPUBackend{}).Compute(context.Background(), want); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(s.Data, want.Data) {
+			t.Errorf("tile %d, %d has data %v, computed %v", s.X, s.Y, s.Data, want.Data)
+		}
+	}
+
+	domain := &Tile{Zoom: 0, X: 1, Y: 2, Width: 4, Mode: ModeDomain, Values: make([]float32, 32)}
+	for i := range domain.Values {
+		domain.Values[i] = float32(i)
+	}
+	tiles, err = domain.Split(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the top right tile holds the real and imaginary parts of pixels 10,
+	// 11, 14 and 15
+	want := []float32{20, 21, 22, 23, 28, 29, 30, 31}
+	if s := tiles[3]; s.X != 3 || s.Y != 5 || !reflect.DeepEqual(s.Values, want) {
+		t.Errorf("top right tile %d, %d has values %v, want %v", s.X, s.Y, s.Values, want)
+	}
+
+	if _, err := tile.Split(5); err == nil {
+		t.Error("split a 16 pixel tile into 5 pixel tiles")
+	}
+}
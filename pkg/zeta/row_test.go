@@ -0,0 +1,123 @@
+package zeta
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+// testRow returns width evenly spaced real parts from min to max like a tile row
+func testRow(min, max float64, width int) []float64 {
+	sig := make([]float64, width)
+	for x := range sig {
+		sig[x] = min + (max-min)*(float64(x)/float64(width))
+	}
+	return sig
+}
+
+func TestZetaRowMatchesZeta(t *testing.T) {
+	rows := []struct {
+		min, max, t float64
+	}{
+		{-20, 20, 0.25},
+		{-4, 4, 14.134},
+		{-30, 10, 120},
+		{-2, 3, 700},
+		{0, 1, 1500},
+	}
+
+	for _, row := range rows {
+		sig := testRow(row.min, row.max, 256)
+		k := newRowKernel(complex(row.min, row.t), complex(row.max, row.t))
+
+		out := make([]complex128, len(sig))
+		k.zetaRow(sig, row.t, out)
+
+		for p := range sig {
+			s := complex(sig[p], row.t)
+			want := zeta(s)
+			if err := cmplx.Abs(out[p]-want) / math.Max(1, cmplx.Abs(want)); err > 1e-12 {
+				t.Fatalf("ζ(%v): row kernel %v, zeta %v, error %g", s, out[p], want, err)
+			}
+
+			// a point on its own is exactly the same, whatever the table
+			if got := newRowKernel(s, s).Eval(s); got != out[p] {
+				t.Fatalf("ζ(%v): row kernel %v, on its own %v", s, out[p], got)
+			}
+		}
+	}
+}
+
+func TestRowKernelIterationCounts(t *testing.T) {
+	const width = 64
+
+	// either side of the imaginary axis
+	for _, tile := range []*Tile{{Zoom: 2, X: -1, Y: 1, Width: width}, {Zoom: 2, X: 0, Y: 1, Width: width}} {
+		min, max := tile.Min(), tile.Max()
+		batched := (&Algo{}).Compute(context.Background(), min, max, width)
+
+		differ := 0
+		span := max - min
+		for y := 0; y < width; y++ {
+			for x := 0; x < width; x++ {
+				u := float64(x) / float64(width)
+				v := float64(y) / float64(width)
+				s := min + complex(real(span)*u, imag(span)*v)
+
+				// single points are iterated by the kernel too
+				its := int(batched[y*width+x])
+				if orbit := int(Orbit(s, OrbitParams{}).Iterations); its != orbit {
+					t.Fatalf("iterations at %v: row kernel %d, orbit %d", s, its, orbit)
+				}
+				if pixel := int(tile.ComputePixel(Zeta{}, x, y)); its != pixel {
+					t.Fatalf("iterations at %v: row kernel %d, pixel on its own %d", s, its, pixel)
+				}
+
+				// the iteration stops when successive iterates agree to 1e-15,
+				// the limit of double precision, so the order terms are summed
+				// in can change a count by one from iterating zeta itself
+				want := int(iterate(Zeta{}, s, epsilon))
+				if its < want-1 || its > want+1 {
+					t.Fatalf("iterations at %v: row kernel %d, scalar %d", s, its, want)
+				}
+				if its != want {
+					differ++
+				}
+			}
+		}
+
+		if differ > width*width/100 {
+			t.Fatalf("%v: %d of %d iteration counts differ from the scalar path", tile, differ, width*width)
+		}
+	}
+}
+
+// benchRow iterates every pixel of a 512 pixel row at height t
+func benchRow(b *testing.B, t float64, batched bool) {
+	sig := testRow(-2, 3, TileWidth)
+	k := newRowKernel(complex(-2, t), complex(3, t))
+	first := make([]complex128, len(sig))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if batched {
+			k.zetaRow(sig, t, first)
+			for p := range sig {
+				iterateFrom(k, complex(sig[p], t), first[p], epsilon)
+			}
+		} else {
+			for p := range sig {
+				iterate(Zeta{}, complex(sig[p], t), epsilon)
+			}
+		}
+	}
+}
+
+func BenchmarkIterateRow(b *testing.B) {
+	for _, t := range []float64{100, 400, 900} {
+		b.Run(fmt.Sprintf("scalar/t=%g", t), func(b *testing.B) { benchRow(b, t, false) })
+		b.Run(fmt.Sprintf("batched/t=%g", t), func(b *testing.B) { benchRow(b, t, true) })
+	}
+}
@@ -0,0 +1,56 @@
+package zeta
+
+import (
+	"math/cmplx"
+	"testing"
+)
+
+// TestRiemannSiegelMatchesEMS cross checks the Riemann-Siegel evaluator against
+// the Euler-Maclaurin sum above the threshold where zeta switches over
+func TestRiemannSiegelMatchesEMS(t *testing.T) {
+	heights := []float64{rsThreshold + 0.5, 1777.3, 2000, -3000, 4000, 8000}
+	reals := []float64{-6, -2, 0, 0.25, 0.5, 1, 2, 6, 10}
+
+	for _, im := range heights {
+		for _, re := range reals {
+			s := complex(re, im)
+			want := ems(s)
+			got := riemannSiegel(s)
+
+			if rel := cmplx.Abs(got-want) / cmplx.Abs(want); rel > 1e-4 {
+				t.Errorf("s=%v: riemannSiegel=%v ems=%v relative error %.2e", s, got, want, rel)
+			}
+		}
+	}
+}
+
+// TestRiemannSiegelFunctionalEquation checks points far to the left of the
+// critical strip, where ems loses accuracy, against ζ(s) = χ(s) ζ(1-s)
+func TestRiemannSiegelFunctionalEquation(t *testing.T) {
+	for _, s := range []complex128{-10 + 1500i, -20 + 2500i, -40 + 4000i, -20 - 2500i} {
+		var want complex128
+		if imag(s) < 0 {
+			want = cmplx.Conj(chiFactor(cmplx.Conj(s)) * ems(1-cmplx.Conj(s)))
+		} else {
+			want = chiFactor(s) * ems(1-s)
+		}
+		got := riemannSiegel(s)
+
+		if rel := cmplx.Abs(got-want) / cmplx.Abs(want); rel > 1e-8 {
+			t.Errorf("s=%v: riemannSiegel=%v χ(s)ζ(1-s)=%v relative error %.2e", s, got, want, rel)
+		}
+	}
+}
+
+// TestZetaSwitchesToRiemannSiegel makes sure zeta picks the evaluator by height
+func TestZetaSwitchesToRiemannSiegel(t *testing.T) {
+	below := complex(0.5, rsThreshold-1)
+	if zeta(below) != ems(below) {
+		t.Errorf("expected ems below the threshold at %v", below)
+	}
+
+	above := complex(0.5, rsThreshold+1)
+	if zeta(above) != riemannSiegel(above) {
+		t.Errorf("expected riemannSiegel above the threshold at %v", above)
+	}
+}
@@ -0,0 +1,193 @@
+package zeta
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Sampling methods. Tiles have always been sampled at the lower left corner
+// of each pixel, which is the default and leaves Tile.Sampling empty.
+const (
+	// SampleCentre samples the centre of each pixel
+	SampleCentre = "centre"
+
+	// SampleSuper samples an N×N grid in every pixel and reduces them to
+	// one value
+	SampleSuper = "ss"
+
+	// SampleAdaptive samples the centre of every pixel then an N×N grid in
+	// the pixels that differ from a neighbour, such as on basin boundaries
+	SampleAdaptive = "adapt"
+
+	// ReduceMean averages the samples' smooth iteration counts (see
+	// smoothCount), rounding only the mean. Domain colouring values are
+	// averaged as complex numbers.
+	ReduceMean = "mean"
+
+	// ReduceMajority takes the most common sample, the lowest of any tie.
+	// Newton samples vote on their root.
+	ReduceMajority = "majority"
+
+	maxSamplingN = 8
+
+	// adaptiveEdges is roughly the share of pixels adaptive sampling
+	// supersamples, for estimating its cost
+	adaptiveEdges = 0.2
+)
+
+var samplingRE = regexp.MustCompile(`^(ss|adapt)([0-9]+)(mean|majority)$`)
+
+// Sampling is how the points a pixel is computed from are chosen. It is
+// written ss3mean, adapt4majority or centre in tiles and set names.
+type Sampling struct {
+	Method  string
+	N       int
+	Reducer string
+}
+
+// ParseSampling parses a sampling method, empty for the corner of the pixel
+func ParseSampling(s string) (Sampling, error) {
+	if s == "" || s == SampleCentre {
+		return Sampling{Method: s, N: 1}, nil
+	}
+
+	m := samplingRE.FindStringSubmatch(s)
+	if m == nil {
+		return Sampling{}, fmt.Errorf("unknown sampling %q, expected centre, ss<n><reducer> or adapt<n><reducer> with reducer mean or majority", s)
+	}
+	n, _ := strconv.Atoi(m[2])
+	if n < 2 || n > maxSamplingN {
+		return Sampling{}, fmt.Errorf("sampling %q needs a grid from 2 to %d", s, maxSamplingN)
+	}
+	return Sampling{Method: m[1], N: n, Reducer: m[3]}, nil
+}
+
+// CheckSampling returns an error if tiles can't be sampled that way in the
+// mode. Domain colouring values can only be averaged and Newton's roots only
+// voted on.
+func CheckSampling(mode, sampling string) error {
+	s, err := ParseSampling(sampling)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case mode == ModeDomain && s.Reducer == ReduceMajority:
+		return fmt.Errorf("domain colouring values can't be reduced by majority, use mean")
+	case mode == ModeDomain && s.Method == SampleAdaptive:
+		return fmt.Errorf("domain colouring has no edges to sample adaptively")
+	case mode == ModeNewton && s.Reducer == ReduceMean:
+		return fmt.Errorf("Newton roots can't be averaged, use majority")
+	}
+	return nil
+}
+
+func (s Sampling) String() string {
+	if s.Reducer == "" {
+		return s.Method
+	}
+	return s.Method + strconv.Itoa(s.N) + s.Reducer
+}
+
+// offsets returns where in a pixel, from 0 to 1 across and up, the first
+// pass samples
+func (s Sampling) offsets() [][2]float64 {
+	switch s.Method {
+	case "":
+		return [][2]float64{{0, 0}}
+	case SampleSuper:
+		return s.grid()
+	}
+	return [][2]float64{{0.5, 0.5}}
+}
+
+// perPixel estimates the number of points computed for each pixel
+func (s Sampling) perPixel() float64 {
+	switch s.Method {
+	case SampleSuper:
+		return float64(s.N * s.N)
+	case SampleAdaptive:
+		return 1 + adaptiveEdges*float64(s.N*s.N)
+	}
+	return 1
+}
+
+// grid returns the centres of an N×N grid over a pixel
+func (s Sampling) grid() [][2]float64 {
+	offsets := make([][2]float64, 0, s.N*s.N)
+	for j := 0; j < s.N; j++ {
+		for i := 0; i < s.N; i++ {
+			offsets = append(offsets, [2]float64{(float64(i) + 0.5) / float64(s.N), (float64(j) + 0.5) / float64(s.N)})
+		}
+	}
+	return offsets
+}
+
+// reduceSmooth rounds the mean of the smooth iteration counts of a pixel's
+// samples
+func (s Sampling) reduceSmooth(samples []float64) uint16 {
+	sum := 0.0
+	for _, v := range samples {
+		sum += v
+	}
+	return tileCount(uint16(math.Round(sum / float64(len(samples)))))
+}
+
+// voteRoot takes the root most of a pixel's Newton samples converged to, the
+// lowest of any tie, with the rounded mean of their iteration counts
+func (s Sampling) voteRoot(samples []uint16) uint16 {
+	if len(samples) == 1 {
+		return samples[0]
+	}
+
+	roots := make([]uint16, len(samples))
+	for i, d := range samples {
+		root, _ := NewtonData(d)
+		roots[i] = uint16(root)
+	}
+	best := s.vote(roots)
+
+	sum, n := 0, 0
+	for _, d := range samples {
+		if root, its := NewtonData(d); uint16(root) == best {
+			sum += int(its)
+			n++
+		}
+	}
+	return best<<8 | uint16((sum+n/2)/n)
+}
+
+// vote takes the most common of a pixel's samples, the lowest of any tie
+func (s Sampling) vote(samples []uint16) uint16 {
+	if len(samples) == 1 {
+		return samples[0]
+	}
+
+	sorted := append([]uint16(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	best, bestCount := sorted[0], 0
+	for i := 0; i < len(sorted); {
+		j := i
+		for j < len(sorted) && sorted[j] == sorted[i] {
+			j++
+		}
+		if j-i > bestCount {
+			best, bestCount = sorted[i], j-i
+		}
+		i = j
+	}
+	return best
+}
+
+// reduceValues averages the domain colouring values of a pixel's samples
+func (s Sampling) reduceValues(samples []complex128) complex128 {
+	var sum complex128
+	for _, v := range samples {
+		sum += v
+	}
+	return sum / complex(float64(len(samples)), 0)
+}
@@ -0,0 +1,142 @@
+package zeta
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestParseSampling(t *testing.T) {
+	for _, s := range []string{"", "centre", "ss2mean", "ss8majority", "adapt3mean"} {
+		got, err := ParseSampling(s)
+		if err != nil {
+			t.Errorf("%q: %v", s, err)
+			continue
+		}
+		if got.String() != s {
+			t.Errorf("%q parsed to %q", s, got)
+		}
+	}
+
+	for _, s := range []string{"center", "ss1mean", "ss9mean", "ss3", "adapt3median", "ss-3mean", "ss3median"} {
+		if _, err := ParseSampling(s); err == nil {
+			t.Errorf("%q parsed without an error", s)
+		}
+	}
+
+	if err := CheckSampling(ModeDomain, "ss3majority"); err == nil {
+		t.Error("domain tiles can be reduced by majority")
+	}
+	if err := CheckSampling(ModeNewton, "ss3mean"); err == nil {
+		t.Error("Newton tiles can be averaged")
+	}
+	if err := CheckSampling(ModeNewton, "adapt2majority"); err != nil {
+		t.Error(err)
+	}
+
+	if set := (&Tile{Sampling: "centre"}).Set(); set != "zeta_centre" {
+		t.Errorf("zeta sampled at the centre is in set %q", set)
+	}
+}
+
+func TestReduce(t *testing.T) {
+	mean := Sampling{Method: SampleSuper, N: 2, Reducer: ReduceMean}
+	if got := mean.reduceSmooth([]float64{1.6, 2.2, 2.3, 2.4}); got != 2 {
+		t.Errorf("mean of 1.6, 2.2, 2.3, 2.4 is %d", got)
+	}
+	if got := mean.reduceSmooth([]float64{1.6, 1.7, 2.3, 2.4}); got != 2 {
+		t.Errorf("mean of 1.6, 1.7, 2.3, 2.4 rounds to %d", got)
+	}
+	if got := mean.reduceSmooth([]float64{255.4, 255.4, 255.4, 255.4}); got != maxTileITs {
+		t.Errorf("mean past the largest count is %d", got)
+	}
+
+	majority := Sampling{Method: SampleSuper, N: 2, Reducer: ReduceMajority}
+	if got := majority.vote([]uint16{9, 3, 9, 100}); got != 9 {
+		t.Errorf("majority of 9, 3, 9, 100 is %d", got)
+	}
+	if got := majority.vote([]uint16{7, 3, 3, 7}); got != 3 {
+		t.Errorf("majority of a tie is %d, want the lowest", got)
+	}
+
+	// three samples reach root 5 in different counts and two root 2 in the
+	// same count, which would win a vote on the whole data
+	newton := []uint16{5<<8 | 10, 2<<8 | 7, 5<<8 | 11, 2<<8 | 7, 5<<8 | 13}
+	if got := majority.voteRoot(newton); got != 5<<8|11 {
+		root, its := NewtonData(got)
+		t.Errorf("Newton majority is root %d in %d iterations, want root 5 in 11", root, its)
+	}
+	if got := majority.voteRoot([]uint16{3<<8 | 4, 1<<8 | 9}); got != 1<<8|9 {
+		t.Errorf("Newton majority of a tie is %x, want the lowest root", got)
+	}
+
+	if got := mean.reduceValues([]complex128{1, 1i, -1, -1i}); got != 0 {
+		t.Errorf("mean of the unit roots is %v", got)
+	}
+}
+
+func TestSampledCompute(t *testing.T) {
+	for _, sampling := range []string{"centre", "ss2majority", "ss2mean", "adapt2majority"} {
+		tile := &Tile{Zoom: 2, X: 0, Y: 0, Width: 16, Sampling: sampling}
+		if err := (&CPUBackend{}).Compute(context.Background(), tile); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := tile.Func()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		corner := &Tile{Zoom: 2, Width: 16}
+		differ := 0
+		for y := 0; y < tile.Width; y++ {
+			for x := 0; x < tile.Width; x++ {
+				p := &SeamPixel{Tile: tile, X: x, Y: y}
+				if err := p.check(f, 1); err != nil {
+					t.Fatal(err)
+				}
+				if p.Mismatched {
+					t.Fatalf("%s: pixel %d, %d is %d, computed on its own %d", sampling, x, y, p.Stored, p.Computed)
+				}
+				if p.Stored != corner.ComputePixel(f, x, y) {
+					differ++
+				}
+			}
+		}
+
+		// sampling elsewhere in the pixel changes some of them
+		if differ == 0 {
+			t.Errorf("%s: every pixel is the same as sampled at the corner", sampling)
+		}
+	}
+}
+
+func TestSmoothCount(t *testing.T) {
+	fractional := 0
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			s := complex(-20+float64(x)*1.3, -20+float64(y)*1.3)
+			count := iterate(Zeta{}, s, epsilon)
+			smooth := smoothCount(Zeta{}, s, zeta(s), epsilon)
+
+			// a single sample rounds back to its count
+			if smooth < float64(count)-0.5 || smooth >= float64(count)+0.5 {
+				t.Fatalf("%v: smooth count %g for count %d", s, smooth, count)
+			}
+			if smooth != float64(count) {
+				fractional++
+			}
+		}
+	}
+	if fractional == 0 {
+		t.Error("no smooth count has a fraction")
+	}
+
+	// the step crossing a limit halfway in log scale
+	if got := crossing(10, 1000, 100); math.Abs(got-0.5) > 1e-12 {
+		t.Errorf("crossing halfway is %g", got)
+	}
+	if got := crossing(1e-10, 0, 1e-15); got != 0 {
+		t.Errorf("converging exactly crosses at %g", got)
+	}
+}
@@ -0,0 +1,188 @@
+package zeta
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// Edges of a tile shared with its neighbours. The right neighbour is X+1 and
+// the top neighbour is Y+1, further along the imaginary axis.
+const (
+	EdgeRight = "right"
+	EdgeTop   = "top"
+)
+
+// Seam compares the pixels either side of the edge between two stored tiles
+// with the same pixels computed again
+type Seam struct {
+	A, B *Tile
+	Edge string
+
+	// Gap is how far apart, in pixels of the tiles, the end of A's last
+	// pixel and the start of B's first pixel are computed to be. Both are
+	// the same point on the plane, anything but 0 is float64 rounding.
+	Gap float64
+
+	Pixels     []SeamPixel
+	Mismatched int
+}
+
+// SeamPixel is a sampled pixel on one side of a seam
+type SeamPixel struct {
+	Tile          *Tile
+	X, Y          int
+	Stored        uint16
+	Computed      uint16
+	Value         complex128 // the stored value of domain tiles
+	ComputedValue complex128
+	Mismatched    bool
+}
+
+// CheckSeam computes samples pixels spread along the edge between the loaded
+// tiles a and b again, on both sides, and compares them with the stored data.
+// b must be a's neighbour on the edge. Iteration counts within tolerance of
+// each other match, as do domain values within that many parts per million,
+// so tiles computed with lookup tables or by another backend aren't flagged.
+func CheckSeam(a, b *Tile, edge string, samples int, tolerance int) (*Seam, error) {
+	if a.Set() != b.Set() || a.Zoom != b.Zoom || a.Width != b.Width {
+		return nil, fmt.Errorf("tiles %v and %v are not of the same set and size", a, b)
+	}
+	switch edge {
+	case EdgeRight:
+		if b.X != a.X+1 || b.Y != a.Y {
+			return nil, fmt.Errorf("%v is not right of %v", b, a)
+		}
+	case EdgeTop:
+		if b.X != a.X || b.Y != a.Y+1 {
+			return nil, fmt.Errorf("%v is not above %v", b, a)
+		}
+	default:
+		return nil, fmt.Errorf("unknown edge %q", edge)
+	}
+	if samples < 1 || samples > a.Width {
+		samples = a.Width
+	}
+
+	f, err := a.Func()
+	if err != nil {
+		return nil, err
+	}
+
+	w := a.Width
+	seam := &Seam{A: a, B: b, Edge: edge}
+
+	// where the last pixel of a ends and the first of b starts
+	aEnd, bStart := a.pixelCoord(w, w), b.pixelCoord(0, 0)
+	if edge == EdgeRight {
+		seam.Gap = (real(bStart) - real(aEnd)) * a.PPU()
+	} else {
+		seam.Gap = (imag(bStart) - imag(aEnd)) * a.PPU()
+	}
+
+	for i := 0; i < samples; i++ {
+		// spread the samples evenly from one end of the edge to the other
+		along := 0
+		if samples > 1 {
+			along = i * (w - 1) / (samples - 1)
+		}
+
+		ax, ay, bx, by := w-1, along, 0, along
+		if edge == EdgeTop {
+			ax, ay, bx, by = along, w-1, along, 0
+		}
+
+		for _, p := range []*SeamPixel{{Tile: a, X: ax, Y: ay}, {Tile: b, X: bx, Y: by}} {
+			if err := p.check(f, tolerance); err != nil {
+				return nil, err
+			}
+			if p.Mismatched {
+				seam.Mismatched++
+			}
+			seam.Pixels = append(seam.Pixels, *p)
+		}
+	}
+	return seam, nil
+}
+
+// check computes the pixel again and compares it with the stored data. The
+// pixels of adaptively sampled tiles match if they are either the centre
+// sample or the supersampled value, as either could be stored.
+func (p *SeamPixel) check(f Function, tolerance int) error {
+	t := p.Tile
+	i := p.Y*t.Width + p.X
+
+	if t.Mode == ModeDomain {
+		if 2*i+1 >= len(t.Values) {
+			return fmt.Errorf("tile %v has no data", t)
+		}
+		p.Value = t.DomainValue(i)
+		_, p.ComputedValue = t.computePixel(f, p.X, p.Y, false)
+
+		// the values are stored as float32
+		diff := cmplx.Abs(p.Value - p.ComputedValue)
+		p.Mismatched = diff > math.Max(float64(tolerance), 1)*1e-6*cmplx.Abs(p.ComputedValue)
+		return nil
+	}
+
+	if i >= len(t.Data) {
+		return fmt.Errorf("tile %v has no data", t)
+	}
+	p.Stored = t.Data[i]
+	p.Computed, _ = t.computePixel(f, p.X, p.Y, false)
+	p.Mismatched = !t.dataMatches(p.Stored, p.Computed, tolerance)
+
+	if sampling, _ := ParseSampling(t.Sampling); p.Mismatched && sampling.Method == SampleAdaptive {
+		if refined, _ := t.computePixel(f, p.X, p.Y, true); t.dataMatches(p.Stored, refined, tolerance) {
+			p.Computed, p.Mismatched = refined, false
+		}
+	}
+	return nil
+}
+
+// dataMatches compares iteration counts or Newton data within tolerance
+func (t *Tile) dataMatches(stored, computed uint16, tolerance int) bool {
+	if t.Mode == ModeNewton {
+		sr, sits := NewtonData(stored)
+		cr, cits := NewtonData(computed)
+		return sr == cr && absDiff(uint16(sits), uint16(cits)) <= uint16(tolerance)
+	}
+	return absDiff(stored, computed) <= uint16(tolerance)
+}
+
+// ComputePixel computes the data of pixel x, y of an iteration or Newton tile
+// on its own, the same way Algo computes the whole tile. Edge pixels of
+// adaptively sampled tiles are supersampled, which this doesn't know about,
+// so they are computed at their centre.
+func (t *Tile) ComputePixel(f Function, x, y int) uint16 {
+	d, _ := t.computePixel(f, x, y, false)
+	return d
+}
+
+// computePixel computes pixel x, y, as an edge pixel of an adaptively sampled
+// tile if refined is true
+func (t *Tile) computePixel(f Function, x, y int, refined bool) (uint16, complex128) {
+	sampling, _ := ParseSampling(t.Sampling)
+	a := &Algo{Func: f, Mode: t.Mode, Sampling: sampling}
+
+	min, max := t.Min(), t.Max()
+	if _, ok := f.(Zeta); ok && t.Mode == "" {
+		a.kernel = newRowKernel(min, max)
+	}
+
+	offsets := sampling.offsets()
+	if refined && sampling.Method == SampleAdaptive {
+		offsets = sampling.grid()
+	}
+	return a.pixel(x, y, offsets, min, max, t.Width)
+}
+
+// pixelCoord is the point pixel x, y is computed at by Algo, which spans the
+// tile from Min to Max rather than adding Units to Min like Coord
+func (t *Tile) pixelCoord(x, y int) complex128 {
+	min := t.Min()
+	span := t.Max() - min
+	u := float64(x) / float64(t.Width)
+	v := float64(y) / float64(t.Width)
+	return min + complex(real(span)*u, imag(span)*v)
+}
@@ -0,0 +1,59 @@
+package zeta
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCheckSeam(t *testing.T) {
+	compute := func(x, y int, mode string) *Tile {
+		tile := &Tile{Zoom: 1, X: x, Y: y, Width: 16, Mode: mode}
+		if err := (&CPUBackend{}).Compute(context.Background(), tile); err != nil {
+			t.Fatal(err)
+		}
+		return tile
+	}
+
+	for _, mode := range []string{"", ModeNewton, ModeDomain} {
+		a := compute(0, 0, mode)
+		right := compute(1, 0, mode)
+		top := compute(0, 1, mode)
+
+		for _, b := range []struct {
+			tile *Tile
+			edge string
+		}{{right, EdgeRight}, {top, EdgeTop}} {
+			seam, err := CheckSeam(a, b.tile, b.edge, 4, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(seam.Pixels) != 8 {
+				t.Fatalf("%q %s: sampled %d pixels, want 8", mode, b.edge, len(seam.Pixels))
+			}
+			if seam.Mismatched != 0 {
+				t.Errorf("%q %s: %d pixels mismatched in freshly computed tiles", mode, b.edge, seam.Mismatched)
+			}
+			if seam.Gap != 0 {
+				t.Errorf("%q %s: gap of %g pixels", mode, b.edge, seam.Gap)
+			}
+		}
+	}
+
+	// shift the stored data of the right tile along by a pixel
+	a, b := compute(0, 0, ""), compute(1, 0, "")
+	for y := 0; y < b.Width; y++ {
+		row := b.Data[y*b.Width : (y+1)*b.Width]
+		copy(row, row[1:])
+	}
+	seam, err := CheckSeam(a, b, EdgeRight, 16, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seam.Mismatched == 0 {
+		t.Error("no pixels mismatched along a shifted tile")
+	}
+
+	if _, err := CheckSeam(a, compute(2, 0, ""), EdgeRight, 4, 0); err == nil {
+		t.Error("checked the seam between tiles that aren't neighbours")
+	}
+}
@@ -0,0 +1,74 @@
+package zeta
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DataExt is the extension of a tile's data file
+const DataExt = ".dat.gz"
+
+// WalkFunc is called by WalkStore for each tile data file. The tile's set,
+// zoom and position are filled in from the file's path and its width from the
+// set's metadata, but its data is not loaded.
+type WalkFunc func(t *Tile, fname string, info os.FileInfo) error
+
+// WalkStore calls fn for every tile data file under root, laid out as
+// set/zoom/y/zoom.y.x.dat.gz like Tile.Path. Files that aren't tile data, such
+// as images, are skipped. An error returned by fn stops the walk.
+func WalkStore(root string, fn WalkFunc) error {
+	widths := NewSetWidths(root)
+	return filepath.Walk(root, func(fname string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.HasSuffix(info.Name(), DataExt) {
+			return nil
+		}
+
+		t, err := TileFromPath(root, fname)
+		if err != nil {
+			return fmt.Errorf("%s: %v", fname, err)
+		}
+		if t.Width, err = widths.Width(t.Set()); err != nil {
+			return err
+		}
+		return fn(t, fname, info)
+	})
+}
+
+// TileFromPath parses the set, zoom and position of a tile from the path of
+// its data file in the store at root. Its width is left for the caller to
+// set from the set's metadata, as WalkStore does.
+func TileFromPath(root, fname string) (*Tile, error) {
+	rel, err := filepath.Rel(root, fname)
+	if err != nil {
+		return nil, err
+	}
+	rel = filepath.ToSlash(rel)
+
+	tok := strings.Split(rel, "/")
+	if len(tok) != 3 && len(tok) != 4 {
+		return nil, fmt.Errorf("expected [set/]zoom/y/%s", "zoom.y.x"+DataExt)
+	}
+
+	t, err := ParseFilename(tok[len(tok)-1])
+	if err != nil {
+		return nil, err
+	}
+	if tok[len(tok)-3] != strconv.Itoa(t.Zoom) || tok[len(tok)-2] != strconv.Itoa(t.Y) {
+		return nil, fmt.Errorf("tile %d/%d/%d is in the wrong directory", t.Zoom, t.X, t.Y)
+	}
+
+	if len(tok) == 4 {
+		set, err := ParseSetName(tok[0])
+		if err != nil {
+			return nil, err
+		}
+		t.Mode, t.Function, t.Params, t.Sampling, t.LUT = set.Mode, set.Function, set.Params, set.Sampling, set.LUT
+	}
+	return t, nil
+}
@@ -0,0 +1,140 @@
+package zeta
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParseSetName(t *testing.T) {
+	tiles := []*Tile{
+		{},
+		{Function: "eta"},
+		{Function: "hurwitz", Params: map[string]float64{"a": 0.5}},
+		{Function: "dirichlet", Params: map[string]float64{"q": 5, "k": 1}},
+		{Mode: ModeNewton, Function: "zeta"},
+		{Mode: ModeDomain, Function: "hurwitz", Params: map[string]float64{"a": 1e-3}},
+		{Sampling: SampleCentre},
+		{Function: "hurwitz", Params: map[string]float64{"a": 0.5}, Sampling: "ss3mean"},
+		{Mode: ModeNewton, Function: "eta", Sampling: "adapt4majority"},
+		{LUT: true},
+		{Sampling: "ss2mean", LUT: true},
+	}
+
+	for _, want := range tiles {
+		got, err := ParseSetName(want.Set())
+		if err != nil {
+			t.Fatalf("%q: %v", want.Set(), err)
+		}
+
+		if got.Set() != want.Set() {
+			t.Errorf("%q parsed to set %q", want.Set(), got.Set())
+		}
+		if len(want.Params) > 0 && !reflect.DeepEqual(got.Params, want.Params) {
+			t.Errorf("%q parsed to params %v, want %v", want.Set(), got.Params, want.Params)
+		}
+		if got.Sampling != want.Sampling {
+			t.Errorf("%q parsed to sampling %q, want %q", want.Set(), got.Sampling, want.Sampling)
+		}
+		if got.LUT != want.LUT {
+			t.Errorf("%q parsed to lut %v, want %v", want.Set(), got.LUT, want.LUT)
+		}
+	}
+
+	for _, set := range []string{"newton", "gamma", "hurwitz_0.5", "hurwitz_ax", "domain_newton_zeta", "zeta_ss1mean", "zeta_lut_ss2mean"} {
+		if _, err := ParseSetName(set); err == nil {
+			t.Errorf("%q parsed without an error", set)
+		}
+	}
+}
+
+func TestWalkStore(t *testing.T) {
+	defer os.Setenv("ZETA_TILE_PATH", os.Getenv("ZETA_TILE_PATH"))
+	root := t.TempDir()
+	os.Setenv("ZETA_TILE_PATH", root)
+
+	stored := []*Tile{
+		{Zoom: 2, X: -1, Y: 3},
+		{Zoom: 4, X: 5, Y: -6, Function: "hurwitz", Params: map[string]float64{"a": 0.5}},
+		{Zoom: 0, X: 0, Y: 0, Mode: ModeNewton, Function: "zeta"},
+	}
+	want := []string{}
+	for _, tile := range stored {
+		tile.Width = 2
+		tile.Data = make([]uint16, 4)
+		if err := SaveMetadata(root, tile.Set(), &Metadata{TileWidth: 2}); err != nil {
+			t.Fatal(err)
+		}
+		if err := tile.Save(); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, tile.String())
+
+		// images next to the data are skipped
+		img := filepath.Join(tile.Path(), "image.png")
+		if err := ioutil.WriteFile(img, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := []string{}
+	err := WalkStore(root, func(tile *Tile, fname string, info os.FileInfo) error {
+		if err := tile.Load(); err != nil {
+			return err
+		}
+		got = append(got, tile.String())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(want)
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("walked %v, want %v", got, want)
+	}
+
+	// a data file in the wrong place stops the walk
+	misplaced := filepath.Join(root, "2", "3", "2.1.0"+DataExt)
+	if err := ioutil.WriteFile(misplaced, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := WalkStore(root, func(*Tile, string, os.FileInfo) error { return nil }); err == nil {
+		t.Fatal("walked a misplaced tile without an error")
+	}
+}
+
+func TestSaveLoadDomain(t *testing.T) {
+	defer os.Setenv("ZETA_TILE_PATH", os.Getenv("ZETA_TILE_PATH"))
+	os.Setenv("ZETA_TILE_PATH", t.TempDir())
+
+	tile := &Tile{Zoom: 1, X: -2, Y: 0, Width: 4, Mode: ModeDomain, Function: "eta"}
+	if err := (&CPUBackend{}).Compute(context.Background(), tile); err != nil {
+		t.Fatal(err)
+	}
+	if len(tile.Values) != 2*4*4 || len(tile.Data) != 0 {
+		t.Fatalf("computed %d values and %d counts", len(tile.Values), len(tile.Data))
+	}
+	if err := tile.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := &Tile{Zoom: 1, X: -2, Y: 0, Width: 4, Mode: ModeDomain, Function: "eta"}
+	if err := got.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Values, tile.Values) {
+		t.Errorf("loaded values %v, saved %v", got.Values, tile.Values)
+	}
+
+	// counts aren't stored in the domain set
+	counts := &Tile{Zoom: 1, X: -2, Y: 0, Width: 4, Function: "eta"}
+	if err := counts.Load(); err != ErrTileNotFound {
+		t.Errorf("loaded counts from the domain set: %v", err)
+	}
+}
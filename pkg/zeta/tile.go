@@ -14,19 +14,25 @@ import (
 	"log"
 	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"strconv"
 	"strings"
+	"zetamachine/pkg/palette"
 
 	"github.com/go-chi/chi"
 )
 
 const (
-	// TileWidth is the width of a rendered tile in pixels
+	// TileWidth is the width in pixels of the tiles of sets without metadata
+	// (see Metadata)
 	TileWidth = 512
 )
 
+// ErrTileNotFound is returned when loading a tile that has no data file
+var ErrTileNotFound = errors.New("Tile not found")
+
 // Tile holds information for generating a single zeta tile at a particular
 // zoom level
 type Tile struct {
@@ -35,17 +41,64 @@ type Tile struct {
 	Y     int      `json:"y"`
 	Width int      `json:"width"`
 	Data  []uint16 `json:"data"`
+
+	// Function is the name of the iterated function (see NewFunction) and
+	// Params are its parameters. Both are empty for zeta.
+	Function string             `json:"function,omitempty"`
+	Params   map[string]float64 `json:"params,omitempty"`
+
+	// Mode is how the function is rendered, empty for the iteration count,
+	// ModeNewton or ModeDomain
+	Mode string `json:"mode,omitempty"`
+
+	// Sampling is how the points each pixel is computed from are chosen (see
+	// ParseSampling), empty for the lower left corner
+	Sampling string `json:"sampling,omitempty"`
+
+	// LUT selects the set computed with the lookup tables of the backend.
+	// Their counts can differ slightly from full computation (see
+	// Algo.LUTs), so they are stored apart. Only zeta iteration tiles can be.
+	LUT bool `json:"lut,omitempty"`
+
+	// Values holds the real and imaginary parts of each pixel of a ModeDomain
+	// tile in place of Data
+	Values []float32 `json:"values,omitempty"`
+
+	// Region, if set, is the square of the plane the tile covers in place of
+	// the square on the tile grid at Zoom, X and Y. Lookup tables are
+	// rendered in regions off the tile grid.
+	Region *Region `json:"region,omitempty"`
+}
+
+// Region is a square of the plane from Min (real, imaginary) with sides of
+// Units
+type Region struct {
+	Min   [2]float64 `json:"min"`
+	Units float64    `json:"units"`
 }
 
-// Render generates a single tile image using the tile's properties
+// Render generates a single tile image using the tile's properties. The
+// imaginary axis points up the image (see ImageRow).
 func (t *Tile) Render(colors []color.Color) (image.Image, error) {
 
 	rgba := image.NewNRGBA(image.Rect(0, 0, t.Width, t.Width))
 
+	if t.Mode == ModeDomain {
+		for i := 0; i < len(t.Values)/2; i++ {
+			rgba.Set(i%t.Width, ImageRow(t.Width, i/t.Width), palette.Domain(t.DomainValue(i)))
+		}
+		return rgba, nil
+	}
+
 	for i := range t.Data {
 		x := i % t.Width
-		y := i / t.Width
+		y := ImageRow(t.Width, i/t.Width)
 		c := t.Data[i]
+
+		if t.Mode == ModeNewton {
+			rgba.Set(x, y, palette.Newton(NewtonData(c)))
+			continue
+		}
 		rgba.Set(x, y, colors[c])
 	}
 	var img image.Image = rgba
@@ -53,36 +106,148 @@ func (t *Tile) Render(colors []color.Color) (image.Image, error) {
 	return img, nil
 }
 
+// ErrInvalidRequest is wrapped by the errors RequestToTile returns for
+// requests that don't describe a tile
+var ErrInvalidRequest = errors.New("invalid tile request")
+
 // RequestToTile parses the URL parameters to get the tile arguments, then it
-// constructs a *Tile instance and returns it
-func RequestToTile(r *http.Request) (*Tile, error) {
+// constructs a *Tile instance as wide as the tiles of its set and returns it
+func RequestToTile(r *http.Request, widths *SetWidths) (*Tile, error) {
 	zoom, err := strconv.Atoi(chi.URLParam(r, "zoom"))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: zoom: %v", ErrInvalidRequest, err)
 	}
 
 	x, err := strconv.Atoi(chi.URLParam(r, "x"))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: x: %v", ErrInvalidRequest, err)
 	}
 
 	y, err := strconv.Atoi(chi.URLParam(r, "y"))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: y: %v", ErrInvalidRequest, err)
 	}
 
-	t := &Tile{Zoom: zoom, X: x, Y: y, Width: TileWidth}
+	t := &Tile{Zoom: zoom, X: x, Y: y}
+	if err := t.ParseQuery(r.URL.Query()); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+
+	if t.Width, err = widths.Width(t.Set()); err != nil {
+		return nil, err
+	}
 
 	return t, nil
 }
 
+// ParseQuery sets the function, its parameters and the mode of the tile from
+// URL query values. Values named for one of the function's parameters are
+// its parameters and any others, such as a cache buster, are ignored.
+func (t *Tile) ParseQuery(query url.Values) error {
+	t.Function = query.Get("function")
+	t.Mode = query.Get("mode")
+	t.Sampling = query.Get("sampling")
+
+	t.LUT = false
+	if v := query.Get("lut"); v != "" {
+		lut, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid lut: %v", err)
+		}
+		t.LUT = lut
+	}
+
+	names, err := FunctionParams(t.Function)
+	if err != nil {
+		return err
+	}
+	t.Params = nil
+	for _, k := range names {
+		if _, ok := query[k]; !ok {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(query.Get(k), 64)
+		if err != nil {
+			return fmt.Errorf("invalid parameter %s: %v", k, err)
+		}
+		if t.Params == nil {
+			t.Params = make(map[string]float64)
+		}
+		t.Params[k] = v
+	}
+
+	_, err = t.Func()
+	return err
+}
+
+// Func constructs the function this tile iterates and checks it can be
+// rendered in the tile's mode and sampling
+func (t *Tile) Func() (Function, error) {
+	f, err := NewFunction(t.Function, t.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckMode(t.Mode, f); err != nil {
+		return nil, err
+	}
+	if err := CheckSampling(t.Mode, t.Sampling); err != nil {
+		return nil, err
+	}
+	if _, ok := f.(Zeta); t.LUT && (!ok || t.Mode != "") {
+		return nil, errors.New("lookup tables only speed up zeta iteration tiles")
+	}
+	return f, nil
+}
+
+// CheckMode returns an error if the function can not be rendered in the mode
+func CheckMode(mode string, f Function) error {
+	switch mode {
+	case "", ModeDomain:
+		return nil
+	case ModeNewton:
+		if _, ok := f.(Derivative); !ok {
+			return fmt.Errorf("the %s mode needs a function with a derivative", mode)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown mode %q", mode)
+}
+
+// Set names the set of tiles this one belongs to in storage. Iterated zeta
+// tiles sampled at the pixel corners belong to the unnamed set. Tiles
+// computed with lookup tables are in a set of their own ending in _lut.
+func (t *Tile) Set() string {
+	set := SetName(t.Mode, t.Function, t.Params)
+	if t.Sampling == "" && !t.LUT {
+		return set
+	}
+	if set == "" {
+		set = DefaultFunction
+	}
+	if t.Sampling != "" {
+		set += "_" + t.Sampling
+	}
+	if t.LUT {
+		set += "_" + lutSuffix
+	}
+	return set
+}
+
 // PPU returns the resolution of this tile in pixels per unit
 func (t *Tile) PPU() float64 {
+	if t.Region != nil {
+		return float64(t.Width) / t.Region.Units
+	}
 	return math.Pow(2, float64(t.Zoom))
 }
 
 // Min returns the lower left coordinate in 'units' this tile renders
 func (t *Tile) Min() complex128 {
+	if t.Region != nil {
+		return complex(t.Region.Min[0], t.Region.Min[1])
+	}
 	units := t.Units()
 	r := float64(t.X) * units
 	i := float64(t.Y) * units
@@ -99,8 +264,42 @@ func (t *Tile) Max() complex128 {
 	return complex(r, i)
 }
 
+// TileAt returns the TileWidth wide tile at the zoom level that contains the
+// point s (see Projection.TileAt)
+func TileAt(zoom int, s complex128) *Tile {
+	return DefaultProjection.TileAt(zoom, s)
+}
+
+// Coord returns the point pixel x, y of the tile is computed at
+func (t *Tile) Coord(x, y int) complex128 {
+	u := float64(x) / float64(t.Width)
+	v := float64(y) / float64(t.Width)
+	return t.Min() + complex(t.Units()*u, t.Units()*v)
+}
+
+// Pixel returns the pixel of the tile containing s, that is the pixel x, y
+// covering Coord(x, y) up to Coord(x+1, y+1). The result is clamped to the
+// tile.
+func (t *Tile) Pixel(s complex128) (int, int) {
+	d := (s - t.Min()) * complex(t.PPU(), 0)
+	clamp := func(v float64) int {
+		p := int(math.Floor(v))
+		if p < 0 {
+			return 0
+		}
+		if p >= t.Width {
+			return t.Width - 1
+		}
+		return p
+	}
+	return clamp(real(d)), clamp(imag(d))
+}
+
 // Units is the number of 'units' this tile covers (this is not pixels)
 func (t *Tile) Units() float64 {
+	if t.Region != nil {
+		return t.Region.Units
+	}
 	return float64(t.Width) / t.PPU()
 }
 
@@ -120,8 +319,12 @@ func (t *Tile) Filename() string {
 
 // Path returns the full relative path to the file
 func (t *Tile) Path() string {
-	tilePath := os.Getenv("ZETA_TILE_PATH")
-	return path.Join(tilePath, fmt.Sprintf("%d/%d", t.Zoom, t.Y))
+	return t.Dir(os.Getenv("ZETA_TILE_PATH"))
+}
+
+// Dir returns the directory of the tile's file in the store at root
+func (t *Tile) Dir(root string) string {
+	return path.Join(SetDir(root, t.Set()), fmt.Sprintf("%d/%d", t.Zoom, t.Y))
 }
 
 // Exists checks if the tile is already on the local disk
@@ -132,12 +335,17 @@ func (t *Tile) Exists() (os.FileInfo, error) {
 }
 
 func (t *Tile) String() string {
-	return fmt.Sprint("zoom:", t.Zoom, " x:", t.X, " y:", t.Y, " ppu:", t.PPU(), " min:", t.Min(), " max:", t.Max(), " units:", t.Units(), " width:", t.Width)
+	return fmt.Sprint("set:", t.Set(), " zoom:", t.Zoom, " x:", t.X, " y:", t.Y, " ppu:", t.PPU(), " min:", t.Min(), " max:", t.Max(), " units:", t.Units(), " width:", t.Width)
 }
 
 // Save saves the binary iteration data from a tile
 func (t *Tile) Save() error {
-	fpath := t.Path()
+	return t.SaveTo(os.Getenv("ZETA_TILE_PATH"))
+}
+
+// SaveTo saves the tile's data to the store at root
+func (t *Tile) SaveTo(root string) error {
+	fpath := t.Dir(root)
 	fname := path.Join(fpath, t.Filename())
 
 	// does not return an error if the path exists. creates the path recusively
@@ -154,7 +362,7 @@ func (t *Tile) Save() error {
 	// convert the []int to []byte
 	var buf bytes.Buffer
 	enc := gob.NewEncoder(&buf)
-	if err := enc.Encode(t.Data); err != nil {
+	if err := enc.Encode(t.payload()); err != nil {
 		return err
 	}
 
@@ -173,13 +381,26 @@ func (t *Tile) Save() error {
 	return nil
 }
 
-// TileFromFilename is a helper function that parses a tile's info from the
-// filename, then loads it from the standard tile data path.
-func TileFromFilename(fname string) (*Tile, error) {
+// payload points to the data saved for the tile, which depends on its mode
+func (t *Tile) payload() interface{} {
+	if t.Mode == ModeDomain {
+		return &t.Values
+	}
+	return &t.Data
+}
+
+// ParseFilename parses a tile's zoom and position from the name of its data
+// file or an image named the same way, such as 4.-2.1.png. The name doesn't
+// say which set the tile is in, so its width is left for the caller to set
+// from the set's metadata.
+func ParseFilename(fname string) (*Tile, error) {
 	if strings.ContainsAny(fname, "\\/") {
 		return nil, errors.New("File name contains path separators: " + fname)
 	}
 	tok := strings.Split(fname, ".")
+	if len(tok) < 3 {
+		return nil, errors.New("File name is not zoom.y.x: " + fname)
+	}
 	zoom, err := strconv.Atoi(tok[0])
 	if err != nil {
 		return nil, err
@@ -193,15 +414,7 @@ func TileFromFilename(fname string) (*Tile, error) {
 		return nil, err
 	}
 
-	t := &Tile{
-		Zoom:  zoom,
-		X:     x,
-		Y:     y,
-		Width: TileWidth,
-	}
-
-	err = t.Load()
-	return t, err
+	return &Tile{Zoom: zoom, X: x, Y: y}, nil
 }
 
 // Load ...
@@ -224,12 +437,12 @@ func (t *Tile) Load() error {
 
 		buf := bytes.NewBuffer(b)
 		dec := gob.NewDecoder(buf)
-		if err := dec.Decode(&t.Data); err != nil {
+		if err := dec.Decode(t.payload()); err != nil {
 			log.Println("Failed to decode data file:", err)
 			return err
 		}
 	} else {
-		err = errors.New("Tile not found")
+		return ErrTileNotFound
 	}
 
 	return nil
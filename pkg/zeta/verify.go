@@ -0,0 +1,82 @@
+package zeta
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// Problem classifies what is wrong with a tile's data file
+type Problem string
+
+// Problems found by Verify, and ProblemPath for data files TileFromPath
+// can't place. ProblemNone means the file is intact.
+const (
+	ProblemNone       Problem = ""
+	ProblemPath       Problem = "path"
+	ProblemUnreadable Problem = "unreadable"
+	ProblemGzip       Problem = "gzip"
+	ProblemTruncated  Problem = "truncated"
+	ProblemDecode     Problem = "decode"
+	ProblemWidth      Problem = "width"
+	ProblemZero       Problem = "zero"
+)
+
+// Problems lists every problem in the order they are checked
+var Problems = []Problem{ProblemPath, ProblemUnreadable, ProblemGzip, ProblemTruncated, ProblemDecode, ProblemWidth, ProblemZero}
+
+// Verify checks the data file fname holds an intact tile width pixels wide.
+// The tile's mode decides what the data should be. The data is left in the
+// tile. All zero data, which is left by renders cancelled before they wrote
+// anything, is a problem too.
+func (t *Tile) Verify(fname string, width int) (Problem, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return ProblemUnreadable, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return ProblemGzip, err
+	}
+	b, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return ProblemTruncated, err
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(t.payload()); err != nil {
+		return ProblemDecode, err
+	}
+
+	n := len(t.Data)
+	if t.Mode == ModeDomain {
+		n = len(t.Values) / 2
+	}
+	if n != width*width {
+		return ProblemWidth, fmt.Errorf("%d pixels, expected %d", n, width*width)
+	}
+
+	if t.zero() {
+		return ProblemZero, fmt.Errorf("every pixel is zero")
+	}
+	return ProblemNone, nil
+}
+
+// zero reports whether every value of the tile's data is zero
+func (t *Tile) zero() bool {
+	for _, v := range t.Data {
+		if v != 0 {
+			return false
+		}
+	}
+	for _, v := range t.Values {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package zeta
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	defer os.Setenv("ZETA_TILE_PATH", os.Getenv("ZETA_TILE_PATH"))
+	os.Setenv("ZETA_TILE_PATH", t.TempDir())
+
+	save := func(tile *Tile) string {
+		if err := tile.Save(); err != nil {
+			t.Fatal(err)
+		}
+		return filepath.Join(tile.Path(), tile.Filename())
+	}
+
+	good := save(&Tile{Zoom: 1, X: 0, Y: 0, Data: []uint16{0, 1, 2, 3}})
+	zero := save(&Tile{Zoom: 1, X: 1, Y: 0, Data: make([]uint16, 4)})
+	wide := save(&Tile{Zoom: 1, X: 2, Y: 0, Data: make([]uint16, 9)})
+	domain := save(&Tile{Zoom: 1, X: 3, Y: 0, Mode: ModeDomain, Values: []float32{1, 0, 0, 1, -1, 0, 0, -1}})
+
+	b, err := ioutil.ReadFile(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := filepath.Join(filepath.Dir(good), "truncated"+DataExt)
+	if err := ioutil.WriteFile(truncated, b[:len(b)-12], 0644); err != nil {
+		t.Fatal(err)
+	}
+	notGzip := filepath.Join(filepath.Dir(good), "plain"+DataExt)
+	if err := ioutil.WriteFile(notGzip, []byte("not a tile"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	notGob := filepath.Join(filepath.Dir(good), "gob"+DataExt)
+	c, err := compress([]byte("not a gob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(notGob, c, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		fname string
+		mode  string
+		want  Problem
+	}{
+		{good, "", ProblemNone},
+		{domain, ModeDomain, ProblemNone},
+		{zero, "", ProblemZero},
+		{wide, "", ProblemWidth},
+		{truncated, "", ProblemTruncated},
+		{notGzip, "", ProblemGzip},
+		{notGob, "", ProblemDecode},
+		{filepath.Join(filepath.Dir(good), "missing"+DataExt), "", ProblemUnreadable},
+	}
+	for _, test := range tests {
+		tile := &Tile{Mode: test.mode}
+		got, err := tile.Verify(test.fname, 2)
+		if got != test.want {
+			t.Errorf("%s: got %q (%v), want %q", filepath.Base(test.fname), got, err, test.want)
+		}
+		if (err == nil) != (test.want == ProblemNone) {
+			t.Errorf("%s: unexpected error %v", filepath.Base(test.fname), err)
+		}
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	defer os.Setenv("ZETA_TILE_PATH", os.Getenv("ZETA_TILE_PATH"))
+	os.Setenv("ZETA_TILE_PATH", t.TempDir())
+
+	tile := &Tile{Zoom: 3, X: 1, Y: 2, Width: TileWidth}
+	if err := tile.Load(); err != ErrTileNotFound {
+		t.Fatalf("loading a missing tile returned %v, want ErrTileNotFound", err)
+	}
+}
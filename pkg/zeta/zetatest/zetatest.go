@@ -0,0 +1,191 @@
+// Package zetatest is a conformance suite for zeta.Backend implementations.
+// Every backend's tests should pass it:
+//
+//	func TestConformance(t *testing.T) {
+//		zetatest.TestBackend(t, myBackend)
+//	}
+package zetatest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	"zetamachine/pkg/zeta"
+)
+
+// Width is the width of the tiles computed by the suite, small enough for it
+// to run quickly on a CPU
+const Width = 16
+
+// Tiles are the tiles every backend computes: the bulb, the arm along the
+// critical line and a deep zoom far up it
+var Tiles = []struct {
+	Name string
+	Tile zeta.Tile
+}{
+	{"bulb", zeta.Tile{Zoom: 3, X: -1, Y: 0, Width: Width}},
+	{"arm", zeta.Tile{Zoom: 5, X: 0, Y: 100, Width: Width}},
+	{"deep", zeta.Tile{Zoom: 12, X: 128, Y: 38400, Width: Width}},
+}
+
+// TestBackend runs the conformance suite against the backend
+func TestBackend(t *testing.T, b zeta.Backend) {
+	t.Run("Name", func(t *testing.T) {
+		if b.Name() == "" {
+			t.Fatal("backend has no name")
+		}
+	})
+
+	for _, tc := range Tiles {
+		tile := tc.Tile
+		t.Run("Iterations/"+tc.Name, func(t *testing.T) {
+			testIterations(t, b, &tile)
+		})
+	}
+
+	t.Run("Functions", func(t *testing.T) { testFunctions(t, b) })
+	t.Run("Domain", func(t *testing.T) { testDomain(t, b) })
+	t.Run("Canceled", func(t *testing.T) { testCanceled(t, b) })
+	t.Run("Concurrent", func(t *testing.T) { testConcurrent(t, b) })
+}
+
+// CheckIterations compares the iteration counts of a computed tile with
+// zeta.Orbit at every pixel. The iteration stops at the limit of double
+// precision so counts may differ by one, but only rarely.
+func CheckIterations(t *testing.T, tile *zeta.Tile) {
+	t.Helper()
+
+	if len(tile.Data) != tile.Width*tile.Width {
+		t.Fatalf("%v: got %d values, want %d", tile, len(tile.Data), tile.Width*tile.Width)
+	}
+
+	f, err := tile.Func()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mismatch := 0
+	for y := 0; y < tile.Width; y++ {
+		for x := 0; x < tile.Width; x++ {
+			s := tile.Coord(x, y)
+			// tile counts stop at 255 so there is no need to follow the
+			// orbit much further
+			want := int(zeta.Orbit(s, zeta.OrbitParams{Func: f, MaxITs: 256}).Iterations)
+			got := int(tile.Data[y*tile.Width+x])
+
+			if got < want-1 || got > want+1 {
+				t.Fatalf("%v: pixel %d,%d at %v has %d iterations, want %d", tile, x, y, s, got, want)
+			}
+			if got != want {
+				mismatch++
+			}
+		}
+	}
+
+	if mismatch > len(tile.Data)/50 {
+		t.Fatalf("%v: %d of %d pixels are off by one", tile, mismatch, len(tile.Data))
+	}
+}
+
+func testIterations(t *testing.T, b zeta.Backend, tile *zeta.Tile) {
+	if err := b.Compute(context.Background(), tile); err != nil {
+		t.Fatal(err)
+	}
+	CheckIterations(t, tile)
+}
+
+// testFunctions checks other functions are either computed correctly or
+// refused with an error
+func testFunctions(t *testing.T, b zeta.Backend) {
+	tiles := []*zeta.Tile{
+		{Zoom: 3, X: 0, Y: 0, Width: Width, Function: "eta"},
+		{Zoom: 3, X: 0, Y: 0, Width: Width, Function: "hurwitz", Params: map[string]float64{"a": 0.5}},
+	}
+
+	for _, tile := range tiles {
+		if err := b.Compute(context.Background(), tile); err != nil {
+			t.Log(b.Name(), "does not support", tile.Set(), ":", err)
+			continue
+		}
+		CheckIterations(t, tile)
+	}
+}
+
+// testDomain checks domain colouring tiles are either computed correctly or
+// refused with an error
+func testDomain(t *testing.T, b zeta.Backend) {
+	tile := &zeta.Tile{Zoom: 3, X: -1, Y: 0, Width: Width, Mode: zeta.ModeDomain}
+	if err := b.Compute(context.Background(), tile); err != nil {
+		t.Log(b.Name(), "does not support", tile.Set(), ":", err)
+		return
+	}
+
+	if len(tile.Values) != 2*Width*Width {
+		t.Fatalf("got %d values, want %d", len(tile.Values), 2*Width*Width)
+	}
+
+	f, _ := tile.Func()
+	for y := 0; y < Width; y++ {
+		for x := 0; x < Width; x++ {
+			want := f.Eval(tile.Coord(x, y))
+			got := tile.DomainValue(y*Width + x)
+
+			// values are stored as float32
+			if d := got - want; real(d)*real(d)+imag(d)*imag(d) > 1e-10*(1+real(want)*real(want)+imag(want)*imag(want)) {
+				t.Fatalf("pixel %d,%d: got %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+// testCanceled checks a canceled tile stops quickly with an error
+func testCanceled(t *testing.T, b zeta.Backend) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tile := &zeta.Tile{Zoom: 0, X: 0, Y: 1, Width: zeta.TileWidth}
+	done := make(chan error, 1)
+	go func() {
+		done <- b.Compute(ctx, tile)
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("canceled tile did not return an error")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("canceled tile is still computing")
+	}
+}
+
+// testConcurrent checks tiles computed at the same time match
+func testConcurrent(t *testing.T, b zeta.Backend) {
+	tiles := make([]*zeta.Tile, 4)
+	errs := make([]error, len(tiles))
+
+	var wg sync.WaitGroup
+	for i := range tiles {
+		tile := Tiles[0].Tile
+		tiles[i] = &tile
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = b.Compute(context.Background(), tiles[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for i, tile := range tiles {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		for p := range tile.Data {
+			if tile.Data[p] != tiles[0].Data[p] {
+				t.Fatalf("tile %d differs from tile 0 at pixel %d", i, p)
+			}
+		}
+	}
+}